@@ -26,6 +26,17 @@ const (
 	MEthStateTrie       = 0x96
 	MEthAccountSnapshot = 0x97
 	MEthStorageTrie     = 0x98
+	MEthLogTrie         = 0x99
+	MEthLog             = 0x9a
+)
+
+// EXPERIMENTAL IPLD Codecs for Ethereum withdrawals (EIP-4895).
+// These codes are NOT assigned in the multicodec table, they are
+// only meant for local use and may change once the table has them.
+// Do not publish cids built with them.
+const (
+	MEthWithdrawalTrie = 0x9b
+	MEthWithdrawal     = 0x9c
 )
 
 // rawdataToCid takes the desired codec and a slice of bytes
@@ -115,6 +126,11 @@ func (lt *localTrie) add(idx int, rawdata []byte) {
 	lt.trie.Update(key, rawdata)
 }
 
+// put adds to the trie the given value under the given key.
+func (lt *localTrie) put(key, rawdata []byte) {
+	lt.trie.Update(key, rawdata)
+}
+
 // rootHash returns the computed trie root.
 // Useful for sanity checks on parsed data.
 func (lt *localTrie) rootHash() []byte {
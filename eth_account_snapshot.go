@@ -81,6 +81,14 @@ func (as *EthAccountSnapshot) Resolve(p []string) (interface{}, []string, error)
 		return as, nil, nil
 	}
 
+	switch p[0] {
+	case "codeHash":
+		return &node.Link{Cid: keccak256ToCid(RawBinary, as.CodeHash)}, p[1:], nil
+	case "root", "storage":
+		// "storage" is an alias, to read as "accounts/<address>/storage/slot/0x0"
+		return &node.Link{Cid: keccak256ToCid(MEthStorageTrie, as.Root)}, p[1:], nil
+	}
+
 	if len(p) > 1 {
 		return nil, nil, fmt.Errorf("unexpected path elements past %s", p[0])
 	}
@@ -88,12 +96,8 @@ func (as *EthAccountSnapshot) Resolve(p []string) (interface{}, []string, error)
 	switch p[0] {
 	case "balance":
 		return as.Balance, nil, nil
-	case "codeHash":
-		return &node.Link{Cid: keccak256ToCid(RawBinary, as.CodeHash)}, nil, nil
 	case "nonce":
 		return as.Nonce, nil, nil
-	case "root":
-		return &node.Link{Cid: keccak256ToCid(MEthStorageTrie, as.Root)}, nil, nil
 	default:
 		return nil, nil, fmt.Errorf("no such link")
 	}
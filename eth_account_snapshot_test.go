@@ -115,6 +115,7 @@ func TestAccountSnapshotResolve(t *testing.T) {
 		"codeHash",
 		"nonce",
 		"root",
+		"storage",
 	}
 	for _, gc := range goodCases {
 		_, _, err = eas.Resolve([]string{gc})
@@ -192,6 +193,48 @@ func TestAccountSnapshotResolveLink(t *testing.T) {
 	}
 }
 
+func TestAccountSnapshotResolveStorage(t *testing.T) {
+	eas := prepareEthAccountSnapshot(t)
+
+	for _, p := range []string{"root", "storage"} {
+		obj, rest, err := eas.ResolveLink([]string{p, "slot", "0x0"})
+		checkError(err, t)
+		if obj.Cid.String() != "z46gvXALNuXdCn6ts67LS6JkPUkZb7zNrH6fMayQM7U9HNLDtWt" {
+			t.Fatalf("Wrong cid for %s", p)
+		}
+		if len(rest) != 2 || rest[0] != "slot" || rest[1] != "0x0" {
+			t.Fatalf("Wrong rest for %s", p)
+		}
+	}
+}
+
+func TestAccountSnapshotResolvePassThrough(t *testing.T) {
+	eas := prepareEthAccountSnapshot(t)
+
+	// The links pass the rest of the path through
+	for _, p := range []string{"codeHash", "root", "storage"} {
+		lnk, rest, err := eas.ResolveLink([]string{p, "some", "path"})
+		checkError(err, t)
+		if lnk == nil {
+			t.Fatalf("Expected a link for %s", p)
+		}
+		if len(rest) != 2 || rest[0] != "some" || rest[1] != "path" {
+			t.Fatalf("Wrong rest for %s", p)
+		}
+	}
+
+	// While the values take no further element
+	for _, p := range []string{"balance", "nonce"} {
+		obj, rest, err := eas.Resolve([]string{p, "some"})
+		if obj != nil || rest != nil {
+			t.Fatalf("Expected nothing resolved for %s", p)
+		}
+		if err == nil || err.Error() != "unexpected path elements past "+p {
+			t.Fatalf("Expected error 'unexpected path elements past %s'\r\ngot %v", p, err)
+		}
+	}
+}
+
 func TestAccountSnapshotCopy(t *testing.T) {
 	eas := prepareEthAccountSnapshot(t)
 
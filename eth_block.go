@@ -6,21 +6,66 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/big"
+	"sort"
+	"strings"
 
 	cid "github.com/ipfs/go-cid"
 	node "github.com/ipfs/go-ipld-format"
 
 	"github.com/ethereum/go-ethereum/common"
+	hexutil "github.com/ethereum/go-ethereum/common/hexutil"
 	types "github.com/ethereum/go-ethereum/core/types"
+	crypto "github.com/ethereum/go-ethereum/crypto"
 	rlp "github.com/ethereum/go-ethereum/rlp"
 )
 
 // EthBlock (eth-block, codec 0x90), represents an ethereum block header
 type EthBlock struct {
 	*types.Header
+	*EthHeaderExt
 
 	cid     *cid.Cid
 	rawdata []byte
+
+	// alias is the cid of the hash the JSON input gave
+	// the block with, when it did not match its header.
+	alias *cid.Cid
+}
+
+// EthHeaderExt holds the optional header fields appended by the
+// hard forks since London, in their RLP order. A nil field means
+// it is absent, and so are all the ones after it.
+type EthHeaderExt struct {
+	BaseFee          *big.Int     // London
+	WithdrawalsHash  *common.Hash // Shanghai
+	BlobGasUsed      *uint64      // Cancun
+	ExcessBlobGas    *uint64      // Cancun
+	ParentBeaconRoot *common.Hash // Cancun
+	RequestsHash     *common.Hash // Prague
+}
+
+// BlockNodes holds the IPLD nodes of a block: its header, and the
+// nodes of its body when it was given one.
+type BlockNodes struct {
+	Block               *EthBlock
+	Txs                 []*EthTx
+	TxTrieNodes         []*EthTxTrie
+	Uncles              []*EthBlock
+	UncleList           *EthBlockList
+	Withdrawals         []*EthWithdrawal
+	WithdrawalTrieNodes []*EthWithdrawalTrie
+
+	// The receipts of the transactions and their logs, only
+	// set by the readers of the sources carrying them, see
+	// Era1Reader, RPCIngester and FromRawBlockReceiptsJSON.
+	Receipts         []*EthTxReceipt
+	ReceiptTrieNodes []*EthTxReceiptTrie
+	Logs             []*EthLog
+	LogTrieNodes     []*EthLogTrie
+
+	// TotalDifficulty of the chain up to the block, as era1 archives give it.
+	TotalDifficulty *big.Int
 }
 
 // Static (compile time) check that EthBlock satisfies the node.Node interface.
@@ -33,92 +78,273 @@ var _ node.Node = (*EthBlock)(nil)
 // FromBlockRLP takes an RLP message representing
 // an ethereum block header or body (header, ommers and txs)
 // to return it as a set of IPLD nodes for further processing.
-func FromBlockRLP(r io.Reader) (*EthBlock, []*EthTx, []*EthTxTrie, error) {
+// The ommers are returned as eth-block nodes, along with the
+// eth-block-list holding them. Since Shanghai, bodies also
+// carry withdrawals, returned along with their trie.
+func FromBlockRLP(r io.Reader) (*BlockNodes, error) {
 	// We may want to use this stream several times
 	rawdata, err := ioutil.ReadAll(r)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, err
 	}
 
-	// Let's try to decode the received element as a block body
-	var decodedBlock types.Block
-	err = rlp.Decode(bytes.NewBuffer(rawdata), &decodedBlock)
+	// Let's find out whether the received element is a block body
+	// or just a header: a body starts with a list (its header).
+	content, _, err := rlp.SplitList(rawdata)
 	if err != nil {
-		if err.Error()[:41] != "rlp: expected input list for types.Header" {
-			return nil, nil, nil, err
-		}
+		return nil, err
+	}
+	kind, _, _, err := rlp.Split(content)
+	if err != nil {
+		return nil, err
+	}
 
-		// Maybe it is just a header... (body sans ommers and txs)
-		var decodedHeader types.Header
-		err := rlp.Decode(bytes.NewBuffer(rawdata), &decodedHeader)
+	if kind != rlp.List {
+		// It was a header (body sans ommers and txs)
+		ethBlock, err := DecodeEthBlock(rawdataToCid(MEthBlock, rawdata), rawdata)
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, err
 		}
+		return &BlockNodes{Block: ethBlock}, nil
+	}
 
-		// It was a header
-		return &EthBlock{
-			Header:  &decodedHeader,
-			cid:     rawdataToCid(MEthBlock, rawdata),
-			rawdata: rawdata,
-		}, nil, nil, nil
+	// This is a block body (header + txs + ommers).
+	// We keep the raw elements, as they are the bits we store.
+	var body objRLPBlockBody
+	err = rlp.DecodeBytes(rawdata, &body)
+	if err != nil {
+		return nil, err
 	}
 
-	// This is a block body (header + ommers + txs)
 	// We'll extract the header bits here
-	headerRawData := getRLP(decodedBlock.Header())
-	ethBlock := &EthBlock{
-		Header:  decodedBlock.Header(),
-		cid:     rawdataToCid(MEthBlock, headerRawData),
-		rawdata: headerRawData,
+	ethBlock, err := DecodeEthBlock(rawdataToCid(MEthBlock, body.Header), body.Header)
+	if err != nil {
+		return nil, err
 	}
 
 	// Process the found eth-tx objects
-	ethTxNodes, ethTxTrieNodes, err := processTransactions(decodedBlock.Transactions(),
-		decodedBlock.Header().TxHash[:])
+	var txs []*EthTx
+	for _, rawTx := range body.Transactions {
+		tx, err := txFromBodyRLP(rawTx)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+	bn := &BlockNodes{Block: ethBlock}
+	bn.Txs, bn.TxTrieNodes, err = processTransactions(txs, ethBlock.TxHash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	// Process the found ommers
+	var uncles []*EthBlock
+	for _, rawUncle := range body.Uncles {
+		uncle, err := DecodeEthBlock(rawdataToCid(MEthBlock, rawUncle), rawUncle)
+		if err != nil {
+			return nil, err
+		}
+		uncles = append(uncles, uncle)
+	}
+	bn.Uncles, bn.UncleList, err = processUncles(uncles, ethBlock.UncleHash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	// Process the found withdrawals
+	var withdrawals []*EthWithdrawal
+	for _, rawWithdrawal := range body.Withdrawals() {
+		w, err := DecodeEthWithdrawal(rawdataToCid(MEthWithdrawal, rawWithdrawal), rawWithdrawal)
+		if err != nil {
+			return nil, err
+		}
+		withdrawals = append(withdrawals, w)
+	}
+	bn.Withdrawals, bn.WithdrawalTrieNodes, err = ethBlock.processWithdrawals(withdrawals)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, err
 	}
 
-	return ethBlock, ethTxNodes, ethTxTrieNodes, nil
+	return bn, nil
 }
 
 // FromBlockJSON takes the output of an ethereum client JSON API
 // (i.e. parity or geth) and returns a set of IPLD nodes.
-func FromBlockJSON(r io.Reader) (*EthBlock, []*EthTx, []*EthTxTrie, error) {
+// The JSON API only gives away the hashes of the ommers, so their
+// nodes are returned only when the block has none, or when the
+// "uncles" field carries the full headers instead. Otherwise, see FromUnclesJSON.
+// Withdrawals are returned along with their trie, as in FromBlockRLP.
+//
+// The header is encoded again from the fields given, so its hash must match
+// the "hash" field of the input, when there is one. Otherwise the client gave
+// fields we don't know of, or left some out, and the cid of the block would
+// not be the one its children link to. See FromBlockJSONLenient.
+func FromBlockJSON(r io.Reader) (*BlockNodes, error) {
+	return fromBlockJSON(r, false)
+}
+
+// FromBlockJSONLenient is FromBlockJSON, accepting a header whose hash does
+// not match the "hash" field of the input. That hash is kept as an alias of
+// the block, see EthBlock.Alias.
+func FromBlockJSONLenient(r io.Reader) (*BlockNodes, error) {
+	return fromBlockJSON(r, true)
+}
+
+// fromBlockJSON implements FromBlockJSON and FromBlockJSONLenient.
+func fromBlockJSON(r io.Reader, lenient bool) (*BlockNodes, error) {
 	var obj objJSONBlock
 	dec := json.NewDecoder(r)
 	err := dec.Decode(&obj)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, err
 	}
 
-	headerRawData := getRLP(obj.Result.Header)
-	ethBlock := &EthBlock{
-		Header:  &obj.Result.Header,
-		cid:     rawdataToCid(MEthBlock, headerRawData),
-		rawdata: headerRawData,
+	ethBlock := newEthBlock(&obj.Result.Header, obj.Result.headerExt)
+	err = obj.Result.given.check(ethBlock, lenient)
+	if err != nil {
+		return nil, err
 	}
 
 	// Process the found eth-tx objects
-	ethTxNodes, ethTxTrieNodes, err := processTransactions(obj.Result.Transactions,
-		obj.Result.Header.TxHash[:])
+	var txs []*EthTx
+	for _, rawTx := range obj.Result.Transactions {
+		tx, err := txFromJSON(rawTx)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+	bn := &BlockNodes{Block: ethBlock}
+	bn.Txs, bn.TxTrieNodes, err = processTransactions(txs, obj.Result.Header.TxHash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	// Process the found withdrawals
+	var withdrawals []*EthWithdrawal
+	for _, rawWithdrawal := range obj.Result.Withdrawals {
+		w, err := withdrawalFromJSON(rawWithdrawal)
+		if err != nil {
+			return nil, err
+		}
+		withdrawals = append(withdrawals, w)
+	}
+	bn.Withdrawals, bn.WithdrawalTrieNodes, err = ethBlock.processWithdrawals(withdrawals)
+	if err != nil {
+		return nil, err
+	}
+
+	// Process the found ommers, if we were given their headers
+	var uncles []*EthBlock
+	for _, o := range obj.Result.Ommers {
+		if len(o) == 0 || o[0] != '{' {
+			// Just a hash, can't do anything about this list
+			return bn, nil
+		}
+
+		uncle, err := uncleFromJSON(o)
+		if err != nil {
+			return nil, err
+		}
+		uncles = append(uncles, uncle)
+	}
+	bn.Uncles, bn.UncleList, err = processUncles(uncles, obj.Result.Header.UncleHash[:])
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, err
 	}
 
-	return ethBlock, ethTxNodes, ethTxTrieNodes, nil
+	return bn, nil
+}
+
+// FromUnclesJSON takes the outputs of the JSON API method
+// "eth_getUncleByBlockHashAndIndex" for every ommer of the given block,
+// in index order, and returns the uncle eth-block nodes along with
+// the eth-block-list holding them.
+func FromUnclesJSON(b *EthBlock, rs []io.Reader) ([]*EthBlock, *EthBlockList, error) {
+	var uncles []*EthBlock
+
+	for _, r := range rs {
+		var obj struct {
+			Result json.RawMessage `json:"result"`
+		}
+		dec := json.NewDecoder(r)
+		err := dec.Decode(&obj)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		uncle, err := uncleFromJSON(obj.Result)
+		if err != nil {
+			return nil, nil, err
+		}
+		uncles = append(uncles, uncle)
+	}
+
+	return processUncles(uncles, b.UncleHash[:])
+}
+
+// FromRawBlockJSON takes the output of the JSON API methods "debug_getRawHeader"
+// or "debug_getRawBlock", the consensus RLP of a block header or body in hex,
+// and returns the same IPLD nodes as FromBlockRLP. Unlike FromBlockJSON, the
+// header is kept as the client encoded it, instead of being encoded again.
+func FromRawBlockJSON(r io.Reader) (*BlockNodes, error) {
+	var rawdata hexutil.Bytes
+	if err := decodeRawJSON(r, &rawdata); err != nil {
+		return nil, err
+	}
+
+	return FromBlockRLP(bytes.NewReader(rawdata))
+}
+
+// uncleFromJSON takes the JSON representation of an ommer header
+// and returns it as an eth-block node.
+func uncleFromJSON(input []byte) (*EthBlock, error) {
+	var h types.Header
+	err := h.UnmarshalJSON(input)
+	if err != nil {
+		return nil, err
+	}
+
+	ext, err := headerExtFromJSON(input)
+	if err != nil {
+		return nil, err
+	}
+
+	given, err := givenHashFromJSON(input)
+	if err != nil {
+		return nil, err
+	}
+
+	// The ommers are in the uncle list by their encoding,
+	// an alias would be of no use.
+	uncle := newEthBlock(&h, ext)
+	err = given.check(uncle, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return uncle, nil
+}
+
+// newEthBlock encodes the given header, along with its optional
+// fields, returning it as an eth-block node.
+func newEthBlock(h *types.Header, ext *EthHeaderExt) *EthBlock {
+	rawdata := encodeHeader(h, ext)
+	return &EthBlock{
+		Header:       h,
+		EthHeaderExt: ext,
+		cid:          rawdataToCid(MEthBlock, rawdata),
+		rawdata:      rawdata,
+	}
 }
 
 // processTransactions will take the found transactions in a parsed block body
 // to return IPLD node slices for eth-tx and eth-tx-trie
-func processTransactions(txs []*types.Transaction, expectedTxRoot []byte) ([]*EthTx, []*EthTxTrie, error) {
-	var ethTxNodes []*EthTx
+func processTransactions(txs []*EthTx, expectedTxRoot []byte) ([]*EthTx, []*EthTxTrie, error) {
 	transactionTrie := newTxTrie()
 
 	for idx, tx := range txs {
-		ethTx := NewTx(tx) // Will panic if it finds an error while parsing a tx
-		ethTxNodes = append(ethTxNodes, ethTx)
-		transactionTrie.add(idx, ethTx.RawData())
+		transactionTrie.add(idx, tx.RawData())
 	}
 
 	if !bytes.Equal(transactionTrie.rootHash(), expectedTxRoot) {
@@ -127,7 +353,36 @@ func processTransactions(txs []*types.Transaction, expectedTxRoot []byte) ([]*Et
 
 	ethTxTrieNodes := transactionTrie.getNodes()
 
-	return ethTxNodes, ethTxTrieNodes, nil
+	return txs, ethTxTrieNodes, nil
+}
+
+// processWithdrawals checks the withdrawals found in the block body against
+// the header. Headers predating Shanghai can't commit to any of them.
+func (b *EthBlock) processWithdrawals(ws []*EthWithdrawal) ([]*EthWithdrawal, []*EthWithdrawalTrie, error) {
+	if b.EthHeaderExt == nil || b.WithdrawalsHash == nil {
+		if len(ws) != 0 {
+			return nil, nil, fmt.Errorf("unexpected withdrawals in a block without withdrawals root")
+		}
+		return nil, nil, nil
+	}
+
+	return processWithdrawals(ws, b.WithdrawalsHash[:])
+}
+
+// txFromBodyRLP takes a transaction, as found in a block body, to return
+// its eth-tx node. Typed transactions are wrapped into an RLP string there.
+func txFromBodyRLP(raw rlp.RawValue) (*EthTx, error) {
+	kind, content, _, err := rlp.Split(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	rawdata := []byte(raw)
+	if kind != rlp.List {
+		rawdata = content
+	}
+
+	return DecodeEthTx(rawdataToCid(MEthTx, rawdata), rawdata)
 }
 
 /*
@@ -137,19 +392,48 @@ func processTransactions(txs []*types.Transaction, expectedTxRoot []byte) ([]*Et
 // DecodeEthBlock takes a cid and its raw binary data
 // from IPFS and returns an EthBlock object for further processing.
 func DecodeEthBlock(c *cid.Cid, b []byte) (*EthBlock, error) {
-	var h types.Header
-	err := rlp.Decode(bytes.NewReader(b), &h)
+	h, ext, err := decodeHeader(b)
 	if err != nil {
 		return nil, err
 	}
 
 	return &EthBlock{
-		Header:  &h,
-		cid:     c,
-		rawdata: b,
+		Header:       h,
+		EthHeaderExt: ext,
+		cid:          c,
+		rawdata:      b,
 	}, nil
 }
 
+// decodeHeader parses the RLP of a block header. Its first fields are
+// the ones of types.Header, and the rest go to the EthHeaderExt.
+func decodeHeader(b []byte) (*types.Header, *EthHeaderExt, error) {
+	var raws []rlp.RawValue
+	err := rlp.DecodeBytes(b, &raws)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	n := len(raws)
+	if n > legacyHeaderFields {
+		n = legacyHeaderFields
+	}
+
+	var h types.Header
+	err = rlp.DecodeBytes(getRLP(raws[:n]), &h)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ext := &EthHeaderExt{}
+	err = ext.decodeRLP(raws[n:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &h, ext, nil
+}
+
 /*
   Block INTERFACE
 */
@@ -164,6 +448,13 @@ func (b *EthBlock) Cid() *cid.Cid {
 	return b.cid
 }
 
+// Alias returns the cid of the hash the block was given with by
+// FromBlockJSONLenient, when it does not match the header. It is the
+// one its children link to. Nil for any other block.
+func (b *EthBlock) Alias() *cid.Cid {
+	return b.alias
+}
+
 // String is a helper for output
 func (b *EthBlock) String() string {
 	return fmt.Sprintf("<EthBlock %s>", b.cid)
@@ -200,6 +491,10 @@ func (b *EthBlock) Resolve(p []string) (interface{}, []string, error) {
 		return &node.Link{Cid: commonHashToCid(MEthTxTrie, b.TxHash)}, rest, nil
 	case "uncles":
 		return &node.Link{Cid: commonHashToCid(MEthBlockList, b.UncleHash)}, rest, nil
+	case "withdrawalsRoot":
+		if b.EthHeaderExt != nil && b.WithdrawalsHash != nil {
+			return &node.Link{Cid: commonHashToCid(MEthWithdrawalTrie, *b.WithdrawalsHash)}, rest, nil
+		}
 	}
 
 	if len(p) != 1 {
@@ -228,9 +523,13 @@ func (b *EthBlock) Resolve(p []string) (interface{}, []string, error) {
 		return b.Number, nil, nil
 	case "time":
 		return b.Time, nil, nil
-	default:
-		return nil, nil, fmt.Errorf("no such link")
 	}
+
+	if v, ok := b.EthHeaderExt.fields()[first]; ok {
+		return v, nil, nil
+	}
+
+	return nil, nil, fmt.Errorf("no such link")
 }
 
 // Tree lists all paths within the object under 'path', and up to the given depth.
@@ -240,7 +539,7 @@ func (b *EthBlock) Tree(p string, depth int) []string {
 		return nil
 	}
 
-	return []string{
+	out := []string{
 		"time",
 		"bloom",
 		"coinbase",
@@ -257,6 +556,13 @@ func (b *EthBlock) Tree(p string, depth int) []string {
 		"tx",
 		"uncles",
 	}
+	for _, k := range headerExtPaths {
+		if _, ok := b.EthHeaderExt.fields()[k]; ok {
+			out = append(out, k)
+		}
+	}
+
+	return out
 }
 
 // ResolveLink is a helper function that allows easier traversal of links through blocks
@@ -281,13 +587,17 @@ func (b *EthBlock) Copy() node.Node {
 // Links is a helper function that returns all links within this object
 // HINT: Use `ipfs refs <cid>`
 func (b *EthBlock) Links() []*node.Link {
-	return []*node.Link{
+	out := []*node.Link{
 		&node.Link{Cid: commonHashToCid(MEthBlock, b.ParentHash)},
 		&node.Link{Cid: commonHashToCid(MEthTxReceiptTrie, b.ReceiptHash)},
 		&node.Link{Cid: commonHashToCid(MEthStateTrie, b.Root)},
 		&node.Link{Cid: commonHashToCid(MEthTxTrie, b.TxHash)},
 		&node.Link{Cid: commonHashToCid(MEthBlockList, b.UncleHash)},
 	}
+	if b.EthHeaderExt != nil && b.WithdrawalsHash != nil {
+		out = append(out, &node.Link{Cid: commonHashToCid(MEthWithdrawalTrie, *b.WithdrawalsHash)})
+	}
+	return out
 }
 
 // Stat will go away. It is here to comply with the Node interface.
@@ -304,6 +614,12 @@ func (b *EthBlock) Size() (uint64, error) {
   EthBlock functions
 */
 
+// Hash returns the block hash, this is, the keccak256 of the header RLP,
+// which takes into account the fields added after types.Header.
+func (b *EthBlock) Hash() common.Hash {
+	return crypto.Keccak256Hash(b.rawdata)
+}
+
 // MarshalJSON processes the block header into readable JSON format,
 // converting the right links into their cids, and keeping the original
 // hex hash, allowing the user to simplify external queries.
@@ -325,9 +641,55 @@ func (b *EthBlock) MarshalJSON() ([]byte, error) {
 		"tx":         commonHashToCid(MEthTxTrie, b.TxHash),
 		"uncles":     commonHashToCid(MEthBlockList, b.UncleHash),
 	}
+	for k, v := range b.EthHeaderExt.fields() {
+		out[k] = v
+	}
+	if b.EthHeaderExt != nil && b.WithdrawalsHash != nil {
+		out["withdrawalsRoot"] = commonHashToCid(MEthWithdrawalTrie, *b.WithdrawalsHash)
+	}
 	return json.Marshal(out)
 }
 
+// objRLPBlockBody is the RLP layout of a block body. Its elements are
+// kept raw, as they are stored byte for byte as the IPLD nodes.
+// Since Shanghai, the list of withdrawals comes after the ommers.
+type objRLPBlockBody struct {
+	Header       rlp.RawValue
+	Transactions []rlp.RawValue
+	Uncles       []rlp.RawValue
+	Rest         [][]rlp.RawValue `rlp:"tail"`
+}
+
+// Withdrawals returns the raw withdrawals of the block body, if any.
+func (o *objRLPBlockBody) Withdrawals() []rlp.RawValue {
+	if len(o.Rest) == 0 {
+		return nil
+	}
+	return o.Rest[0]
+}
+
+// decodeRawJSON decodes the result of a response of the JSON API into v,
+// failing with the error of the response, if it has one.
+func decodeRawJSON(r io.Reader, v interface{}) error {
+	var obj struct {
+		Result json.RawMessage `json:"result"`
+		Error  *rpcError       `json:"error"`
+	}
+	dec := json.NewDecoder(r)
+	err := dec.Decode(&obj)
+	if err != nil {
+		return err
+	}
+
+	if obj.Error != nil {
+		return obj.Error
+	}
+	if len(obj.Result) == 0 || string(obj.Result) == "null" {
+		return fmt.Errorf("no result in the JSON API response")
+	}
+	return json.Unmarshal(obj.Result, v)
+}
+
 // objJSONBlock defines the output of the JSON RPC API for either
 // "eth_BlockByHash" or "eth_BlockByHeader".
 type objJSONBlock struct {
@@ -339,26 +701,303 @@ type objJSONBlock struct {
 type objJSONBlockResult struct {
 	types.Header           // Use its fields and unmarshaler
 	*objJSONBlockResultExt // Add these fields to the parsing
+
+	headerExt *EthHeaderExt
+	given     *givenHash
 }
 
 // objJSONBLockResultExt facilitates the composition
 // of the field "result", adding to the
-// `types.Header` fields, both ommers (their hashes) and transactions.
+// `types.Header` fields, ommers, transactions and withdrawals.
+// Ommers are usually given as hashes, but we take their headers too.
 type objJSONBlockResultExt struct {
-	OmmerHashes  []common.Hash        `json:"uncles"`
-	Transactions []*types.Transaction `json:"transactions"`
+	Ommers       []json.RawMessage `json:"uncles"`
+	Transactions []json.RawMessage `json:"transactions"`
+	Withdrawals  []json.RawMessage `json:"withdrawals"`
 }
 
 // UnmarshalJSON overrides the function types.Header.UnmarshalJSON, allowing us
 // to parse the fields of Header, plus ommer hashes and transactions.
-// (yes, ommer hashes. You will need to "eth_getUncleCountByBlockHash" per each ommer)
+// (yes, ommer hashes. You will need to "eth_getUncleByBlockHashAndIndex" per each ommer,
+// see FromUnclesJSON)
 func (o *objJSONBlockResult) UnmarshalJSON(input []byte) error {
 	err := o.Header.UnmarshalJSON(input)
 	if err != nil {
 		return err
 	}
 
+	o.headerExt, err = headerExtFromJSON(input)
+	if err != nil {
+		return err
+	}
+
+	o.given, err = givenHashFromJSON(input)
+	if err != nil {
+		return err
+	}
+
 	o.objJSONBlockResultExt = &objJSONBlockResultExt{}
 	err = json.Unmarshal(input, o.objJSONBlockResultExt)
 	return err
 }
+
+/*
+  Post-London header fields
+*/
+
+// legacyHeaderFields is the number of fields of types.Header,
+// the ones every block header has.
+const legacyHeaderFields = 15
+
+// headerExtPaths are the paths of the optional header fields, in RLP order.
+var headerExtPaths = []string{
+	"baseFeePerGas",
+	"withdrawalsRoot",
+	"blobGasUsed",
+	"excessBlobGas",
+	"parentBeaconBlockRoot",
+	"requestsHash",
+}
+
+// rlpFields returns the optional fields present in the header,
+// in their RLP order, stopping at the first absent one.
+func (ext *EthHeaderExt) rlpFields() []interface{} {
+	var out []interface{}
+	if ext == nil || ext.BaseFee == nil {
+		return out
+	}
+	out = append(out, ext.BaseFee)
+	if ext.WithdrawalsHash == nil {
+		return out
+	}
+	out = append(out, ext.WithdrawalsHash)
+	if ext.BlobGasUsed == nil {
+		return out
+	}
+	out = append(out, ext.BlobGasUsed)
+	if ext.ExcessBlobGas == nil {
+		return out
+	}
+	out = append(out, ext.ExcessBlobGas)
+	if ext.ParentBeaconRoot == nil {
+		return out
+	}
+	out = append(out, ext.ParentBeaconRoot)
+	if ext.RequestsHash == nil {
+		return out
+	}
+	return append(out, ext.RequestsHash)
+}
+
+// decodeRLP parses the raw optional fields found after the
+// ones of types.Header.
+func (ext *EthHeaderExt) decodeRLP(raws []rlp.RawValue) error {
+	targets := []interface{}{
+		&ext.BaseFee,
+		&ext.WithdrawalsHash,
+		&ext.BlobGasUsed,
+		&ext.ExcessBlobGas,
+		&ext.ParentBeaconRoot,
+		&ext.RequestsHash,
+	}
+	if len(raws) > len(targets) {
+		return fmt.Errorf("unexpected header fields past %s", headerExtPaths[len(targets)-1])
+	}
+
+	for i, raw := range raws {
+		err := rlp.DecodeBytes(raw, targets[i])
+		if err != nil {
+			return fmt.Errorf("invalid header field %s: %v", headerExtPaths[i], err)
+		}
+	}
+	return nil
+}
+
+// fields returns the readable optional fields present in the header,
+// but for withdrawalsRoot, which is a link.
+func (ext *EthHeaderExt) fields() map[string]interface{} {
+	out := map[string]interface{}{}
+	for i, f := range ext.rlpFields() {
+		switch v := f.(type) {
+		case *common.Hash:
+			out[headerExtPaths[i]] = *v
+		case *uint64:
+			out[headerExtPaths[i]] = *v
+		default:
+			out[headerExtPaths[i]] = v
+		}
+	}
+	delete(out, "withdrawalsRoot")
+	return out
+}
+
+// encodeHeader returns the RLP of a block header, appending
+// its optional fields to the ones of types.Header.
+func encodeHeader(h *types.Header, ext *EthHeaderExt) []byte {
+	extra := ext.rlpFields()
+	if len(extra) == 0 {
+		return getRLP(h)
+	}
+
+	var raws []rlp.RawValue
+	err := rlp.DecodeBytes(getRLP(h), &raws)
+	if err != nil {
+		panic(err)
+	}
+	for _, f := range extra {
+		raws = append(raws, getRLP(f))
+	}
+
+	return getRLP(raws)
+}
+
+// headerExtJSON is the JSON representation of the optional header fields.
+type headerExtJSON struct {
+	BaseFee          *hexutil.Big    `json:"baseFeePerGas"`
+	WithdrawalsHash  *common.Hash    `json:"withdrawalsRoot"`
+	BlobGasUsed      *hexutil.Uint64 `json:"blobGasUsed"`
+	ExcessBlobGas    *hexutil.Uint64 `json:"excessBlobGas"`
+	ParentBeaconRoot *common.Hash    `json:"parentBeaconBlockRoot"`
+	RequestsHash     *common.Hash    `json:"requestsHash"`
+}
+
+// headerExtFromJSON takes the JSON representation of a block header
+// and returns the optional fields found in it.
+func headerExtFromJSON(input []byte) (*EthHeaderExt, error) {
+	var dec headerExtJSON
+	err := json.Unmarshal(input, &dec)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := &EthHeaderExt{
+		WithdrawalsHash:  dec.WithdrawalsHash,
+		ParentBeaconRoot: dec.ParentBeaconRoot,
+		RequestsHash:     dec.RequestsHash,
+	}
+	if dec.BaseFee != nil {
+		ext.BaseFee = dec.BaseFee.ToInt()
+	}
+	if dec.BlobGasUsed != nil {
+		v := uint64(*dec.BlobGasUsed)
+		ext.BlobGasUsed = &v
+	}
+	if dec.ExcessBlobGas != nil {
+		v := uint64(*dec.ExcessBlobGas)
+		ext.ExcessBlobGas = &v
+	}
+
+	// As they are encoded in order, a field can't be given
+	// without the ones before it.
+	present := []bool{
+		ext.BaseFee != nil,
+		ext.WithdrawalsHash != nil,
+		ext.BlobGasUsed != nil,
+		ext.ExcessBlobGas != nil,
+		ext.ParentBeaconRoot != nil,
+		ext.RequestsHash != nil,
+	}
+	for i := len(ext.rlpFields()); i < len(present); i++ {
+		if present[i] {
+			return nil, fmt.Errorf("header field %s given without %s", headerExtPaths[i], headerExtPaths[len(ext.rlpFields())])
+		}
+	}
+
+	return ext, nil
+}
+
+/*
+  Hash of the JSON input
+*/
+
+// legacyHeaderPaths are the JSON fields of types.Header.
+var legacyHeaderPaths = []string{
+	"parentHash",
+	"sha3Uncles",
+	"miner",
+	"stateRoot",
+	"transactionsRoot",
+	"receiptsRoot",
+	"logsBloom",
+	"difficulty",
+	"number",
+	"gasLimit",
+	"gasUsed",
+	"timestamp",
+	"extraData",
+	"mixHash",
+	"nonce",
+}
+
+// blockInfoPaths are the JSON fields the clients give
+// along with the header, which are not part of it.
+var blockInfoPaths = []string{
+	"hash",
+	"size",
+	"totalDifficulty",
+	"transactions",
+	"uncles",
+	"withdrawals",
+	"author",     // parity, the miner
+	"sealFields", // parity, the mixHash and nonce
+}
+
+// givenHash holds the "hash" field of the JSON representation of a
+// header, and the fields of it we don't know of, to tell what the
+// header is likely missing when the hash does not match.
+type givenHash struct {
+	hash    *common.Hash
+	unknown []string
+}
+
+// givenHashFromJSON takes the JSON representation of a block header
+// and returns its "hash" field, if any.
+func givenHashFromJSON(input []byte) (*givenHash, error) {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(input, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	given := &givenHash{}
+	if raw, ok := fields["hash"]; ok && string(raw) != "null" {
+		given.hash = new(common.Hash)
+		err = json.Unmarshal(raw, given.hash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid block hash: %v", err)
+		}
+	}
+
+	for _, paths := range [][]string{legacyHeaderPaths, headerExtPaths, blockInfoPaths} {
+		for _, k := range paths {
+			delete(fields, k)
+		}
+	}
+	for k := range fields {
+		given.unknown = append(given.unknown, k)
+	}
+	sort.Strings(given.unknown)
+
+	return given, nil
+}
+
+// check compares the hash of the block with the given one. A mismatch is
+// an error, naming the fields of the input the header is likely missing,
+// unless lenient, where the given hash is kept as an alias of the block.
+func (given *givenHash) check(b *EthBlock, lenient bool) error {
+	if given == nil || given.hash == nil || *given.hash == b.Hash() {
+		return nil
+	}
+
+	if lenient {
+		b.alias = commonHashToCid(MEthBlock, *given.hash)
+		return nil
+	}
+
+	if len(given.unknown) == 0 {
+		return fmt.Errorf("wrong block hash computed, expected %s, got %s: the input may lack header fields",
+			given.hash.Hex(), b.Hash().Hex())
+	}
+	return fmt.Errorf("wrong block hash computed, expected %s, got %s: the header is likely missing the fields %s",
+		given.hash.Hex(), b.Hash().Hex(), strings.Join(given.unknown, ", "))
+}
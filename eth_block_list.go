@@ -0,0 +1,200 @@
+package ipldeth
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	cid "github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// EthBlockList (eth-block-list, codec 0x91), represents a list of
+// ethereum block headers, i.e. the ommers (uncles) of a block.
+type EthBlockList struct {
+	uncles []*EthBlock
+
+	cid     *cid.Cid
+	rawdata []byte
+}
+
+// Static (compile time) check that EthBlockList satisfies the node.Node interface.
+var _ node.Node = (*EthBlockList)(nil)
+
+/*
+  INPUT
+*/
+
+// The ommers list is parsed along with the block body holding it.
+// See FromBlockRLP, FromBlockJSON and FromUnclesJSON.
+
+// newBlockList takes the already parsed uncle headers of a block and
+// returns the eth-block-list node of them. Its RLP is the list of the
+// uncles RLP, so its hash is the one in the header's UncleHash.
+func newBlockList(uncles []*EthBlock) *EthBlockList {
+	raws := make([]rlp.RawValue, 0, len(uncles))
+	for _, u := range uncles {
+		raws = append(raws, u.RawData())
+	}
+	rawdata := getRLP(raws)
+
+	return &EthBlockList{
+		uncles:  uncles,
+		cid:     rawdataToCid(MEthBlockList, rawdata),
+		rawdata: rawdata,
+	}
+}
+
+// processUncles will take the found uncle headers of a block body to return
+// the slice of eth-block nodes and the eth-block-list holding them.
+func processUncles(uncles []*EthBlock, expectedUncleHash []byte) ([]*EthBlock, *EthBlockList, error) {
+	uncleList := newBlockList(uncles)
+
+	if !uncleList.Cid().Equals(keccak256ToCid(MEthBlockList, expectedUncleHash)) {
+		return nil, nil, fmt.Errorf("wrong uncle hash computed")
+	}
+
+	return uncles, uncleList, nil
+}
+
+/*
+  OUTPUT
+*/
+
+// DecodeEthBlockList takes a cid and its raw binary data
+// from IPFS and returns an EthBlockList object for further processing.
+func DecodeEthBlockList(c *cid.Cid, b []byte) (*EthBlockList, error) {
+	var raws []rlp.RawValue
+	err := rlp.DecodeBytes(b, &raws)
+	if err != nil {
+		return nil, err
+	}
+
+	var uncles []*EthBlock
+	for _, raw := range raws {
+		uncle, err := DecodeEthBlock(rawdataToCid(MEthBlock, raw), raw)
+		if err != nil {
+			return nil, err
+		}
+		uncles = append(uncles, uncle)
+	}
+
+	return &EthBlockList{
+		uncles:  uncles,
+		cid:     c,
+		rawdata: b,
+	}, nil
+}
+
+/*
+  Block INTERFACE
+*/
+
+// RawData returns the binary of the RLP encode of the block list.
+func (bl *EthBlockList) RawData() []byte {
+	return bl.rawdata
+}
+
+// Cid returns the cid of the block list.
+func (bl *EthBlockList) Cid() *cid.Cid {
+	return bl.cid
+}
+
+// String is a helper for output
+func (bl *EthBlockList) String() string {
+	return fmt.Sprintf("<EthBlockList %s>", bl.cid)
+}
+
+// Loggable returns a map the type of IPLD Link.
+func (bl *EthBlockList) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "eth-block-list",
+	}
+}
+
+/*
+  Node INTERFACE
+*/
+
+// Resolve resolves a path through this node, stopping at any link boundary
+// and returning the object found as well as the remaining path to traverse
+func (bl *EthBlockList) Resolve(p []string) (interface{}, []string, error) {
+	if len(p) == 0 {
+		return bl, nil, nil
+	}
+
+	idx, err := strconv.Atoi(p[0])
+	if err != nil || idx < 0 || idx >= len(bl.uncles) {
+		return nil, nil, fmt.Errorf("no such link")
+	}
+
+	return &node.Link{Cid: bl.uncles[idx].Cid()}, p[1:], nil
+}
+
+// Tree lists all paths within the object under 'path', and up to the given depth.
+// To list the entire object (similar to `find .`) pass "" and -1
+func (bl *EthBlockList) Tree(p string, depth int) []string {
+	if p != "" || depth == 0 {
+		return nil
+	}
+
+	var out []string
+	for i := range bl.uncles {
+		out = append(out, strconv.Itoa(i))
+	}
+	return out
+}
+
+// ResolveLink is a helper function that allows easier traversal of links through blocks
+func (bl *EthBlockList) ResolveLink(p []string) (*node.Link, []string, error) {
+	obj, rest, err := bl.Resolve(p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if lnk, ok := obj.(*node.Link); ok {
+		return lnk, rest, nil
+	}
+
+	return nil, nil, fmt.Errorf("resolved item was not a link")
+}
+
+// Copy will go away. It is here to comply with the Node interface.
+func (bl *EthBlockList) Copy() node.Node {
+	panic("dont use this yet")
+}
+
+// Links is a helper function that returns all links within this object
+func (bl *EthBlockList) Links() []*node.Link {
+	var out []*node.Link
+	for _, u := range bl.uncles {
+		out = append(out, &node.Link{Cid: u.Cid()})
+	}
+	return out
+}
+
+// Stat will go away. It is here to comply with the Node interface.
+func (bl *EthBlockList) Stat() (*node.NodeStat, error) {
+	return &node.NodeStat{}, nil
+}
+
+// Size returns the size in bytes of the serialized object
+func (bl *EthBlockList) Size() (uint64, error) {
+	return uint64(len(bl.rawdata)), nil
+}
+
+/*
+  EthBlockList functions
+*/
+
+// MarshalJSON processes the block list into readable JSON format,
+// an array with the cids of the listed block headers.
+func (bl *EthBlockList) MarshalJSON() ([]byte, error) {
+	out := make([]*cid.Cid, 0, len(bl.uncles))
+	for _, u := range bl.uncles {
+		out = append(out, u.Cid())
+	}
+	return json.Marshal(out)
+}
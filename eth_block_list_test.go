@@ -0,0 +1,285 @@
+package ipldeth
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	block "github.com/ipfs/go-block-format"
+	node "github.com/ipfs/go-ipld-format"
+)
+
+/*
+  INPUT
+*/
+
+func TestBlockListInBlockBodyRlpParsing(t *testing.T) {
+	fi, err := os.Open("test_data/eth-block-body-rlp-997522")
+	checkError(err, t)
+
+	bn, err := FromBlockRLP(fi)
+	checkError(err, t)
+
+	if len(bn.Uncles) != 2 {
+		t.Fatal("Wrong number of parsed uncles")
+	}
+	testUncles997522(bn.Block, bn.Uncles, bn.UncleList, t)
+}
+
+func TestBlockListInBlockHeaderRlpParsing(t *testing.T) {
+	fi, err := os.Open("test_data/eth-block-header-rlp-999999")
+	checkError(err, t)
+
+	bn, err := FromBlockRLP(fi)
+	checkError(err, t)
+
+	if bn.Uncles != nil || bn.UncleList != nil {
+		t.Fatal("No uncles should have been gotten from here")
+	}
+}
+
+func TestEmptyBlockListInBlockBodyJsonParsing(t *testing.T) {
+	fi, err := os.Open("test_data/eth-block-body-json-999999")
+	checkError(err, t)
+
+	bn, err := FromBlockJSON(fi)
+	checkError(err, t)
+
+	if len(bn.Uncles) != 0 {
+		t.Fatal("Wrong number of parsed uncles")
+	}
+
+	// Even an empty list should be linked from the block
+	lnk, _, err := bn.Block.ResolveLink([]string{"uncles"})
+	checkError(err, t)
+	if !lnk.Cid.Equals(bn.UncleList.Cid()) {
+		t.Fatal("Wrong cid for the empty uncle list")
+	}
+	if string(bn.UncleList.RawData()) != "\xc0" {
+		t.Fatal("Wrong rawdata for the empty uncle list")
+	}
+}
+
+func TestBlockListFromUnclesJSON(t *testing.T) {
+	fi, err := os.Open("test_data/eth-block-body-json-997522")
+	checkError(err, t)
+
+	// The JSON API only gave us the hashes of the uncles
+	bn, err := FromBlockJSON(fi)
+	checkError(err, t)
+	if bn.Uncles != nil || bn.UncleList != nil {
+		t.Fatal("No uncles should have been gotten from here")
+	}
+
+	var rs []io.Reader
+	for _, fn := range []string{
+		"test_data/eth-uncle-json-997522-0",
+		"test_data/eth-uncle-json-997522-1",
+	} {
+		fu, err := os.Open(fn)
+		checkError(err, t)
+		rs = append(rs, fu)
+	}
+
+	bn.Uncles, bn.UncleList, err = FromUnclesJSON(bn.Block, rs)
+	checkError(err, t)
+
+	testUncles997522(bn.Block, bn.Uncles, bn.UncleList, t)
+}
+
+func TestBlockListFromUnclesJSONWrongUncleHash(t *testing.T) {
+	ethBlock := prepareDecodedEthBlock("test_data/eth-block-header-rlp-999999", t)
+
+	fu, err := os.Open("test_data/eth-uncle-json-997522-0")
+	checkError(err, t)
+
+	_, _, err = FromUnclesJSON(ethBlock, []io.Reader{fu})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if err.Error() != "wrong uncle hash computed" {
+		t.Fatal("Wrong error")
+	}
+}
+
+func TestBlockListFromUnclesJSONWrongHash(t *testing.T) {
+	fi, err := os.Open("test_data/eth-block-body-json-997522")
+	checkError(err, t)
+
+	bn, err := FromBlockJSON(fi)
+	checkError(err, t)
+
+	r := prepareEditedBlockJSON("test_data/eth-uncle-json-997522-0", func(result map[string]interface{}) {
+		result["hash"] = "0x0000000000000000000000000000000000000000000000000000000000000001"
+	}, t)
+
+	_, _, err = FromUnclesJSON(bn.Block, []io.Reader{r})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if !strings.HasPrefix(err.Error(), "wrong block hash computed, expected 0x0000000000000000000000000000000000000000000000000000000000000001") {
+		t.Fatalf("Wrong error %v", err)
+	}
+}
+
+/*
+  OUTPUT
+*/
+
+func TestDecodeBlockList(t *testing.T) {
+	uncleList := prepareEthBlockList(t)
+
+	// Just to clarify: This `block` is an IPFS block
+	storedBlockList, err := block.NewBlockWithCid(uncleList.RawData(), uncleList.Cid())
+	checkError(err, t)
+
+	decoded, err := DecodeEthBlockList(storedBlockList.Cid(), storedBlockList.RawData())
+	checkError(err, t)
+
+	links := decoded.Links()
+	if len(links) != 2 {
+		t.Fatal("Wrong number of links")
+	}
+	if links[0].Cid.String() != uncleList.uncles[0].Cid().String() ||
+		links[1].Cid.String() != uncleList.uncles[1].Cid().String() {
+		t.Fatal("Wrong uncle links")
+	}
+}
+
+/*
+  Block INTERFACE
+*/
+
+func TestEthBlockListLoggable(t *testing.T) {
+	uncleList := prepareEthBlockList(t)
+
+	l := uncleList.Loggable()
+	if _, ok := l["type"]; !ok {
+		t.Fatal("Loggable map expected the field 'type'")
+	}
+
+	if l["type"] != "eth-block-list" {
+		t.Fatal("Wrong Loggable 'type' value")
+	}
+}
+
+func TestEthBlockListSize(t *testing.T) {
+	uncleList := prepareEthBlockList(t)
+
+	size, err := uncleList.Size()
+	checkError(err, t)
+	if size != uint64(len(uncleList.RawData())) {
+		t.Fatal("Wrong size")
+	}
+}
+
+/*
+  Node INTERFACE
+*/
+
+func TestEthBlockListResolve(t *testing.T) {
+	uncleList := prepareEthBlockList(t)
+
+	// Empty path
+	obj, rest, err := uncleList.Resolve([]string{})
+	checkError(err, t)
+	if obj.(*EthBlockList) != uncleList {
+		t.Fatal("Should have returned the same eth-block-list object")
+	}
+	if len(rest) != 0 {
+		t.Fatal("Wrong rest of the path returned")
+	}
+
+	// Bad cases
+	for _, bc := range []string{"2", "-1", "a", "uncle"} {
+		_, _, err = uncleList.Resolve([]string{bc})
+		if err == nil || err.Error() != "no such link" {
+			t.Fatalf("Expected 'no such link' error for %s", bc)
+		}
+	}
+
+	// Good cases, the rest of the path is left to the uncle
+	obj, rest, err = uncleList.Resolve([]string{"1", "number"})
+	checkError(err, t)
+
+	lnk, ok := obj.(*node.Link)
+	if !ok {
+		t.Fatal("Returned object is not a link")
+	}
+	if !lnk.Cid.Equals(uncleList.uncles[1].Cid()) {
+		t.Fatal("Wrong link")
+	}
+	if len(rest) != 1 || rest[0] != "number" {
+		t.Fatal("Wrong rest of the path returned")
+	}
+}
+
+func TestEthBlockListTree(t *testing.T) {
+	uncleList := prepareEthBlockList(t)
+
+	if uncleList.Tree("non-empty-string", 1) != nil {
+		t.Fatal("Expected nil to be returned")
+	}
+	if uncleList.Tree("", 0) != nil {
+		t.Fatal("Expected nil to be returned")
+	}
+
+	tree := uncleList.Tree("", 1)
+	if len(tree) != 2 || tree[0] != "0" || tree[1] != "1" {
+		t.Fatal("Wrong tree")
+	}
+}
+
+func TestEthBlockListJSONMarshal(t *testing.T) {
+	uncleList := prepareEthBlockList(t)
+
+	jsonOutput, err := uncleList.MarshalJSON()
+	checkError(err, t)
+
+	var data []interface{}
+	err = json.Unmarshal(jsonOutput, &data)
+	checkError(err, t)
+
+	if len(data) != 2 {
+		t.Fatal("Wrong number of elements")
+	}
+	if parseMapElement(data[0]) != uncleList.uncles[0].Cid().String() {
+		t.Fatal("Wrong uncle cid")
+	}
+}
+
+/*
+  AUXILIARS
+*/
+
+func prepareEthBlockList(t *testing.T) *EthBlockList {
+	fi, err := os.Open("test_data/eth-block-body-rlp-997522")
+	checkError(err, t)
+
+	bn, err := FromBlockRLP(fi)
+	checkError(err, t)
+
+	return bn.UncleList
+}
+
+func testUncles997522(ethBlock *EthBlock, uncles []*EthBlock, uncleList *EthBlockList, t *testing.T) {
+	lnk, _, err := ethBlock.ResolveLink([]string{"uncles"})
+	checkError(err, t)
+	if !lnk.Cid.Equals(uncleList.Cid()) {
+		t.Fatal("Wrong cid for the uncle list")
+	}
+
+	if uncles[0].Cid().String() !=
+		commonHashToCid(MEthBlock, uncles[0].Hash()).String() {
+		t.Fatal("Wrong cid for the first uncle")
+	}
+	if uncles[0].Number.String() != "997519" {
+		t.Fatal("Wrong number for the first uncle")
+	}
+	if uncles[1].Hash().Hex() !=
+		"0x0324272e484e509c3c9e9e75ad8b48c7d34556e6b269dd72331033fd5cdc1b2a" {
+		t.Fatal("Wrong hash for the second uncle")
+	}
+}
@@ -1,17 +1,22 @@
 package ipldeth
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"runtime"
 	"strconv"
+	"strings"
 	"testing"
 
 	block "github.com/ipfs/go-block-format"
 	node "github.com/ipfs/go-ipld-format"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
@@ -19,30 +24,68 @@ func TestBlockBodyRlpParsing(t *testing.T) {
 	fi, err := os.Open("test_data/eth-block-body-rlp-999999")
 	checkError(err, t)
 
-	output, _, _, err := FromBlockRLP(fi)
+	bn, err := FromBlockRLP(fi)
 	checkError(err, t)
 
-	testEthBlockFields(output, t)
+	testEthBlockFields(bn.Block, t)
 }
 
 func TestBlockHeaderRlpParsing(t *testing.T) {
 	fi, err := os.Open("test_data/eth-block-header-rlp-999999")
 	checkError(err, t)
 
-	output, _, _, err := FromBlockRLP(fi)
+	bn, err := FromBlockRLP(fi)
 	checkError(err, t)
 
-	testEthBlockFields(output, t)
+	testEthBlockFields(bn.Block, t)
 }
 
 func TestBlockBodyJsonParsing(t *testing.T) {
 	fi, err := os.Open("test_data/eth-block-body-json-999999")
 	checkError(err, t)
 
-	output, _, _, err := FromBlockJSON(fi)
+	bn, err := FromBlockJSON(fi)
 	checkError(err, t)
 
-	testEthBlockFields(output, t)
+	testEthBlockFields(bn.Block, t)
+}
+
+func TestBlockBodyJsonPublishedHash(t *testing.T) {
+	// Unmodified mainnet eth_getBlockByNumber responses
+	for _, fixture := range []struct {
+		filepath string
+		hash     string
+	}{
+		{"test_data/eth-block-body-json-0", "0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3"},
+		{"test_data/eth-block-body-json-997522", "0x79851e1adb52a8c5490da2df5d8c060b1cc44a3b6eeaada2e20edba5a8e84523"},
+		{"test_data/eth-block-body-json-999998", "0xd33c9dde9fff0ebaa6e71e8b26d2bda15ccf111c7af1b633698ac847667f0fb4"},
+		{"test_data/eth-block-body-json-999999", "0xb4fbadf8ea452b139718e2700dc1135cfc81145031c84b7ab27cd710394f7b38"},
+		{"test_data/eth-block-body-json-4139497", "0x16f37b728aacdb8491eaf8caa84c090285f204d9f6332931144e2fb7fa9c622b"},
+	} {
+		fi, err := os.Open(fixture.filepath)
+		checkError(err, t)
+
+		bn, err := FromBlockJSON(fi)
+		fi.Close()
+		checkError(err, t)
+
+		if !bn.Block.Cid().Equals(commonHashToCid(MEthBlock, common.HexToHash(fixture.hash))) {
+			t.Fatalf("Wrong cid for %s\r\ngot %s", fixture.filepath, bn.Block.Cid())
+		}
+		if bn.Block.Alias() != nil {
+			t.Fatalf("Unexpected alias for %s", fixture.filepath)
+		}
+	}
+
+	// Any change to the header shows up against the published hash
+	r := prepareEditedBlockJSON("test_data/eth-block-body-json-999999", func(result map[string]interface{}) {
+		result["extraData"] = "0x"
+	}, t)
+
+	_, err := FromBlockJSON(r)
+	if err == nil || !strings.HasPrefix(err.Error(), "wrong block hash computed, expected 0xb4fbadf8ea452b139718e2700dc1135cfc81145031c84b7ab27cd710394f7b38") {
+		t.Fatalf("Expected error 'wrong block hash computed'\r\ngot %v", err)
+	}
 }
 
 func TestEthBlockProcessTransactionsError(t *testing.T) {
@@ -50,7 +93,128 @@ func TestEthBlockProcessTransactionsError(t *testing.T) {
 	fi, err := os.Open("test_data/error-tx-eth-block-body-json-999999")
 	checkError(err, t)
 
-	_, _, _, err = FromBlockJSON(fi)
+	_, err = FromBlockJSON(fi)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+}
+
+func TestBlockBodyJsonWrongHash(t *testing.T) {
+	// A client naming a header field we don't know of
+	r := prepareEditedBlockJSON("test_data/eth-block-body-json-999999-prague", func(result map[string]interface{}) {
+		result["requestsRoot"] = result["requestsHash"]
+		delete(result, "requestsHash")
+	}, t)
+
+	_, err := FromBlockJSON(r)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if !strings.HasPrefix(err.Error(), "wrong block hash computed, expected 0xbdafabc985e7995b469e259df9ec5504946f40cee5eb84318372a1e19a5c4d43") ||
+		!strings.HasSuffix(err.Error(), "the header is likely missing the fields requestsRoot") {
+		t.Fatalf("Wrong error %v", err)
+	}
+
+	// A client leaving a header field out
+	r = prepareEditedBlockJSON("test_data/eth-block-body-json-999999-prague", func(result map[string]interface{}) {
+		delete(result, "requestsHash")
+	}, t)
+
+	_, err = FromBlockJSON(r)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if !strings.HasSuffix(err.Error(), "the input may lack header fields") {
+		t.Fatalf("Wrong error %v", err)
+	}
+}
+
+func TestBlockBodyJsonLenient(t *testing.T) {
+	r := prepareEditedBlockJSON("test_data/eth-block-body-json-999999-prague", func(result map[string]interface{}) {
+		delete(result, "requestsHash")
+	}, t)
+
+	bn, err := FromBlockJSONLenient(r)
+	checkError(err, t)
+
+	if bn.Block.Alias() == nil {
+		t.Fatal("Expected an alias")
+	}
+	if !bn.Block.Alias().Equals(commonHashToCid(MEthBlock, common.HexToHash("0xbdafabc985e7995b469e259df9ec5504946f40cee5eb84318372a1e19a5c4d43"))) {
+		t.Fatal("Wrong alias")
+	}
+	if bn.Block.Alias().Equals(bn.Block.Cid()) {
+		t.Fatal("The alias should not be the cid of the header")
+	}
+
+	// Matching hashes need no alias
+	fi, err := os.Open("test_data/eth-block-body-json-999999-prague")
+	checkError(err, t)
+
+	bn, err = FromBlockJSONLenient(fi)
+	checkError(err, t)
+
+	if bn.Block.Alias() != nil {
+		t.Fatal("Unexpected alias")
+	}
+	testEthBlockExtFields(bn.Block, t)
+}
+
+func TestBlockBodyRawJsonParsing(t *testing.T) {
+	rawdata, err := ioutil.ReadFile("test_data/eth-block-body-rlp-997522")
+	checkError(err, t)
+
+	bn, err := FromRawBlockJSON(prepareRawJSON(hexutil.Bytes(rawdata), t))
+	checkError(err, t)
+
+	expected, err := FromBlockRLP(bytes.NewReader(rawdata))
+	checkError(err, t)
+
+	if !bn.Block.Cid().Equals(expected.Block.Cid()) {
+		t.Fatalf("Wrong block\r\nexpected %s\r\ngot %s", expected.Block.Cid(), bn.Block.Cid())
+	}
+	if len(bn.Txs) != 1 || len(bn.Uncles) != 2 || bn.UncleList == nil {
+		t.Fatal("Wrong block body")
+	}
+}
+
+func TestBlockHeaderRawJsonParsing(t *testing.T) {
+	rawdata, err := ioutil.ReadFile("test_data/eth-block-header-rlp-999999")
+	checkError(err, t)
+
+	bn, err := FromRawBlockJSON(prepareRawJSON(hexutil.Bytes(rawdata), t))
+	checkError(err, t)
+
+	testEthBlockFields(bn.Block, t)
+}
+
+func TestBlockRawJsonErrors(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"the method debug_getRawBlock does not exist/is not available"}}`,
+			"the method debug_getRawBlock does not exist/is not available (code -32601)",
+		},
+		{
+			`{"jsonrpc":"2.0","id":1,"result":null}`,
+			"no result in the JSON API response",
+		},
+	}
+
+	for _, tc := range testCases {
+		_, err := FromRawBlockJSON(strings.NewReader(tc.input))
+		if err == nil {
+			t.Fatal("Expected an error")
+		}
+		if err.Error() != tc.expected {
+			t.Fatalf("Wrong error\r\nexpected %s\r\ngot %s", tc.expected, err.Error())
+		}
+	}
+
+	// Not hex
+	_, err := FromRawBlockJSON(strings.NewReader(`{"result":"f9021"}`))
 	if err == nil {
 		t.Fatal("Expected an error")
 	}
@@ -465,6 +629,150 @@ func TestEthBlockSize(t *testing.T) {
 	}
 }
 
+/*
+  Post-London header fields
+
+  The header of block 999999, extended with every optional field, is
+  synthetic: its hash is computed, not published. Mainnet Cancun and
+  Prague headers are still to be added next to it.
+*/
+
+func TestBlockHeaderExtRlpParsing(t *testing.T) {
+	fi, err := os.Open("test_data/eth-block-header-rlp-999999-prague")
+	checkError(err, t)
+
+	bn, err := FromBlockRLP(fi)
+	checkError(err, t)
+
+	testEthBlockExtFields(bn.Block, t)
+}
+
+func TestBlockHeaderExtJsonParsing(t *testing.T) {
+	fi, err := os.Open("test_data/eth-block-body-json-999999-prague")
+	checkError(err, t)
+
+	bn, err := FromBlockJSON(fi)
+	checkError(err, t)
+
+	// The re-encoded header must be byte for byte the one of the RLP input
+	testEthBlockExtFields(bn.Block, t)
+}
+
+func TestDecodeBlockHeaderExt(t *testing.T) {
+	ethBlock := prepareDecodedEthBlock("test_data/eth-block-header-rlp-999999-prague", t)
+
+	testEthBlockExtFields(ethBlock, t)
+
+	if fmt.Sprintf("%x", encodeHeader(ethBlock.Header, ethBlock.EthHeaderExt)) !=
+		fmt.Sprintf("%x", ethBlock.RawData()) {
+		t.Fatal("Wrong re-encoded header")
+	}
+
+	// A legacy header has none of them
+	legacy := prepareDecodedEthBlock("test_data/eth-block-header-rlp-999999", t)
+	if len(legacy.EthHeaderExt.rlpFields()) != 0 {
+		t.Fatal("Unexpected optional header fields")
+	}
+}
+
+func TestHeaderExtFromJSONMissingField(t *testing.T) {
+	_, err := headerExtFromJSON([]byte(`{"baseFeePerGas": "0x1", "blobGasUsed": "0x0"}`))
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if err.Error() != "header field blobGasUsed given without withdrawalsRoot" {
+		t.Fatalf("Wrong error %v", err)
+	}
+}
+
+func TestEthBlockExtResolve(t *testing.T) {
+	ethBlock := prepareDecodedEthBlock("test_data/eth-block-header-rlp-999999-prague", t)
+
+	testCases := map[string][]string{
+		"baseFeePerGas":         []string{"%s", "1000000000"},
+		"blobGasUsed":           []string{"%d", "131072"},
+		"excessBlobGas":         []string{"%d", "0"},
+		"parentBeaconBlockRoot": []string{"%x", "1111111111111111111111111111111111111111111111111111111111111111"},
+		"requestsHash":          []string{"%x", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+	}
+
+	for field, value := range testCases {
+		obj, rest, err := ethBlock.Resolve([]string{field})
+		checkError(err, t)
+
+		if fmt.Sprintf(value[0], obj) != value[1] {
+			t.Fatalf("Wrong %v", field)
+		}
+		if len(rest) != 0 {
+			t.Fatal("Wrong rest of the path returned")
+		}
+	}
+
+	lnk, rest, err := ethBlock.ResolveLink([]string{"withdrawalsRoot", "0"})
+	checkError(err, t)
+	if lnk.Cid.String() != "z481Z7krJjAneUJqwnNwKiYkTj6vEwpXbrqXj3ouH2hjz2pN2XS" {
+		t.Fatal("Wrong withdrawalsRoot cid")
+	}
+	if len(rest) != 1 || rest[0] != "0" {
+		t.Fatal("Wrong rest of the path returned")
+	}
+
+	// A legacy header does not have them
+	legacy := prepareDecodedEthBlock("test_data/eth-block-header-rlp-999999", t)
+	for _, field := range headerExtPaths {
+		_, _, err = legacy.Resolve([]string{field})
+		if err == nil || err.Error() != "no such link" {
+			t.Fatalf("Expected 'no such link' error for %s", field)
+		}
+	}
+}
+
+func TestEthBlockExtTreeAndLinks(t *testing.T) {
+	ethBlock := prepareDecodedEthBlock("test_data/eth-block-header-rlp-999999-prague", t)
+
+	tree := ethBlock.Tree("", 1)
+	if len(tree) != 15+len(headerExtPaths) {
+		t.Fatalf("Wrong number of elements. Got %d", len(tree))
+	}
+	for i, k := range headerExtPaths {
+		if tree[15+i] != k {
+			t.Fatalf("Wrong element %v", tree[15+i])
+		}
+	}
+
+	links := ethBlock.Links()
+	if len(links) != 6 {
+		t.Fatal("Wrong number of links")
+	}
+	if links[5].Cid.String() != "z481Z7krJjAneUJqwnNwKiYkTj6vEwpXbrqXj3ouH2hjz2pN2XS" {
+		t.Fatal("Wrong cid for withdrawals root link")
+	}
+}
+
+func TestEthBlockExtJSONMarshal(t *testing.T) {
+	ethBlock := prepareDecodedEthBlock("test_data/eth-block-header-rlp-999999-prague", t)
+
+	jsonOutput, err := ethBlock.MarshalJSON()
+	checkError(err, t)
+
+	var data map[string]interface{}
+	err = json.Unmarshal(jsonOutput, &data)
+	checkError(err, t)
+
+	if parseFloat(data["baseFeePerGas"]) != "1000000000" {
+		t.Fatal("Wrong base fee")
+	}
+	if parseFloat(data["blobGasUsed"]) != "131072" {
+		t.Fatal("Wrong blob gas used")
+	}
+	if data["requestsHash"] != "0xe3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Fatal("Wrong requests hash")
+	}
+	if parseMapElement(data["withdrawalsRoot"]) != "z481Z7krJjAneUJqwnNwKiYkTj6vEwpXbrqXj3ouH2hjz2pN2XS" {
+		t.Fatal("Wrong withdrawals root cid")
+	}
+}
+
 /*
   AUXILIARS
 */
@@ -511,7 +819,7 @@ func prepareStoredEthBlock(filepath string, t *testing.T) *block.BasicBlock {
 // basic block and RLP-decodes it
 func prepareDecodedEthBlock(filepath string, t *testing.T) *EthBlock {
 	// Get the block from the datastore and decode it.
-	storedEthBlock := prepareStoredEthBlock("test_data/eth-block-header-rlp-999999", t)
+	storedEthBlock := prepareStoredEthBlock(filepath, t)
 	ethBlock, err := DecodeEthBlock(storedEthBlock.Cid(), storedEthBlock.RawData())
 	checkError(err, t)
 
@@ -580,3 +888,66 @@ func testEthBlockFields(ethBlock *EthBlock, t *testing.T) {
 		t.Fatal("Wrong MixDigest")
 	}
 }
+
+// testEthBlockExtFields checks the synthetic header with all the optional
+// fields, built upon the one of block 999999.
+func testEthBlockExtFields(ethBlock *EthBlock, t *testing.T) {
+	// The cid is the one of the block hash
+	if ethBlock.Cid().String() != "z43AaGF5VFtTbPnkgxEH9Dhtrckck9KXobLaZkvXX8Q91NcnBea" {
+		t.Fatal("Wrong cid")
+	}
+	if ethBlock.Hash().Hex() != "0xbdafabc985e7995b469e259df9ec5504946f40cee5eb84318372a1e19a5c4d43" {
+		t.Fatal("Wrong hash")
+	}
+
+	if ethBlock.Number.String() != "999999" {
+		t.Fatal("Wrong Block Number")
+	}
+	if ethBlock.BaseFee.String() != "1000000000" {
+		t.Fatal("Wrong BaseFee")
+	}
+	if fmt.Sprintf("%x", *ethBlock.WithdrawalsHash) != "56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421" {
+		t.Fatal("Wrong WithdrawalsHash")
+	}
+	if *ethBlock.BlobGasUsed != 131072 {
+		t.Fatal("Wrong BlobGasUsed")
+	}
+	if *ethBlock.ExcessBlobGas != 0 {
+		t.Fatal("Wrong ExcessBlobGas")
+	}
+	if fmt.Sprintf("%x", *ethBlock.ParentBeaconRoot) != "1111111111111111111111111111111111111111111111111111111111111111" {
+		t.Fatal("Wrong ParentBeaconRoot")
+	}
+	if fmt.Sprintf("%x", *ethBlock.RequestsHash) != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Fatal("Wrong RequestsHash")
+	}
+}
+
+// prepareRawJSON returns the given result as a response of the JSON API,
+// as debug_getRawBlock or debug_getRawReceipts give it.
+func prepareRawJSON(result interface{}, t *testing.T) io.Reader {
+	b, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"result":  result,
+	})
+	checkError(err, t)
+
+	return bytes.NewReader(b)
+}
+
+// prepareEditedBlockJSON returns the JSON API output in the given
+// file, with its "result" field changed by edit.
+func prepareEditedBlockJSON(filepath string, edit func(map[string]interface{}), t *testing.T) io.Reader {
+	b, err := ioutil.ReadFile(filepath)
+	checkError(err, t)
+
+	var obj map[string]interface{}
+	checkError(json.Unmarshal(b, &obj), t)
+	edit(obj["result"].(map[string]interface{}))
+
+	b, err = json.Marshal(obj)
+	checkError(err, t)
+
+	return bytes.NewReader(b)
+}
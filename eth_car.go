@@ -0,0 +1,624 @@
+package ipldeth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	cid "github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// CarOptions tells ExportCar which part of the DAG of a block to export.
+type CarOptions struct {
+	// Version of the CAR archive, 1 or 2. Defaults to 1.
+	Version int
+
+	// Subgraphs are the codecs of the links of the block to follow.
+	// Defaults to DefaultCarSubgraphs. Add MEthStateTrie to export
+	// the state trie, along with the storage tries and code of its
+	// accounts. The parent block is never followed.
+	Subgraphs []uint64
+
+	// MaxDepth is the number of links followed from the block,
+	// 0 for no limit.
+	MaxDepth int
+}
+
+// DefaultCarSubgraphs are the codecs of the links ExportCar follows by
+// default: the transactions, receipts, ommers and withdrawals of the block.
+var DefaultCarSubgraphs = []uint64{MEthTxTrie, MEthTxReceiptTrie, MEthBlockList, MEthWithdrawalTrie}
+
+// CarReader reads the blocks of a CARv1 or CARv2 archive, one at a time.
+// Every block is checked against its cid, and decoded by the DecodeEth*
+// function of its codec, so blocks whose payload does not match their
+// codec are rejected.
+//
+// The CAR framing, and the bit of DAG-CBOR of its header, are done here:
+// go-car and go-ipld-cbor build upon a go-cid newer than the one of this
+// package.
+type CarReader struct {
+	r     *bufio.Reader
+	roots []*cid.Cid
+	err   error
+}
+
+// carDecoders are the decoders of the blocks of the archives,
+// by codec, as registered by the plugin.
+var carDecoders = map[uint64]func(*cid.Cid, []byte) (node.Node, error){
+	MEthBlock:          func(c *cid.Cid, b []byte) (node.Node, error) { return DecodeEthBlock(c, b) },
+	MEthBlockList:      func(c *cid.Cid, b []byte) (node.Node, error) { return DecodeEthBlockList(c, b) },
+	MEthTx:             func(c *cid.Cid, b []byte) (node.Node, error) { return DecodeEthTx(c, b) },
+	MEthTxTrie:         func(c *cid.Cid, b []byte) (node.Node, error) { return DecodeEthTxTrie(c, b) },
+	MEthTxReceiptTrie:  func(c *cid.Cid, b []byte) (node.Node, error) { return DecodeEthTxReceiptTrie(c, b) },
+	MEthTxReceipt:      func(c *cid.Cid, b []byte) (node.Node, error) { return DecodeEthTxReceipt(c, b) },
+	MEthStateTrie:      func(c *cid.Cid, b []byte) (node.Node, error) { return DecodeEthStateTrie(c, b) },
+	MEthStorageTrie:    func(c *cid.Cid, b []byte) (node.Node, error) { return DecodeEthStorageTrie(c, b) },
+	MEthWithdrawalTrie: func(c *cid.Cid, b []byte) (node.Node, error) { return DecodeEthWithdrawalTrie(c, b) },
+	MEthWithdrawal:     func(c *cid.Cid, b []byte) (node.Node, error) { return DecodeEthWithdrawal(c, b) },
+	MEthLogTrie:        func(c *cid.Cid, b []byte) (node.Node, error) { return DecodeEthLogTrie(c, b) },
+	MEthLog:            func(c *cid.Cid, b []byte) (node.Node, error) { return DecodeEthLog(c, b) },
+	RawBinary:          func(c *cid.Cid, b []byte) (node.Node, error) { return DecodeEthCode(c, b) },
+}
+
+// carV2Pragma opens CARv2 archives. It reads as a CARv1 header
+// of version 2, for CARv1 readers to reject them.
+var carV2Pragma = []byte{0x0a, 0xa1, 0x67, 'v', 'e', 'r', 's', 'i', 'o', 'n', 0x02}
+
+const (
+	// carV2HeaderSize is the size of the header following the pragma:
+	// characteristics (16 bytes), data offset, data size and index offset.
+	carV2HeaderSize = 40
+
+	// carMaxSectionSize bounds the sections of the archives read,
+	// not to allocate whatever a corrupted length asks for.
+	carMaxSectionSize = 32 << 20
+)
+
+/*
+  INPUT
+*/
+
+// NewCarReader reads the header of the given CARv1 or CARv2 archive, and
+// returns a CarReader over its blocks.
+func NewCarReader(r io.Reader) (*CarReader, error) {
+	br := bufio.NewReader(r)
+
+	pragma, err := br.Peek(len(carV2Pragma))
+	if err == nil && bytes.Equal(pragma, carV2Pragma) {
+		br, err = openCarV2Data(br)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	header, err := readCarSection(br)
+	if err == io.EOF {
+		return nil, fmt.Errorf("empty car archive")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	roots, err := decodeCarHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CarReader{r: br, roots: roots}, nil
+}
+
+// Roots returns the roots given in the header of the archive.
+func (cr *CarReader) Roots() []*cid.Cid {
+	return cr.roots
+}
+
+// Next returns the next block of the archive, decoded, or io.EOF when
+// there are no more. Any error ends the reading.
+func (cr *CarReader) Next() (node.Node, error) {
+	if cr.err != nil {
+		return nil, cr.err
+	}
+
+	nd, err := cr.next()
+	if err != nil {
+		cr.err = err
+		return nil, err
+	}
+	return nd, nil
+}
+
+/*
+  OUTPUT
+*/
+
+// ExportCar writes the DAG of the eth-block of the given cid to w, as a
+// CAR archive rooted at the block. The blocks are read from the NodeGetter,
+// and written once, breadth first, along the subgraphs and up to the depth
+// given by the options. Links to empty tries, empty ommer lists and empty
+// code are not followed, as there are no blocks behind them.
+//
+// The CARv2 archive is written without an index, and the blocks are held
+// in memory until the end, as its header gives the size of the data.
+func ExportCar(ctx context.Context, ng node.NodeGetter, root *cid.Cid, w io.Writer, opts CarOptions) error {
+	if root.Type() != MEthBlock {
+		return fmt.Errorf("car export starts from an eth-block, got codec %x", root.Type())
+	}
+
+	version := opts.Version
+	if version == 0 {
+		version = 1
+	}
+	if version != 1 && version != 2 {
+		return fmt.Errorf("unknown car version %d", version)
+	}
+
+	subgraphs := opts.Subgraphs
+	if subgraphs == nil {
+		subgraphs = DefaultCarSubgraphs
+	}
+
+	out := w
+	var data bytes.Buffer
+	if version == 2 {
+		out = &data
+	}
+
+	if err := writeCarSection(out, encodeCarHeader(root)); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	queue := []carItem{{c: root}}
+	for len(queue) != 0 {
+		item := queue[0]
+		queue = queue[1:]
+
+		if seen[item.c.KeyString()] {
+			continue
+		}
+		seen[item.c.KeyString()] = true
+
+		nd := item.nd
+		if nd == nil {
+			var err error
+			nd, err = getCarNode(ctx, ng, item.c)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := writeCarSection(out, item.c.Bytes(), nd.RawData()); err != nil {
+			return err
+		}
+
+		if opts.MaxDepth > 0 && item.depth >= opts.MaxDepth {
+			continue
+		}
+		for _, next := range carLinks(nd, item.depth == 0, subgraphs) {
+			next.depth = item.depth + 1
+			queue = append(queue, next)
+		}
+	}
+
+	if version == 1 {
+		return nil
+	}
+
+	// pragma | characteristics | data offset | data size | index offset | data
+	header := make([]byte, carV2HeaderSize)
+	binary.LittleEndian.PutUint64(header[16:], uint64(len(carV2Pragma)+carV2HeaderSize))
+	binary.LittleEndian.PutUint64(header[24:], uint64(data.Len()))
+	for _, b := range [][]byte{carV2Pragma, header, data.Bytes()} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+  AUXILIARS
+*/
+
+// next reads and checks the next block of the archive.
+func (cr *CarReader) next() (node.Node, error) {
+	section, err := readCarSection(cr.r)
+	if err != nil {
+		return nil, err
+	}
+
+	c, n, err := readCarCid(section)
+	if err != nil {
+		return nil, err
+	}
+	rawdata := section[n:]
+
+	sum, err := c.Prefix().Sum(rawdata)
+	if err != nil {
+		return nil, err
+	}
+	if !sum.Equals(c) {
+		return nil, fmt.Errorf("block %s does not match its cid", c)
+	}
+
+	decode, ok := carDecoders[c.Type()]
+	if !ok {
+		return nil, fmt.Errorf("no decoder for codec %x of block %s", c.Type(), c)
+	}
+	nd, err := decode(c, rawdata)
+	if err != nil {
+		return nil, fmt.Errorf("block %s: %v", c, err)
+	}
+	return nd, nil
+}
+
+// carItem is a block to export, and its depth from the root. The blocks
+// found in the leaves of the tries are already decoded.
+type carItem struct {
+	c     *cid.Cid
+	nd    node.Node
+	depth int
+}
+
+// getCarNode gets the block of the given cid, and decodes it.
+func getCarNode(ctx context.Context, ng node.NodeGetter, c *cid.Cid) (node.Node, error) {
+	decode, ok := carDecoders[c.Type()]
+	if !ok {
+		return nil, fmt.Errorf("no decoder for codec %x of block %s", c.Type(), c)
+	}
+
+	nd, err := ng.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	return decode(c, nd.RawData())
+}
+
+// carLinks returns the blocks linked from the given node. Only the root
+// block is followed, along the given subgraphs, not the ommers. Trie nodes
+// link to their children, and to the blocks held in their leaves.
+func carLinks(nd node.Node, isRoot bool, subgraphs []uint64) []carItem {
+	var out []carItem
+	add := func(c *cid.Cid) {
+		if !isEmptyCarLink(c) {
+			out = append(out, carItem{c: c})
+		}
+	}
+
+	if b, ok := nd.(*EthBlock); ok {
+		if !isRoot {
+			return nil
+		}
+		for _, l := range b.Links() {
+			if l.Cid.Type() == MEthBlock {
+				continue
+			}
+			for _, codec := range subgraphs {
+				if l.Cid.Type() == codec {
+					add(l.Cid)
+				}
+			}
+		}
+		return out
+	}
+
+	tn := carTrieNode(nd)
+	if tn == nil {
+		for _, l := range nd.Links() {
+			add(l.Cid)
+		}
+		return out
+	}
+
+	for _, l := range tn.Links() {
+		add(l.Cid)
+	}
+	if tn.nodeKind == "leaf" {
+		switch v := tn.elements[1].(type) {
+		case *EthAccountSnapshot:
+			add(keccak256ToCid(MEthStorageTrie, v.Root))
+			add(keccak256ToCid(RawBinary, v.CodeHash))
+		case node.Node:
+			if _, ok := carDecoders[v.Cid().Type()]; ok {
+				out = append(out, carItem{c: v.Cid(), nd: v})
+			}
+		}
+	}
+	return out
+}
+
+// carTrieNode returns the trie node of the trie IPLD nodes.
+func carTrieNode(nd node.Node) *TrieNode {
+	switch n := nd.(type) {
+	case *EthTxTrie:
+		return n.TrieNode
+	case *EthTxReceiptTrie:
+		return n.TrieNode
+	case *EthStateTrie:
+		return n.TrieNode
+	case *EthStorageTrie:
+		return n.TrieNode
+	case *EthWithdrawalTrie:
+		return n.TrieNode
+	case *EthLogTrie:
+		return n.TrieNode
+	default:
+		return nil
+	}
+}
+
+// isEmptyCarLink tells whether the given cid is the one of an empty trie,
+// ommer list or code, which are not stored as blocks.
+func isEmptyCarLink(c *cid.Cid) bool {
+	h, err := cidToHash(c)
+	if err != nil {
+		return false
+	}
+	return h == types.EmptyRootHash || h == types.EmptyUncleHash || h == emptyCodeHash
+}
+
+// openCarV2Data reads the header of a CARv2 archive,
+// and returns a reader over the CARv1 data it wraps.
+func openCarV2Data(r *bufio.Reader) (*bufio.Reader, error) {
+	header := make([]byte, len(carV2Pragma)+carV2HeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("invalid carv2 header: %v", err)
+	}
+	header = header[len(carV2Pragma):]
+
+	offset := binary.LittleEndian.Uint64(header[16:])
+	size := binary.LittleEndian.Uint64(header[24:])
+	if offset < uint64(len(carV2Pragma)+carV2HeaderSize) {
+		return nil, fmt.Errorf("invalid carv2 data offset %d", offset)
+	}
+
+	skip := int64(offset) - int64(len(carV2Pragma)+carV2HeaderSize)
+	if _, err := io.CopyN(ioutil.Discard, r, skip); err != nil {
+		return nil, err
+	}
+	return bufio.NewReader(io.LimitReader(r, int64(size))), nil
+}
+
+// readCarSection reads a section of a CARv1 archive, prefixed by its
+// length. It returns io.EOF only when the archive ends before the section.
+func readCarSection(r *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("invalid car section length: %v", err)
+	}
+	if length == 0 || length > carMaxSectionSize {
+		return nil, fmt.Errorf("invalid car section length %d", length)
+	}
+
+	section := make([]byte, length)
+	if _, err := io.ReadFull(r, section); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return section, nil
+}
+
+// writeCarSection writes the concatenation of the given
+// slices as a section of a CARv1 archive.
+func writeCarSection(w io.Writer, data ...[]byte) error {
+	var length int
+	for _, d := range data {
+		length += len(d)
+	}
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, uint64(length))
+	if _, err := w.Write(buf[:n]); err != nil {
+		return err
+	}
+	for _, d := range data {
+		if _, err := w.Write(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCarCid reads the cid opening a block section,
+// returning it along with its size.
+func readCarCid(b []byte) (*cid.Cid, int, error) {
+	// CIDv0, a bare sha2-256 multihash
+	if len(b) >= 34 && b[0] == 0x12 && b[1] == 0x20 {
+		c, err := cid.Cast(b[:34])
+		return c, 34, err
+	}
+
+	// version | codec | multihash code | digest size | digest
+	var n int
+	var size uint64
+	for i := 0; i < 4; i++ {
+		v, l := binary.Uvarint(b[n:])
+		if l <= 0 {
+			return nil, 0, fmt.Errorf("invalid cid in car section")
+		}
+		n += l
+		size = v
+	}
+	if uint64(len(b)-n) < size {
+		return nil, 0, fmt.Errorf("invalid cid in car section")
+	}
+	n += int(size)
+
+	c, err := cid.Cast(b[:n])
+	return c, n, err
+}
+
+// encodeCarHeader returns the DAG-CBOR header of a CARv1 archive with
+// the given root: {"roots": [root], "version": 1}.
+func encodeCarHeader(root *cid.Cid) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(0xa2) // map of 2 entries
+	writeCborHead(&buf, 3, 5)
+	buf.WriteString("roots")
+	buf.WriteByte(0x81)                 // array of 1 element
+	buf.Write([]byte{0xd8, cborCidTag}) // cid tag
+	writeCborHead(&buf, 2, uint64(len(root.Bytes())+1))
+	buf.WriteByte(0x00) // multibase identity prefix
+	buf.Write(root.Bytes())
+	writeCborHead(&buf, 3, 7)
+	buf.WriteString("version")
+	buf.WriteByte(0x01)
+	return buf.Bytes()
+}
+
+// decodeCarHeader returns the roots of the given CARv1 header.
+func decodeCarHeader(b []byte) ([]*cid.Cid, error) {
+	v, rest, err := decodeCbor(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid car header: %v", err)
+	}
+	header, ok := v.(map[string]interface{})
+	if !ok || len(rest) != 0 {
+		return nil, fmt.Errorf("invalid car header")
+	}
+
+	if version, _ := header["version"].(uint64); version != 1 {
+		return nil, fmt.Errorf("unknown car version %v", header["version"])
+	}
+
+	roots, ok := header["roots"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid car header roots")
+	}
+	var out []*cid.Cid
+	for _, r := range roots {
+		c, ok := r.(*cid.Cid)
+		if !ok {
+			return nil, fmt.Errorf("invalid car header roots")
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// cborCidTag is the CBOR tag of the cids in DAG-CBOR.
+const cborCidTag = 42
+
+// writeCborHead writes the head of a CBOR item of the given major type.
+func writeCborHead(buf *bytes.Buffer, major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		buf.WriteByte(major | byte(n))
+	case n <= 0xff:
+		buf.Write([]byte{major | 24, byte(n)})
+	case n <= 0xffff:
+		buf.WriteByte(major | 25)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= 0xffffffff:
+		buf.WriteByte(major | 26)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(major | 27)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+// decodeCbor decodes the CBOR item at the start of b, as far as CAR
+// headers go: unsigned integers, strings, arrays, maps keyed by strings,
+// cids and simple values. It returns the rest of b.
+func decodeCbor(b []byte) (interface{}, []byte, error) {
+	if len(b) == 0 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	b = b[1:]
+
+	var n uint64
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(b) < size {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		for _, x := range b[:size] {
+			n = n<<8 | uint64(x)
+		}
+		b = b[size:]
+	default:
+		return nil, nil, fmt.Errorf("unsupported cbor item %x", major<<5|info)
+	}
+
+	switch major {
+	case 0:
+		return n, b, nil
+	case 2, 3:
+		if uint64(len(b)) < n {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		if major == 3 {
+			return string(b[:n]), b[n:], nil
+		}
+		return b[:n], b[n:], nil
+	case 4:
+		if n > uint64(len(b)) {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		out := make([]interface{}, n)
+		for i := range out {
+			var err error
+			out[i], b, err = decodeCbor(b)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		return out, b, nil
+	case 5:
+		out := make(map[string]interface{})
+		for i := uint64(0); i < n; i++ {
+			k, rest, err := decodeCbor(b)
+			if err != nil {
+				return nil, nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("cbor map key is not a string")
+			}
+			out[key], b, err = decodeCbor(rest)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		return out, b, nil
+	case 6:
+		v, rest, err := decodeCbor(b)
+		if err != nil || n != cborCidTag {
+			return v, rest, err
+		}
+		raw, ok := v.([]byte)
+		if !ok || len(raw) == 0 || raw[0] != 0x00 {
+			return nil, nil, fmt.Errorf("invalid cid in cbor")
+		}
+		c, err := cid.Cast(raw[1:])
+		return c, rest, err
+	case 7:
+		switch info {
+		case 20:
+			return false, b, nil
+		case 21:
+			return true, b, nil
+		case 22:
+			return nil, b, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("unsupported cbor item %x", major<<5|info)
+}
@@ -0,0 +1,261 @@
+package ipldeth
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+)
+
+/*
+  INPUT
+*/
+
+func TestCarReaderCodecMismatch(t *testing.T) {
+	_, _, head := prepareExportChain(t)
+
+	// The header of the block, given as a transaction
+	c := rawdataToCid(MEthTx, head.RawData())
+	archive := prepareCar(t, head.Cid(), []*cid.Cid{c}, [][]byte{head.RawData()})
+
+	cr, err := NewCarReader(bytes.NewReader(archive))
+	checkError(err, t)
+
+	_, err = cr.Next()
+	if err == nil || !strings.HasPrefix(err.Error(), "block "+c.String()) {
+		t.Fatalf("Expected the block to be rejected\r\ngot %v", err)
+	}
+
+	// The error sticks
+	if _, err2 := cr.Next(); err2 != err {
+		t.Fatalf("Expected the same error\r\nexpected %v\r\ngot %v", err, err2)
+	}
+}
+
+func TestCarReaderCidMismatch(t *testing.T) {
+	_, genesis, head := prepareExportChain(t)
+	archive := prepareCar(t, head.Cid(), []*cid.Cid{head.Cid()}, [][]byte{genesis.RawData()})
+
+	cr, err := NewCarReader(bytes.NewReader(archive))
+	checkError(err, t)
+
+	_, err = cr.Next()
+	expected := "block " + head.Cid().String() + " does not match its cid"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("Expected error '%s'\r\ngot %v", expected, err)
+	}
+}
+
+func TestCarReaderUnknownCodec(t *testing.T) {
+	_, _, head := prepareExportChain(t)
+	c := rawdataToCid(MEthAccountSnapshot, head.RawData())
+	archive := prepareCar(t, head.Cid(), []*cid.Cid{c}, [][]byte{head.RawData()})
+
+	cr, err := NewCarReader(bytes.NewReader(archive))
+	checkError(err, t)
+
+	_, err = cr.Next()
+	if err == nil || !strings.HasPrefix(err.Error(), "no decoder for codec 97") {
+		t.Fatalf("Expected an unknown codec error\r\ngot %v", err)
+	}
+}
+
+func TestCarReaderGoCar(t *testing.T) {
+	// A CARv1 archive of the go-car test suite, rooted at a single
+	// DAG-CBOR block: {"doggy": true}
+	archive, err := ioutil.ReadFile("test_data/car-v1-go-car")
+	checkError(err, t)
+
+	cr, err := NewCarReader(bytes.NewReader(archive))
+	checkError(err, t)
+	roots := cr.Roots()
+	if len(roots) != 1 || fmt.Sprintf("%x", roots[0].Bytes()) !=
+		"01711220151fe9e73c6267a7060c6f6c4cca943c236f4b196723489608edb42a8b8fa80b" {
+		t.Fatalf("Wrong roots %v", roots)
+	}
+
+	// The header is the one we write
+	if !bytes.Equal(encodeCarHeader(roots[0]), archive[1:59]) {
+		t.Fatal("Wrong encoded header")
+	}
+
+	// The block matches its cid, but is no ethereum one
+	_, err = cr.Next()
+	if err == nil || !strings.HasPrefix(err.Error(), "no decoder for codec 71") {
+		t.Fatalf("Expected an unknown codec error\r\ngot %v", err)
+	}
+
+	v, rest, err := decodeCbor(archive[len(archive)-8:])
+	checkError(err, t)
+	if obj, ok := v.(map[string]interface{}); !ok || len(rest) != 0 || len(obj) != 1 || obj["doggy"] != true {
+		t.Fatalf("Wrong block %v", v)
+	}
+}
+
+func TestCarReaderInvalidHeader(t *testing.T) {
+	for _, archive := range [][]byte{
+		nil,
+		{0x02, 0xa0, 0x00},
+		{0x0a, 0xa1, 0x67, 'v', 'e', 'r', 's', 'i', 'o', 'n', 0x03},
+	} {
+		if _, err := NewCarReader(bytes.NewReader(archive)); err == nil {
+			t.Fatalf("Expected an error for header %x", archive)
+		}
+	}
+}
+
+/*
+  OUTPUT
+*/
+
+func TestExportCar(t *testing.T) {
+	ng, _, head := prepareExportChain(t)
+
+	var buf bytes.Buffer
+	err := ExportCar(context.Background(), ng, head.Cid(), &buf, CarOptions{})
+	checkError(err, t)
+
+	roots, nodes := readTestCar(t, buf.Bytes())
+	if len(roots) != 1 || !roots[0].Equals(head.Cid()) {
+		t.Fatalf("Wrong roots\r\nexpected %s\r\ngot %v", head.Cid(), roots)
+	}
+
+	// The block, the root of its transaction trie, and its transaction
+	if len(nodes) != 3 {
+		t.Fatalf("Wrong number of blocks\r\nexpected %d\r\ngot %d", 3, len(nodes))
+	}
+	if _, ok := nodes[0].(*EthBlock); !ok || !nodes[0].Cid().Equals(head.Cid()) {
+		t.Fatal("The block is not the first one of the archive")
+	}
+	if _, ok := nodes[1].(*EthTxTrie); !ok {
+		t.Fatalf("Expected an eth-tx-trie\r\ngot %T", nodes[1])
+	}
+	if _, ok := nodes[2].(*EthTx); !ok {
+		t.Fatalf("Expected an eth-tx\r\ngot %T", nodes[2])
+	}
+}
+
+func TestExportCarState(t *testing.T) {
+	ng, genesis, head := prepareExportChain(t)
+
+	for _, version := range []int{1, 2} {
+		var buf bytes.Buffer
+		err := ExportCar(context.Background(), ng, head.Cid(), &buf, CarOptions{
+			Version:   version,
+			Subgraphs: append(DefaultCarSubgraphs, MEthStateTrie),
+		})
+		checkError(err, t)
+
+		if version == 2 && !bytes.HasPrefix(buf.Bytes(), carV2Pragma) {
+			t.Fatal("Expected a CARv2 archive")
+		}
+
+		// All of the nodes but the parent block, plus the transaction
+		_, nodes := readTestCar(t, buf.Bytes())
+		if len(nodes) != len(ng) {
+			t.Fatalf("Wrong number of blocks\r\nexpected %d\r\ngot %d", len(ng), len(nodes))
+		}
+		for _, nd := range nodes {
+			if nd.Cid().Equals(genesis.Cid()) {
+				t.Fatal("The parent block was exported")
+			}
+			if nd.Cid().Type() == MEthTx {
+				continue
+			}
+			if expected, ok := ng[nd.Cid().String()]; !ok || !bytes.Equal(expected.RawData(), nd.RawData()) {
+				t.Fatalf("Unexpected block %s", nd.Cid())
+			}
+		}
+	}
+}
+
+func TestExportCarMaxDepth(t *testing.T) {
+	ng, _, head := prepareExportChain(t)
+
+	var buf bytes.Buffer
+	err := ExportCar(context.Background(), ng, head.Cid(), &buf, CarOptions{MaxDepth: 1})
+	checkError(err, t)
+
+	_, nodes := readTestCar(t, buf.Bytes())
+	if len(nodes) != 2 {
+		t.Fatalf("Wrong number of blocks\r\nexpected %d\r\ngot %d", 2, len(nodes))
+	}
+}
+
+func TestExportCarErrors(t *testing.T) {
+	ng, _, head := prepareExportChain(t)
+
+	var buf bytes.Buffer
+	err := ExportCar(context.Background(), ng, head.Cid(), &buf, CarOptions{Version: 3})
+	if err == nil || err.Error() != "unknown car version 3" {
+		t.Fatalf("Expected error 'unknown car version 3'\r\ngot %v", err)
+	}
+
+	c := commonHashToCid(MEthTxTrie, head.TxHash)
+	err = ExportCar(context.Background(), ng, c, &buf, CarOptions{})
+	if err == nil || err.Error() != "car export starts from an eth-block, got codec 92" {
+		t.Fatalf("Expected error 'car export starts from an eth-block, got codec 92'\r\ngot %v", err)
+	}
+
+	// A missing block fails the export
+	delete(ng, c.String())
+	err = ExportCar(context.Background(), ng, head.Cid(), &buf, CarOptions{})
+	if err != node.ErrNotFound {
+		t.Fatalf("Expected error '%v'\r\ngot %v", node.ErrNotFound, err)
+	}
+}
+
+/*
+  AUXILIARS
+*/
+
+func TestCarCbor(t *testing.T) {
+	_, _, head := prepareExportChain(t)
+
+	roots, err := decodeCarHeader(encodeCarHeader(head.Cid()))
+	checkError(err, t)
+	if len(roots) != 1 || !roots[0].Equals(head.Cid()) {
+		t.Fatalf("Wrong roots\r\nexpected %s\r\ngot %v", head.Cid(), roots)
+	}
+
+	// {"version": 1, "roots": []}, keys in any order
+	roots, err = decodeCarHeader([]byte{0xa2, 0x67, 'v', 'e', 'r', 's', 'i', 'o', 'n', 0x01,
+		0x65, 'r', 'o', 'o', 't', 's', 0x80})
+	checkError(err, t)
+	if len(roots) != 0 {
+		t.Fatal("Expected no roots")
+	}
+}
+
+// prepareCar writes a CARv1 archive of the given sections.
+func prepareCar(t *testing.T, root *cid.Cid, cids []*cid.Cid, data [][]byte) []byte {
+	var buf bytes.Buffer
+	checkError(writeCarSection(&buf, encodeCarHeader(root)), t)
+	for i, c := range cids {
+		checkError(writeCarSection(&buf, c.Bytes(), data[i]), t)
+	}
+	return buf.Bytes()
+}
+
+// readTestCar reads all the blocks of the given archive.
+func readTestCar(t *testing.T, archive []byte) ([]*cid.Cid, []node.Node) {
+	cr, err := NewCarReader(bytes.NewReader(archive))
+	checkError(err, t)
+
+	var nodes []node.Node
+	for {
+		nd, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		checkError(err, t)
+		nodes = append(nodes, nd)
+	}
+	return cr.Roots(), nodes
+}
@@ -0,0 +1,114 @@
+package ipldeth
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ChainReader reads a chain file, as written by geth export, one block
+// at a time, so only a block is held in memory at once. The file is the
+// concatenation of the RLP of the blocks. Gzipped files, as geth writes
+// them when their name ends in .gz, must be given through a gzip.Reader.
+//
+//	cr := NewChainReader(f)
+//	for {
+//		cb, err := cr.Next()
+//		if err == io.EOF {
+//			break
+//		}
+//		if _, ok := err.(*ChainBlockError); ok {
+//			continue // the next block can still be read
+//		}
+//		...
+//	}
+type ChainReader struct {
+	r      *countingReader
+	stream *rlp.Stream
+	blocks int
+	err    error
+}
+
+// ChainBlockError is returned by the ChainReader when a block could be
+// read off the file, but not turned into IPLD nodes, i.e. when its body
+// does not match its header. The reader moves on to the next block.
+type ChainBlockError struct {
+	// Index is the position of the block in the file, from 0
+	Index int
+	Err   error
+}
+
+// Error implements the error interface.
+func (e *ChainBlockError) Error() string {
+	return fmt.Sprintf("block %d of the chain file: %v", e.Index, e.Err)
+}
+
+/*
+  INPUT
+*/
+
+// NewChainReader returns a ChainReader over the given chain file.
+func NewChainReader(r io.Reader) *ChainReader {
+	cr := &countingReader{r: bufio.NewReader(r)}
+	return &ChainReader{
+		r:      cr,
+		stream: rlp.NewStream(cr, 0),
+	}
+}
+
+// Next returns the nodes of the next block of the file, or io.EOF when
+// there are no more. A *ChainBlockError tells the block was not valid,
+// and the reader can go on. Any other error ends the reading, as the file
+// can't be split into blocks any further.
+func (cr *ChainReader) Next() (*BlockNodes, error) {
+	if cr.err != nil {
+		return nil, cr.err
+	}
+
+	rawdata, err := cr.stream.Raw()
+	if err != nil {
+		cr.err = err
+		return nil, err
+	}
+	idx := cr.blocks
+	cr.blocks++
+
+	bn, err := FromBlockRLP(bytes.NewReader(rawdata))
+	if err != nil {
+		return nil, &ChainBlockError{Index: idx, Err: err}
+	}
+
+	return bn, nil
+}
+
+// Progress returns the number of blocks, and of bytes, read so far.
+func (cr *ChainReader) Progress() (int, int64) {
+	return cr.blocks, cr.r.n
+}
+
+/*
+  AUXILIARS
+*/
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
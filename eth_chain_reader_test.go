@@ -0,0 +1,127 @@
+package ipldeth
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+/*
+  INPUT
+*/
+
+func TestChainReader(t *testing.T) {
+	b1, b2 := prepareChainFileBlocks(t)
+
+	cr := NewChainReader(bytes.NewReader(append(append([]byte{}, b1...), b2...)))
+
+	cb, err := cr.Next()
+	checkError(err, t)
+	if len(cb.Txs) != 1 || len(cb.Uncles) != 2 {
+		t.Fatalf("Wrong first block\r\nexpected 1 tx and 2 uncles\r\ngot %d and %d",
+			len(cb.Txs), len(cb.Uncles))
+	}
+	if cb.Block.Number.Int64() != 997522 {
+		t.Fatalf("Wrong block number\r\nexpected %d\r\ngot %s", 997522, cb.Block.Number)
+	}
+
+	cb, err = cr.Next()
+	checkError(err, t)
+	if len(cb.Txs) != 11 || len(cb.TxTrieNodes) == 0 || cb.UncleList == nil {
+		t.Fatal("Wrong second block")
+	}
+
+	n, size := cr.Progress()
+	if n != 2 || size != int64(len(b1)+len(b2)) {
+		t.Fatalf("Wrong progress\r\nexpected %d blocks and %d bytes\r\ngot %d and %d",
+			2, len(b1)+len(b2), n, size)
+	}
+
+	if _, err = cr.Next(); err != io.EOF {
+		t.Fatalf("Expected io.EOF\r\ngot %v", err)
+	}
+}
+
+func TestChainReaderInvalidBlock(t *testing.T) {
+	b1, b2 := prepareChainFileBlocks(t)
+
+	// The header of the second block, with the transactions of the first
+	var body objRLPBlockBody
+	checkError(rlp.DecodeBytes(b2, &body), t)
+	var other objRLPBlockBody
+	checkError(rlp.DecodeBytes(b1, &other), t)
+	body.Transactions = other.Transactions
+	bad := getRLP(&body)
+
+	var file []byte
+	for _, b := range [][]byte{b1, bad, b2} {
+		file = append(file, b...)
+	}
+	cr := NewChainReader(bytes.NewReader(file))
+
+	_, err := cr.Next()
+	checkError(err, t)
+
+	_, err = cr.Next()
+	cbe, ok := err.(*ChainBlockError)
+	if !ok {
+		t.Fatalf("Expected a *ChainBlockError\r\ngot %v", err)
+	}
+	if cbe.Index != 1 {
+		t.Fatalf("Wrong block index\r\nexpected %d\r\ngot %d", 1, cbe.Index)
+	}
+
+	// The reader goes on past the invalid block
+	cb, err := cr.Next()
+	checkError(err, t)
+	if cb.Block.Number.Int64() != 999999 {
+		t.Fatalf("Wrong block number\r\nexpected %d\r\ngot %s", 999999, cb.Block.Number)
+	}
+
+	if _, err = cr.Next(); err != io.EOF {
+		t.Fatalf("Expected io.EOF\r\ngot %v", err)
+	}
+}
+
+func TestChainReaderTruncatedFile(t *testing.T) {
+	b1, b2 := prepareChainFileBlocks(t)
+
+	file := append(append([]byte{}, b1...), b2[:len(b2)/2]...)
+	cr := NewChainReader(bytes.NewReader(file))
+
+	_, err := cr.Next()
+	checkError(err, t)
+
+	_, err = cr.Next()
+	if err == nil || err == io.EOF {
+		t.Fatalf("Expected an error on the truncated block\r\ngot %v", err)
+	}
+	if _, ok := err.(*ChainBlockError); ok {
+		t.Fatal("A truncated file can't be read any further")
+	}
+
+	// The error sticks
+	if _, err2 := cr.Next(); err2 != err {
+		t.Fatalf("Expected the same error\r\nexpected %v\r\ngot %v", err, err2)
+	}
+	if n, _ := cr.Progress(); n != 1 {
+		t.Fatalf("Wrong number of blocks\r\nexpected %d\r\ngot %d", 1, n)
+	}
+}
+
+/*
+  AUXILIARS
+*/
+
+// prepareChainFileBlocks returns the RLP of two blocks,
+// to be written one after the other in a chain file.
+func prepareChainFileBlocks(t *testing.T) ([]byte, []byte) {
+	b1, err := ioutil.ReadFile("test_data/eth-block-body-rlp-997522")
+	checkError(err, t)
+	b2, err := ioutil.ReadFile("test_data/eth-block-body-rlp-999999")
+	checkError(err, t)
+	return b1, b2
+}
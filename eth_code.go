@@ -0,0 +1,222 @@
+package ipldeth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	cid "github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+
+	vm "github.com/ethereum/go-ethereum/core/vm"
+)
+
+// EthCode (raw, codec 0x55, keccak256 multihash), represents the EVM
+// bytecode of a contract account, as pointed by the codeHash of
+// an eth-account-snapshot. The plugin leaves raw blocks to the default
+// decoder, so its paths are only resolved from Go, see GetAccountCode.
+type EthCode struct {
+	cid     *cid.Cid
+	rawdata []byte
+}
+
+// Static (compile time) check that EthCode satisfies the node.Node interface.
+var _ node.Node = (*EthCode)(nil)
+
+/*
+  INPUT
+*/
+
+// FromCode takes the raw EVM bytecode of a contract to return it
+// as an IPLD node, under the cid its account snapshot links to.
+func FromCode(r io.Reader) (*EthCode, error) {
+	rawdata, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeEthCode(rawdataToCid(RawBinary, rawdata), rawdata)
+}
+
+/*
+  OUTPUT
+*/
+
+// DecodeEthCode takes a cid and its raw binary data
+// from IPFS and returns an EthCode object for further processing.
+func DecodeEthCode(c *cid.Cid, b []byte) (*EthCode, error) {
+	return &EthCode{
+		cid:     c,
+		rawdata: b,
+	}, nil
+}
+
+// GetAccountCode follows the codeHash link of the given account through the
+// given NodeGetter, returning the bytecode behind it as an EthCode node.
+// The bytecode is stored as a raw block, which the NodeGetter gives back
+// as any other raw block, so this is the way to get an EthCode out of it.
+// Accounts without code get an empty EthCode, without a fetch.
+func GetAccountCode(ctx context.Context, ng node.NodeGetter, as *EthAccountSnapshot) (*EthCode, error) {
+	c := keccak256ToCid(RawBinary, as.CodeHash)
+	if bytes.Equal(as.CodeHash, emptyCodeHash[:]) {
+		return DecodeEthCode(c, nil)
+	}
+
+	nd, err := ng.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeEthCode(nd.Cid(), nd.RawData())
+}
+
+/*
+  Block INTERFACE
+*/
+
+// RawData returns the bytecode.
+func (ec *EthCode) RawData() []byte {
+	return ec.rawdata
+}
+
+// Cid returns the cid of the bytecode.
+func (ec *EthCode) Cid() *cid.Cid {
+	return ec.cid
+}
+
+// String is a helper for output
+func (ec *EthCode) String() string {
+	return fmt.Sprintf("<EthereumCode %s>", ec.cid)
+}
+
+// Loggable returns in a map the type of IPLD Link.
+func (ec *EthCode) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "eth-code",
+	}
+}
+
+/*
+  Node INTERFACE
+*/
+
+// Resolve resolves a path through this node, stopping at any link boundary
+// and returning the object found as well as the remaining path to traverse
+func (ec *EthCode) Resolve(p []string) (interface{}, []string, error) {
+	if len(p) == 0 {
+		return ec, nil, nil
+	}
+
+	if len(p) > 1 {
+		return nil, nil, fmt.Errorf("unexpected path elements past %s", p[0])
+	}
+
+	switch p[0] {
+	case "jumpdests":
+		return ec.jumpdests(), nil, nil
+	case "opcodes":
+		return ec.opcodes(), nil, nil
+	case "size":
+		return len(ec.rawdata), nil, nil
+	default:
+		return nil, nil, fmt.Errorf("no such link")
+	}
+}
+
+// Tree lists all paths within the object under 'path', and up to the given depth.
+// To list the entire object (similar to `find .`) pass "" and -1
+func (ec *EthCode) Tree(p string, depth int) []string {
+	if p != "" || depth == 0 {
+		return nil
+	}
+	return []string{"jumpdests", "opcodes", "size"}
+}
+
+// ResolveLink is a helper function that calls resolve and asserts the
+// output is a link
+func (ec *EthCode) ResolveLink(p []string) (*node.Link, []string, error) {
+	obj, rest, err := ec.Resolve(p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if lnk, ok := obj.(*node.Link); ok {
+		return lnk, rest, nil
+	}
+
+	return nil, nil, fmt.Errorf("resolved item was not a link")
+}
+
+// Copy will go away. It is here to comply with the interface.
+func (ec *EthCode) Copy() node.Node {
+	panic("dont use this yet")
+}
+
+// Links is a helper function that returns all links within this object
+func (ec *EthCode) Links() []*node.Link {
+	return nil
+}
+
+// Stat will go away. It is here to comply with the interface.
+func (ec *EthCode) Stat() (*node.NodeStat, error) {
+	return &node.NodeStat{}, nil
+}
+
+// Size will go away. It is here to comply with the interface.
+func (ec *EthCode) Size() (uint64, error) {
+	return uint64(len(ec.rawdata)), nil
+}
+
+/*
+  EthCode functions
+*/
+
+// MarshalJSON processes the bytecode into readable JSON format.
+func (ec *EthCode) MarshalJSON() ([]byte, error) {
+	out := map[string]interface{}{
+		"jumpdests": ec.jumpdests(),
+		"opcodes":   ec.opcodes(),
+		"size":      len(ec.rawdata),
+	}
+	return json.Marshal(out)
+}
+
+// opcodes returns the disassembly of the bytecode, one instruction
+// per element, followed by its immediate data in the case of PUSH.
+// A PUSH truncated by the end of the code shows the bytes available.
+func (ec *EthCode) opcodes() []string {
+	out := []string{}
+	for pc := 0; pc < len(ec.rawdata); pc++ {
+		op := vm.OpCode(ec.rawdata[pc])
+		if !op.IsPush() {
+			out = append(out, op.String())
+			continue
+		}
+
+		end := pc + 1 + int(op-vm.PUSH1) + 1
+		if end > len(ec.rawdata) {
+			end = len(ec.rawdata)
+		}
+		out = append(out, fmt.Sprintf("%s 0x%x", op, ec.rawdata[pc+1:end]))
+		pc = end - 1
+	}
+	return out
+}
+
+// jumpdests returns the offsets of the valid jump destinations
+// of the bytecode, this is, the JUMPDEST not within PUSH data.
+func (ec *EthCode) jumpdests() []int {
+	out := []int{}
+	for pc := 0; pc < len(ec.rawdata); pc++ {
+		op := vm.OpCode(ec.rawdata[pc])
+		switch {
+		case op == vm.JUMPDEST:
+			out = append(out, pc)
+		case op.IsPush():
+			pc += int(op - vm.PUSH1 + 1)
+		}
+	}
+	return out
+}
@@ -0,0 +1,176 @@
+package ipldeth
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	node "github.com/ipfs/go-ipld-format"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+/*
+  INPUT
+*/
+
+func TestCodeParsing(t *testing.T) {
+	code := prepareEthCode(t)
+
+	// The cid is the one the codeHash of the account snapshot points to
+	codeHash, err := hex.DecodeString("3e54b3bc3b1e1ebfcf391fac22d07a9fb22a6f78ce35843d7c749c9559b154e7")
+	checkError(err, t)
+	if !code.Cid().Equals(keccak256ToCid(RawBinary, codeHash)) {
+		t.Fatal("Wrong cid")
+	}
+
+	if fmt.Sprintf("%x", code.RawData()) != "6080604052348015600f57600080fd5b50605b61ff" {
+		t.Fatal("Wrong rawdata")
+	}
+}
+
+func TestGetAccountCode(t *testing.T) {
+	code := prepareEthCode(t)
+	ng := mapNodeGetter{code.Cid().String(): code}
+
+	as := &EthAccountSnapshot{EthAccount: &EthAccount{
+		CodeHash: crypto.Keccak256(code.RawData()),
+	}}
+	output, err := GetAccountCode(context.Background(), ng, as)
+	checkError(err, t)
+	if !output.Cid().Equals(code.Cid()) || !bytes.Equal(output.RawData(), code.RawData()) {
+		t.Fatal("Wrong code")
+	}
+
+	// An account without code needs no fetch
+	as.CodeHash = emptyCodeHash[:]
+	output, err = GetAccountCode(context.Background(), mapNodeGetter{}, as)
+	checkError(err, t)
+	if len(output.RawData()) != 0 {
+		t.Fatal("Expected no code")
+	}
+
+	// Nor can the code of another account be found
+	as.CodeHash = crypto.Keccak256([]byte{0x00})
+	_, err = GetAccountCode(context.Background(), ng, as)
+	if err != node.ErrNotFound {
+		t.Fatalf("Expected node.ErrNotFound\r\ngot %v", err)
+	}
+}
+
+/*
+  Block INTERFACE
+*/
+
+func TestEthCodeLoggable(t *testing.T) {
+	code := prepareEthCode(t)
+
+	l := code.Loggable()
+	if _, ok := l["type"]; !ok {
+		t.Fatal("Loggable map expected the field 'type'")
+	}
+
+	if l["type"] != "eth-code" {
+		t.Fatal("Wrong Loggable 'type' value")
+	}
+}
+
+/*
+  Node INTERFACE
+*/
+
+func TestEthCodeResolve(t *testing.T) {
+	code := prepareEthCode(t)
+
+	obj, rest, err := code.Resolve([]string{})
+	checkError(err, t)
+	if obj.(*EthCode) != code {
+		t.Fatal("Should have returned the same eth-code object")
+	}
+	if rest != nil {
+		t.Fatal("rest should be nil")
+	}
+
+	obj, _, err = code.Resolve([]string{"size"})
+	checkError(err, t)
+	if obj.(int) != 21 {
+		t.Fatal("Wrong size")
+	}
+
+	// The 0x5b pushed as data is not a jump destination
+	obj, _, err = code.Resolve([]string{"jumpdests"})
+	checkError(err, t)
+	if fmt.Sprintf("%v", obj) != "[15]" {
+		t.Fatalf("Wrong jumpdests %v", obj)
+	}
+
+	// The PUSH2 at the end of the code is truncated
+	obj, _, err = code.Resolve([]string{"opcodes"})
+	checkError(err, t)
+	opcodes := strings.Join(obj.([]string), " ")
+	if opcodes != "PUSH1 0x80 PUSH1 0x40 MSTORE CALLVALUE DUP1 ISZERO PUSH1 0x0f JUMPI "+
+		"PUSH1 0x00 DUP1 REVERT JUMPDEST POP PUSH1 0x5b PUSH2 0xff" {
+		t.Fatalf("Wrong opcodes %v", opcodes)
+	}
+
+	_, _, err = code.Resolve([]string{"size", "extra"})
+	if err == nil || err.Error() != "unexpected path elements past size" {
+		t.Fatal("Wrong error")
+	}
+	_, _, err = code.Resolve([]string{"bytecode"})
+	if err == nil || err.Error() != "no such link" {
+		t.Fatal("Wrong error")
+	}
+}
+
+func TestEthCodeTree(t *testing.T) {
+	code := prepareEthCode(t)
+
+	if code.Tree("non-empty-string", 1) != nil {
+		t.Fatal("Expected nil to be returned")
+	}
+	if code.Tree("", 0) != nil {
+		t.Fatal("Expected nil to be returned")
+	}
+	if len(code.Tree("", 1)) != 3 {
+		t.Fatal("Wrong number of elements")
+	}
+}
+
+func TestEthCodeJSONMarshal(t *testing.T) {
+	code := prepareEthCode(t)
+
+	jsonOutput, err := code.MarshalJSON()
+	checkError(err, t)
+
+	var data map[string]interface{}
+	err = json.Unmarshal(jsonOutput, &data)
+	checkError(err, t)
+
+	if parseFloat(data["size"]) != "21" {
+		t.Fatal("Wrong size")
+	}
+	if len(data["opcodes"].([]interface{})) != 15 {
+		t.Fatal("Wrong number of opcodes")
+	}
+}
+
+/*
+  AUXILIARS
+*/
+
+// prepareEthCode returns the usual solidity prologue, followed
+// by a PUSH1 of a JUMPDEST byte and a truncated PUSH2.
+func prepareEthCode(t *testing.T) *EthCode {
+	b, err := hex.DecodeString("6080604052348015600f57600080fd5b50605b61ff")
+	checkError(err, t)
+
+	code, err := FromCode(bytes.NewReader(b))
+	checkError(err, t)
+
+	return code
+}
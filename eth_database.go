@@ -0,0 +1,193 @@
+package ipldeth
+
+import (
+	"context"
+	"fmt"
+
+	block "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// IpldDatabase is a read only go-ethereum database over IPLD blocks,
+// for the go-ethereum trie and state packages to read the state stored
+// in IPFS. The 32 bytes keccak256 keys go-ethereum asks for are turned
+// into cids, trying every codec the database was given, in order.
+// Callers knowing the codec of the block they want use GetCodec instead.
+type IpldDatabase struct {
+	ctx    context.Context
+	get    func(*cid.Cid) ([]byte, error)
+	codecs []uint64
+}
+
+// Blockstore is the part of an IPFS blockstore IpldDatabase reads from.
+// A missing block is told by node.ErrNotFound, any other error is one
+// of the blockstore itself.
+type Blockstore interface {
+	Get(*cid.Cid) (block.Block, error)
+}
+
+// Static (compile time) check that IpldDatabase satisfies the
+// ethdb.Database and trie.Database interfaces.
+var (
+	_ ethdb.Database = (*IpldDatabase)(nil)
+	_ trie.Database  = (*IpldDatabase)(nil)
+)
+
+// defaultDatabaseCodecs are the codecs of the blocks go-ethereum reads
+// to walk the state: its trie nodes, and the code of the contracts.
+var defaultDatabaseCodecs = []uint64{MEthStateTrie, MEthStorageTrie, RawBinary}
+
+// errReadOnly is returned by any attempt to write to an IpldDatabase.
+var errReadOnly = fmt.Errorf("ipld database is read only")
+
+/*
+  INPUT
+*/
+
+// NewNodeGetterDatabase returns an IpldDatabase reading from the given
+// NodeGetter. Codecs defaults to the state and storage trie ones, and to
+// raw, for the contract code.
+//
+// The NodeGetter should be an offline one, i.e. the DAG service of an
+// offline node: a key is looked up under every codec until it is found,
+// and every miss of an online NodeGetter is a search of the network.
+// Every fetch is done with the given context, once it is canceled the
+// database returns its error.
+func NewNodeGetterDatabase(ctx context.Context, ng node.NodeGetter, codecs ...uint64) *IpldDatabase {
+	return newIpldDatabase(ctx, func(c *cid.Cid) ([]byte, error) {
+		nd, err := ng.Get(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		return nd.RawData(), nil
+	}, codecs)
+}
+
+// NewBlockstoreDatabase returns an IpldDatabase reading from the given
+// blockstore. Codecs defaults to the state and storage trie ones, and to
+// raw, for the contract code.
+func NewBlockstoreDatabase(bs Blockstore, codecs ...uint64) *IpldDatabase {
+	return newIpldDatabase(context.Background(), func(c *cid.Cid) ([]byte, error) {
+		b, err := bs.Get(c)
+		if err != nil {
+			return nil, err
+		}
+		return b.RawData(), nil
+	}, codecs)
+}
+
+/*
+  DATABASE INTERFACE
+*/
+
+// Get returns the block of the given keccak256 hash, from the first
+// codec it is found under.
+func (db *IpldDatabase) Get(key []byte) ([]byte, error) {
+	var err error = errNotFound(key)
+	for _, codec := range db.codecs {
+		var rawdata []byte
+		rawdata, err = db.GetCodec(codec, key)
+		if err == nil {
+			return rawdata, nil
+		}
+
+		// No use trying the other codecs
+		if cerr := db.ctx.Err(); cerr != nil {
+			return nil, cerr
+		}
+		if !isNotFound(err) {
+			return nil, err
+		}
+	}
+
+	return nil, err
+}
+
+// GetCodec returns the block of the given keccak256 hash under the given
+// codec, without trying any other, i.e. RawBinary for the code of a contract.
+func (db *IpldDatabase) GetCodec(codec uint64, key []byte) ([]byte, error) {
+	if len(key) != common.HashLength {
+		return nil, errNotFound(key)
+	}
+
+	return db.get(commonHashToCid(codec, common.BytesToHash(key)))
+}
+
+// Has tells whether there is a block of the given keccak256 hash. Only a
+// missing block gives false and no error, a failing lookup, or a canceled
+// context, gives its error.
+func (db *IpldDatabase) Has(key []byte) (bool, error) {
+	_, err := db.Get(key)
+	if err == nil {
+		return true, nil
+	}
+	if isNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// Put is not supported, the database is read only.
+func (db *IpldDatabase) Put(key []byte, value []byte) error {
+	return errReadOnly
+}
+
+// Delete is not supported, the database is read only.
+func (db *IpldDatabase) Delete(key []byte) error {
+	return errReadOnly
+}
+
+// Close complies with the ethdb.Database interface.
+func (db *IpldDatabase) Close() {}
+
+// NewBatch returns a batch failing to write, the database is read only.
+func (db *IpldDatabase) NewBatch() ethdb.Batch {
+	return readOnlyBatch{}
+}
+
+/*
+  AUXILIARS
+*/
+
+// newIpldDatabase returns an IpldDatabase reading with the given function,
+// under the given context.
+func newIpldDatabase(ctx context.Context, get func(*cid.Cid) ([]byte, error), codecs []uint64) *IpldDatabase {
+	if len(codecs) == 0 {
+		codecs = defaultDatabaseCodecs
+	}
+
+	return &IpldDatabase{
+		ctx:    ctx,
+		get:    get,
+		codecs: codecs,
+	}
+}
+
+// errNotFound is the error of a key there is no block of.
+type errNotFound []byte
+
+func (e errNotFound) Error() string {
+	return fmt.Sprintf("not found: %x", []byte(e))
+}
+
+// isNotFound tells whether the error is one of a missing block, rather
+// than one of the lookup itself.
+func isNotFound(err error) bool {
+	if err == node.ErrNotFound {
+		return true
+	}
+	_, ok := err.(errNotFound)
+	return ok
+}
+
+// readOnlyBatch is the ethdb.Batch of an IpldDatabase.
+type readOnlyBatch struct{}
+
+func (readOnlyBatch) Put(key []byte, value []byte) error { return errReadOnly }
+func (readOnlyBatch) ValueSize() int                     { return 0 }
+func (readOnlyBatch) Write() error                       { return errReadOnly }
@@ -0,0 +1,192 @@
+package ipldeth
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	block "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+/*
+  DATABASE INTERFACE
+*/
+
+func TestNodeGetterDatabaseTrie(t *testing.T) {
+	ng, root := prepareGenesisNodeGetter(t)
+	db := NewNodeGetterDatabase(context.Background(), ng)
+
+	stateRoot, err := cidToHash(root)
+	checkError(err, t)
+
+	// go-ethereum walks the state trie by itself
+	st, err := trie.New(stateRoot, db)
+	checkError(err, t)
+
+	contract := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	rawdata, err := st.TryGet(crypto.Keccak256(contract[:]))
+	checkError(err, t)
+
+	var account EthAccount
+	checkError(rlp.DecodeBytes(rawdata, &account), t)
+	if account.Nonce != 1 {
+		t.Fatal("Wrong contract account")
+	}
+
+	// And the storage trie of the contract
+	sr, err := trie.New(common.BytesToHash(account.Root), db)
+	checkError(err, t)
+
+	rawdata, err = sr.TryGet(crypto.Keccak256(make([]byte, 32)))
+	checkError(err, t)
+	if !bytes.Equal(rawdata, []byte{0x2a}) {
+		t.Fatalf("Wrong slot value %x", rawdata)
+	}
+}
+
+func TestNodeGetterDatabaseCode(t *testing.T) {
+	fi, err := os.Open("test_data/eth-genesis-json-private")
+	checkError(err, t)
+
+	stateRoot := common.HexToHash("0x7377ff8b5e915adb7687adf17e88697e209d8ef510b921142a1b85d95e7963ec")
+	_, accounts, _, codes, err := FromGenesisJSON(fi, stateRoot)
+	checkError(err, t)
+
+	ng := make(mapNodeGetter)
+	for _, ec := range codes {
+		ng[ec.Cid().String()] = ec
+	}
+	db := NewNodeGetterDatabase(context.Background(), ng)
+
+	code, err := db.Get(accounts[0].CodeHash)
+	checkError(err, t)
+	if !bytes.Equal(code, codes[0].RawData()) {
+		t.Fatal("Wrong code")
+	}
+
+	// Only the given codecs are tried
+	db = NewNodeGetterDatabase(context.Background(), ng, MEthStateTrie)
+	ok, err := db.Has(accounts[0].CodeHash)
+	checkError(err, t)
+	if ok {
+		t.Fatal("Expected the code not to be found as a state trie node")
+	}
+
+	// Unless the codec is asked for
+	code, err = db.GetCodec(RawBinary, accounts[0].CodeHash)
+	checkError(err, t)
+	if !bytes.Equal(code, codes[0].RawData()) {
+		t.Fatal("Wrong code")
+	}
+	if _, err := db.GetCodec(MEthStorageTrie, accounts[0].CodeHash); err == nil {
+		t.Fatal("Expected the code not to be found as a storage trie node")
+	}
+}
+
+func TestNodeGetterDatabaseCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	db := NewNodeGetterDatabase(ctx, make(mapNodeGetter))
+	if _, err := db.Get(make([]byte, 32)); err != context.Canceled {
+		t.Fatalf("Expected the context error, got %v", err)
+	}
+	if ok, err := db.Has(make([]byte, 32)); ok || err != context.Canceled {
+		t.Fatalf("Expected the context error, got %v", err)
+	}
+}
+
+func TestIpldDatabaseHasErrors(t *testing.T) {
+	// Missing blocks are no error
+	db := NewBlockstoreDatabase(make(mapBlockstore))
+	for _, key := range [][]byte{make([]byte, 32), []byte("secure-key-")} {
+		ok, err := db.Has(key)
+		checkError(err, t)
+		if ok {
+			t.Fatalf("Expected no block for %x", key)
+		}
+	}
+
+	// The ones of the blockstore are
+	db = NewBlockstoreDatabase(failingBlockstore{})
+	if ok, err := db.Has(make([]byte, 32)); ok || err != errFailingBlockstore {
+		t.Fatalf("Expected the blockstore error, got %v", err)
+	}
+}
+
+func TestBlockstoreDatabase(t *testing.T) {
+	ng, root := prepareGenesisNodeGetter(t)
+
+	bs := make(mapBlockstore)
+	for k, n := range ng {
+		b, err := block.NewBlockWithCid(n.RawData(), n.Cid())
+		checkError(err, t)
+		bs[k] = b
+	}
+	db := NewBlockstoreDatabase(bs)
+
+	stateRoot, err := cidToHash(root)
+	checkError(err, t)
+
+	rawdata, err := db.Get(stateRoot[:])
+	checkError(err, t)
+	if !bytes.Equal(rawdata, ng[root.String()].RawData()) {
+		t.Fatal("Wrong state root node")
+	}
+
+	if _, err := db.Get([]byte("secure-key-")); err == nil {
+		t.Fatal("Expected an error for a key not being a hash")
+	}
+}
+
+func TestIpldDatabaseReadOnly(t *testing.T) {
+	db := NewNodeGetterDatabase(context.Background(), make(mapNodeGetter))
+
+	if err := db.Put(make([]byte, 32), []byte{1}); err != errReadOnly {
+		t.Fatal("Expected Put to fail")
+	}
+	if err := db.Delete(make([]byte, 32)); err != errReadOnly {
+		t.Fatal("Expected Delete to fail")
+	}
+
+	batch := db.NewBatch()
+	if err := batch.Put(make([]byte, 32), []byte{1}); err != errReadOnly {
+		t.Fatal("Expected batch Put to fail")
+	}
+	if err := batch.Write(); err != errReadOnly {
+		t.Fatal("Expected batch Write to fail")
+	}
+}
+
+/*
+  AUXILIARS
+*/
+
+// mapBlockstore is a Blockstore over a map of blocks keyed by cid.
+type mapBlockstore map[string]block.Block
+
+func (m mapBlockstore) Get(c *cid.Cid) (block.Block, error) {
+	b, ok := m[c.String()]
+	if !ok {
+		return nil, node.ErrNotFound
+	}
+	return b, nil
+}
+
+var errFailingBlockstore = fmt.Errorf("blockstore is closed")
+
+// failingBlockstore is a Blockstore failing every read.
+type failingBlockstore struct{}
+
+func (failingBlockstore) Get(c *cid.Cid) (block.Block, error) {
+	return nil, errFailingBlockstore
+}
@@ -0,0 +1,393 @@
+package ipldeth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+
+	"github.com/golang/snappy"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Era1Reader reads the blocks of an era1 archive, the pre-merge history
+// format, one at a time. The archive is an e2store file holding, for each
+// block, its snappy compressed header, body and receipts, and its total
+// difficulty. It ends with the accumulator root of the blocks, and an index.
+//
+// Bodies and receipts are checked against their headers as they are read.
+// The accumulator can only be checked at the end, so the blocks are not to
+// be trusted until Next returns io.EOF.
+//
+//	er := NewEra1Reader(f)
+//	for {
+//		eb, err := er.Next()
+//		if err == io.EOF {
+//			break
+//		}
+//		if err != nil {
+//			return err // drop the blocks read so far
+//		}
+//		...
+//	}
+type Era1Reader struct {
+	r   io.Reader
+	err error
+
+	started     bool
+	start       *big.Int
+	records     []era1HeaderRecord
+	accumulator common.Hash
+}
+
+// e2store entry types of an era1 archive
+const (
+	e2Version            = 0x3265
+	e2CompressedHeader   = 0x03
+	e2CompressedBody     = 0x04
+	e2CompressedReceipts = 0x05
+	e2TotalDifficulty    = 0x06
+	e2Accumulator        = 0x07
+	e2BlockIndex         = 0x3266
+)
+
+// era1MaxBlocks is the number of blocks of an era1 archive, and the
+// limit of the list its accumulator is the root of.
+const era1MaxBlocks = 8192
+
+// e2MaxEntrySize bounds the entries of the archives read, compressed or
+// not, not to allocate whatever a corrupted length asks for.
+const e2MaxEntrySize = 32 << 20
+
+// era1HeaderRecord is an element of the accumulator of an era1 archive.
+type era1HeaderRecord struct {
+	hash common.Hash
+	td   *big.Int
+}
+
+/*
+  INPUT
+*/
+
+// NewEra1Reader returns an Era1Reader over the given era1 archive.
+func NewEra1Reader(r io.Reader) *Era1Reader {
+	return &Era1Reader{r: r}
+}
+
+// Next returns the nodes of the next block of the archive, or io.EOF once
+// all of them were read and the accumulator of the archive was verified.
+// Any error ends the reading.
+func (er *Era1Reader) Next() (*BlockNodes, error) {
+	if er.err != nil {
+		return nil, er.err
+	}
+
+	eb, err := er.next()
+	if err != nil {
+		er.err = err
+		return nil, err
+	}
+	return eb, nil
+}
+
+// Accumulator returns the accumulator root of the archive, once it was
+// verified, to be checked against the trusted roots of the history.
+func (er *Era1Reader) Accumulator() common.Hash {
+	return er.accumulator
+}
+
+/*
+  AUXILIARS
+*/
+
+// next reads the entries of the archive up to the end of the next block.
+func (er *Era1Reader) next() (*BlockNodes, error) {
+	if !er.started {
+		typ, _, err := readE2Entry(er.r)
+		if err == io.EOF || (err == nil && typ != e2Version) {
+			return nil, fmt.Errorf("not an era1 archive")
+		}
+		if err != nil {
+			return nil, err
+		}
+		er.started = true
+	}
+
+	for {
+		typ, data, err := readE2Entry(er.r)
+		if err == io.EOF {
+			return nil, fmt.Errorf("era1 archive ends without its accumulator")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch typ {
+		case e2CompressedHeader:
+			return er.readBlock(data)
+		case e2Accumulator:
+			if err := er.verify(data); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+		// Other entries hold nothing we need
+	}
+}
+
+// readBlock reads the entries of the block of the given header.
+func (er *Era1Reader) readBlock(compressedHeader []byte) (*BlockNodes, error) {
+	if len(er.records) == era1MaxBlocks {
+		return nil, fmt.Errorf("too many blocks in era1 archive")
+	}
+
+	header, err := decompressE2Entry(compressedHeader)
+	if err != nil {
+		return nil, err
+	}
+	body, err := readCompressedE2Entry(er.r, e2CompressedBody)
+	if err != nil {
+		return nil, err
+	}
+	receipts, err := readCompressedE2Entry(er.r, e2CompressedReceipts)
+	if err != nil {
+		return nil, err
+	}
+	typ, td, err := readE2Entry(er.r)
+	if err == io.EOF || (err == nil && typ != e2TotalDifficulty) {
+		return nil, fmt.Errorf("missing total difficulty entry")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(td) != 32 {
+		return nil, fmt.Errorf("invalid total difficulty entry")
+	}
+
+	eb, err := processEra1Body(header, body)
+	if err != nil {
+		return nil, fmt.Errorf("block %d of the era1 archive: %v", len(er.records), err)
+	}
+	eb.Receipts, eb.ReceiptTrieNodes, eb.Logs, eb.LogTrieNodes, err = processEra1Receipts(eb.Block, receipts)
+	if err != nil {
+		return nil, fmt.Errorf("block %v: %v", eb.Block.Number, err)
+	}
+	eb.TotalDifficulty = new(big.Int).SetBytes(reverseBytes(td))
+
+	// The blocks of an archive follow each other
+	if n := len(er.records); n == 0 {
+		er.start = new(big.Int).Set(eb.Block.Number)
+	} else if eb.Block.ParentHash != er.records[n-1].hash {
+		return nil, fmt.Errorf("block %v does not follow the previous block of the archive", eb.Block.Number)
+	}
+
+	er.records = append(er.records, era1HeaderRecord{
+		hash: eb.Block.Hash(),
+		td:   eb.TotalDifficulty,
+	})
+	return eb, nil
+}
+
+// verify checks the accumulator, and the index that follows it,
+// against the blocks read.
+func (er *Era1Reader) verify(accumulator []byte) error {
+	if len(accumulator) != common.HashLength {
+		return fmt.Errorf("invalid accumulator entry")
+	}
+	root := era1AccumulatorRoot(er.records)
+	if !bytes.Equal(root[:], accumulator) {
+		return fmt.Errorf("wrong accumulator computed, expected %x, got %x", accumulator, root)
+	}
+
+	typ, index, err := readE2Entry(er.r)
+	if err == io.EOF || (err == nil && typ != e2BlockIndex) {
+		return fmt.Errorf("missing block index entry")
+	}
+	if err != nil {
+		return err
+	}
+
+	// starting-number | offsets... | count
+	if len(index) != 16+8*len(er.records) {
+		return fmt.Errorf("invalid block index entry")
+	}
+	start := binary.LittleEndian.Uint64(index)
+	count := binary.LittleEndian.Uint64(index[len(index)-8:])
+	if int(count) != len(er.records) || (count != 0 && er.start.Cmp(new(big.Int).SetUint64(start)) != 0) {
+		return fmt.Errorf("block index does not match the blocks of the archive")
+	}
+
+	if _, _, err := readE2Entry(er.r); err != io.EOF {
+		return fmt.Errorf("unexpected data after the block index")
+	}
+
+	er.accumulator = root
+	return nil
+}
+
+// processEra1Body checks the body of an era1 block against its header,
+// returning the IPLD nodes of both, as FromBlockRLP does.
+func processEra1Body(header, body []byte) (*BlockNodes, error) {
+	var b struct {
+		Transactions []rlp.RawValue
+		Uncles       []rlp.RawValue
+	}
+	if err := rlp.DecodeBytes(body, &b); err != nil {
+		return nil, err
+	}
+
+	rawdata := getRLP(&objRLPBlockBody{
+		Header:       header,
+		Transactions: b.Transactions,
+		Uncles:       b.Uncles,
+	})
+	return FromBlockRLP(bytes.NewReader(rawdata))
+}
+
+// processEra1Receipts checks the receipts of an era1 block against its
+// header, returning their IPLD nodes, as FromReceipts does.
+func processEra1Receipts(b *EthBlock, receipts []byte) ([]*EthTxReceipt, []*EthTxReceiptTrie, []*EthLog, []*EthLogTrie, error) {
+	var raws []rlp.RawValue
+	if err := rlp.DecodeBytes(receipts, &raws); err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	var rcts []*EthTxReceipt
+	for _, raw := range raws {
+		rct, err := receiptFromListRLP(raw)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		rcts = append(rcts, rct)
+	}
+
+	return processReceipts(rcts, b.ReceiptHash[:])
+}
+
+// receiptFromListRLP takes a receipt, as found in the receipts of an era1
+// block, to return its eth-tx-receipt node. Typed receipts are wrapped into
+// an RLP string there, like the typed transactions of a block body.
+func receiptFromListRLP(raw rlp.RawValue) (*EthTxReceipt, error) {
+	kind, content, _, err := rlp.Split(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	rawdata := []byte(raw)
+	if kind != rlp.List {
+		rawdata = content
+	}
+
+	return DecodeEthTxReceipt(rawdataToCid(MEthTxReceipt, rawdata), rawdata)
+}
+
+// era1AccumulatorRoot returns the SSZ hash tree root of the given header
+// records, as a list of era1MaxBlocks elements.
+func era1AccumulatorRoot(records []era1HeaderRecord) common.Hash {
+	layer := make([][]byte, len(records))
+	for i, r := range records {
+		var td [32]byte
+		copy(td[:], reverseBytes(common.LeftPadBytes(r.td.Bytes(), 32)))
+		layer[i] = sha256Concat(r.hash[:], td[:])
+	}
+
+	// Merkleize, padding each layer with the root of an empty subtree
+	zero := make([]byte, 32)
+	for width := era1MaxBlocks; width > 1; width /= 2 {
+		if len(layer)%2 == 1 {
+			layer = append(layer, zero)
+		}
+		next := make([][]byte, len(layer)/2)
+		for i := range next {
+			next[i] = sha256Concat(layer[2*i], layer[2*i+1])
+		}
+		layer = next
+		zero = sha256Concat(zero, zero)
+	}
+	root := zero
+	if len(layer) != 0 {
+		root = layer[0]
+	}
+
+	// Mix in the length of the list
+	var length [32]byte
+	binary.LittleEndian.PutUint64(length[:], uint64(len(records)))
+	return common.BytesToHash(sha256Concat(root, length[:]))
+}
+
+// readE2Entry reads the next entry of an e2store file. It returns io.EOF
+// only when the file ends right before the entry.
+func readE2Entry(r io.Reader) (uint16, []byte, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+
+	// type | length | reserved, little endian
+	typ := binary.LittleEndian.Uint16(header[0:2])
+	length := binary.LittleEndian.Uint32(header[2:6])
+	if header[6] != 0 || header[7] != 0 {
+		return 0, nil, fmt.Errorf("invalid e2store entry header")
+	}
+	if length > e2MaxEntrySize {
+		return 0, nil, fmt.Errorf("invalid e2store entry length %d", length)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+	return typ, data, nil
+}
+
+// readCompressedE2Entry reads the next entry of an e2store file, which
+// must be of the given type, and decompresses it.
+func readCompressedE2Entry(r io.Reader, typ uint16) ([]byte, error) {
+	t, data, err := readE2Entry(r)
+	if err == io.EOF || (err == nil && t != typ) {
+		return nil, fmt.Errorf("missing e2store entry of type %x", typ)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decompressE2Entry(data)
+}
+
+// decompressE2Entry returns the content of a snappy framed entry.
+func decompressE2Entry(data []byte) ([]byte, error) {
+	r := io.LimitReader(snappy.NewReader(bytes.NewReader(data)), e2MaxEntrySize+1)
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > e2MaxEntrySize {
+		return nil, fmt.Errorf("e2store entry larger than %d bytes once decompressed", e2MaxEntrySize)
+	}
+	return content, nil
+}
+
+// sha256Concat returns the sha256 hash of the concatenation of a and b.
+func sha256Concat(a, b []byte) []byte {
+	h := sha256.New()
+	h.Write(a)
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+// reverseBytes returns a reversed copy of b, to turn
+// little endian integers into big endian ones.
+func reverseBytes(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[len(b)-1-i] = b[i]
+	}
+	return out
+}
@@ -0,0 +1,349 @@
+package ipldeth
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/golang/snappy"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+/*
+  INPUT
+*/
+
+func TestEra1Reader(t *testing.T) {
+	blocks := prepareEra1Blocks(t)
+	er := NewEra1Reader(bytes.NewReader(buildEra1(t, blocks, nil)))
+
+	eb, err := er.Next()
+	checkError(err, t)
+	if eb.Block.Number.Int64() != 0 || len(eb.Txs) != 0 || len(eb.Receipts) != 0 {
+		t.Fatal("Wrong first block")
+	}
+
+	eb, err = er.Next()
+	checkError(err, t)
+	if eb.Block.Number.Int64() != 1 {
+		t.Fatalf("Wrong block number\r\nexpected %d\r\ngot %s", 1, eb.Block.Number)
+	}
+	if len(eb.Txs) != 1 || len(eb.TxTrieNodes) != 1 {
+		t.Fatal("Wrong transactions")
+	}
+	if len(eb.Receipts) != 1 || len(eb.ReceiptTrieNodes) != 1 || len(eb.Logs) != 2 {
+		t.Fatal("Wrong receipts")
+	}
+	if eb.TotalDifficulty.Cmp(big.NewInt(0x800)) != 0 {
+		t.Fatalf("Wrong total difficulty\r\nexpected %d\r\ngot %s", 0x800, eb.TotalDifficulty)
+	}
+
+	if _, err = er.Next(); err != io.EOF {
+		t.Fatalf("Expected io.EOF\r\ngot %v", err)
+	}
+
+	expected := era1AccumulatorRoot([]era1HeaderRecord{
+		{hash: blocks[0].block.Hash(), td: blocks[0].td},
+		{hash: blocks[1].block.Hash(), td: blocks[1].td},
+	})
+	if er.Accumulator() != expected {
+		t.Fatalf("Wrong accumulator\r\nexpected %x\r\ngot %x", expected, er.Accumulator())
+	}
+}
+
+func TestEra1ReaderMainnet(t *testing.T) {
+	// The first blocks of mainnet, as the archive of its first era starts.
+	// They have no transactions nor uncles.
+	type body struct {
+		Transactions []rlp.RawValue
+		Uncles       []rlp.RawValue
+	}
+	var blocks []era1TestBlock
+	for i, td := range []int64{0x400000000, 0x7ff800000, 0xbfe801000} {
+		blocks = append(blocks, era1TestBlock{
+			block:    prepareDecodedEthBlock(fmt.Sprintf("test_data/eth-block-header-rlp-%d", i), t),
+			body:     getRLP(&body{}),
+			receipts: getRLP([]rlp.RawValue{}),
+			td:       big.NewInt(td),
+		})
+	}
+	er := NewEra1Reader(bytes.NewReader(buildEra1(t, blocks, nil)))
+
+	for i, hash := range []string{
+		"0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3",
+		"0x88e96d4537bea4d9c05d12549907b32561d3bf31f45aae734cdc119f13406cb6",
+		"0xb495a1d7e6663152ae92708da4843337b958146015a2802f4193a410044698c9",
+	} {
+		eb, err := er.Next()
+		checkError(err, t)
+		if !eb.Block.Cid().Equals(commonHashToCid(MEthBlock, common.HexToHash(hash))) {
+			t.Fatalf("Wrong block %d\r\nexpected %s\r\ngot %s", i, hash, eb.Block.Hash().Hex())
+		}
+		if eb.TotalDifficulty.Cmp(blocks[i].td) != 0 {
+			t.Fatalf("Wrong total difficulty of block %d", i)
+		}
+	}
+	if _, err := er.Next(); err != io.EOF {
+		t.Fatalf("Expected io.EOF\r\ngot %v", err)
+	}
+
+	// The accumulator of the whole era covers 8192 blocks, the one of
+	// these three was computed with another SSZ implementation
+	expected := "0xe242814b90ed3950e13aac7e56ce116540c71b41d1516605aada26c6c07cc491"
+	if er.Accumulator().Hex() != expected {
+		t.Fatalf("Wrong accumulator\r\nexpected %s\r\ngot %s", expected, er.Accumulator().Hex())
+	}
+}
+
+func TestEra1ReaderNotAnArchive(t *testing.T) {
+	er := NewEra1Reader(bytes.NewReader([]byte{0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}))
+	_, err := er.Next()
+	if err == nil || err.Error() != "not an era1 archive" {
+		t.Fatalf("Expected error 'not an era1 archive'\r\ngot %v", err)
+	}
+}
+
+func TestEra1ReaderWrongAccumulator(t *testing.T) {
+	blocks := prepareEra1Blocks(t)
+	er := NewEra1Reader(bytes.NewReader(buildEra1(t, blocks, make([]byte, 32))))
+
+	for i := 0; i < 2; i++ {
+		_, err := er.Next()
+		checkError(err, t)
+	}
+
+	_, err := er.Next()
+	if err == nil || !strings.HasPrefix(err.Error(), "wrong accumulator computed") {
+		t.Fatalf("Expected a wrong accumulator error\r\ngot %v", err)
+	}
+	if er.Accumulator() != (common.Hash{}) {
+		t.Fatal("The accumulator was not verified")
+	}
+}
+
+func TestEra1ReaderWrongReceipts(t *testing.T) {
+	blocks := prepareEra1Blocks(t)
+	blocks[1].receipts = getRLP([]rlp.RawValue{})
+	er := NewEra1Reader(bytes.NewReader(buildEra1(t, blocks, nil)))
+
+	_, err := er.Next()
+	checkError(err, t)
+
+	_, err = er.Next()
+	if err == nil || err.Error() != "block 1: wrong receipt hash computed" {
+		t.Fatalf("Expected error 'block 1: wrong receipt hash computed'\r\ngot %v", err)
+	}
+
+	// The error sticks
+	if _, err2 := er.Next(); err2 != err {
+		t.Fatalf("Expected the same error\r\nexpected %v\r\ngot %v", err, err2)
+	}
+}
+
+func TestEra1ReaderTruncated(t *testing.T) {
+	archive := buildEra1(t, prepareEra1Blocks(t), nil)
+	er := NewEra1Reader(bytes.NewReader(archive[:len(archive)-10]))
+
+	for i := 0; i < 2; i++ {
+		_, err := er.Next()
+		checkError(err, t)
+	}
+
+	if _, err := er.Next(); err == nil || err == io.EOF {
+		t.Fatalf("Expected an error on the truncated archive\r\ngot %v", err)
+	}
+}
+
+func TestEra1ReaderTypedReceipts(t *testing.T) {
+	rawBlock, err := ioutil.ReadFile("test_data/eth-block-body-rlp-london")
+	checkError(err, t)
+	receipts, err := ioutil.ReadFile("test_data/eth-block-receipts-rlp-london")
+	checkError(err, t)
+
+	// header | transactions | uncles
+	var parts []rlp.RawValue
+	checkError(rlp.DecodeBytes(rawBlock, &parts), t)
+	header, err := DecodeEthBlock(rawdataToCid(MEthBlock, parts[0]), parts[0])
+	checkError(err, t)
+
+	blocks := []era1TestBlock{
+		{
+			block:    header,
+			body:     getRLP(parts[1:]),
+			receipts: receipts,
+			td:       big.NewInt(0x400),
+		},
+	}
+	er := NewEra1Reader(bytes.NewReader(buildEra1(t, blocks, nil)))
+
+	eb, err := er.Next()
+	checkError(err, t)
+	if len(eb.Receipts) != 4 {
+		t.Fatalf("Wrong number of receipts\r\nexpected %d\r\ngot %d", 4, len(eb.Receipts))
+	}
+	for i, rct := range eb.Receipts {
+		if rct.Type() != eb.Txs[i].Type() {
+			t.Fatalf("Wrong type of receipt %d\r\nexpected %d\r\ngot %d", i, eb.Txs[i].Type(), rct.Type())
+		}
+	}
+
+	if _, err = er.Next(); err != io.EOF {
+		t.Fatalf("Expected io.EOF\r\ngot %v", err)
+	}
+}
+
+func TestEra1ReaderEntryTooLarge(t *testing.T) {
+	var header [8]byte
+	binary.LittleEndian.PutUint16(header[0:2], e2Version)
+	binary.LittleEndian.PutUint32(header[2:6], e2MaxEntrySize+1)
+
+	er := NewEra1Reader(bytes.NewReader(header[:]))
+	_, err := er.Next()
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid e2store entry length") {
+		t.Fatalf("Expected an invalid length error\r\ngot %v", err)
+	}
+}
+
+/*
+  AUXILIARS
+*/
+
+func TestEra1AccumulatorRoot(t *testing.T) {
+	// The expected roots were computed with another SSZ implementation
+	root := era1AccumulatorRoot(nil)
+	expected := "0x4a8c3a07c8d23adc5bac61157555c3c784d53d9bc110c1370809bd23cd93777d"
+	if root.Hex() != expected {
+		t.Fatalf("Wrong empty accumulator\r\nexpected %s\r\ngot %s", expected, root.Hex())
+	}
+
+	root = era1AccumulatorRoot([]era1HeaderRecord{
+		{hash: common.BytesToHash(bytes.Repeat([]byte{0x11}, 32)), td: big.NewInt(1)},
+		{hash: common.BytesToHash(bytes.Repeat([]byte{0x22}, 32)), td: big.NewInt(3)},
+	})
+	expected = "0x75e0488805371ba2b929905be4a99c7d449f197998536cb156f0525a7d86ae66"
+	if root.Hex() != expected {
+		t.Fatalf("Wrong accumulator\r\nexpected %s\r\ngot %s", expected, root.Hex())
+	}
+}
+
+// era1TestBlock holds the entries of a block of an era1 archive.
+type era1TestBlock struct {
+	block    *EthBlock
+	body     []byte
+	receipts []byte
+	td       *big.Int
+}
+
+// prepareEra1Blocks returns a genesis block, and a block on top of it
+// with a transaction and its receipt.
+func prepareEra1Blocks(t *testing.T) []era1TestBlock {
+	genesis := newEthBlock(&types.Header{
+		UncleHash:   types.EmptyUncleHash,
+		TxHash:      types.EmptyRootHash,
+		ReceiptHash: types.EmptyRootHash,
+		Difficulty:  big.NewInt(0x400),
+		Number:      big.NewInt(0),
+		GasLimit:    big.NewInt(0x47b760),
+		GasUsed:     big.NewInt(0),
+		Time:        big.NewInt(0),
+	}, nil)
+
+	tx := NewTx(types.NewTransaction(0,
+		common.HexToAddress("0x5abfec25f74cd88437631a7731906932776356f9"),
+		big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil))
+	tt := newTxTrie()
+	tt.add(0, tx.RawData())
+
+	rcts := []*types.Receipt{
+		&types.Receipt{
+			Status:            types.ReceiptStatusSuccessful,
+			CumulativeGasUsed: big.NewInt(21000),
+			Logs:              prepareLogs(2),
+		},
+	}
+
+	head := newEthBlock(&types.Header{
+		ParentHash:  genesis.Hash(),
+		UncleHash:   types.EmptyUncleHash,
+		TxHash:      common.BytesToHash(tt.rootHash()),
+		ReceiptHash: types.DeriveSha(types.Receipts(rcts)),
+		Difficulty:  big.NewInt(0x400),
+		Number:      big.NewInt(1),
+		GasLimit:    big.NewInt(0x47b760),
+		GasUsed:     big.NewInt(21000),
+		Time:        big.NewInt(15),
+	}, nil)
+
+	type body struct {
+		Transactions []rlp.RawValue
+		Uncles       []rlp.RawValue
+	}
+	return []era1TestBlock{
+		{
+			block:    genesis,
+			body:     getRLP(&body{}),
+			receipts: getRLP([]rlp.RawValue{}),
+			td:       big.NewInt(0x400),
+		},
+		{
+			block:    head,
+			body:     getRLP(&body{Transactions: []rlp.RawValue{tx.RawData()}}),
+			receipts: getRLP([]rlp.RawValue{NewReceipt(rcts[0]).RawData()}),
+			td:       big.NewInt(0x800),
+		},
+	}
+}
+
+// buildEra1 writes an era1 archive of the given blocks. The accumulator
+// is computed from the blocks, unless one is given.
+func buildEra1(t *testing.T, blocks []era1TestBlock, accumulator []byte) []byte {
+	var buf bytes.Buffer
+	writeEntry := func(typ uint16, data []byte) {
+		var header [8]byte
+		binary.LittleEndian.PutUint16(header[0:2], typ)
+		binary.LittleEndian.PutUint32(header[2:6], uint32(len(data)))
+		buf.Write(header[:])
+		buf.Write(data)
+	}
+	compress := func(data []byte) []byte {
+		var out bytes.Buffer
+		w := snappy.NewBufferedWriter(&out)
+		_, err := w.Write(data)
+		checkError(err, t)
+		checkError(w.Close(), t)
+		return out.Bytes()
+	}
+
+	writeEntry(e2Version, nil)
+
+	var records []era1HeaderRecord
+	for _, b := range blocks {
+		writeEntry(e2CompressedHeader, compress(b.block.RawData()))
+		writeEntry(e2CompressedBody, compress(b.body))
+		writeEntry(e2CompressedReceipts, compress(b.receipts))
+		writeEntry(e2TotalDifficulty, reverseBytes(common.LeftPadBytes(b.td.Bytes(), 32)))
+		records = append(records, era1HeaderRecord{hash: b.block.Hash(), td: b.td})
+	}
+
+	if accumulator == nil {
+		root := era1AccumulatorRoot(records)
+		accumulator = root[:]
+	}
+	writeEntry(e2Accumulator, accumulator)
+
+	// The offsets are not read, they are left to zero
+	index := make([]byte, 16+8*len(blocks))
+	binary.LittleEndian.PutUint64(index, blocks[0].block.Number.Uint64())
+	binary.LittleEndian.PutUint64(index[len(index)-8:], uint64(len(blocks)))
+	writeEntry(e2BlockIndex, index)
+
+	return buf.Bytes()
+}
@@ -0,0 +1,383 @@
+package ipldeth
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	cid "github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Keys of the go-ethereum database layout, see core/database_util.go
+var (
+	headHeaderKey = []byte("LastHeader")
+	headBlockKey  = []byte("LastBlock")
+	headFastKey   = []byte("LastFast")
+
+	headerPrefix    = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
+	tdSuffix        = []byte("t") // headerPrefix + num (uint64 big endian) + hash + tdSuffix -> td
+	numSuffix       = []byte("n") // headerPrefix + num (uint64 big endian) + numSuffix -> hash
+	blockHashPrefix = []byte("H") // blockHashPrefix + hash -> num (uint64 big endian)
+	bodyPrefix      = []byte("b") // bodyPrefix + num (uint64 big endian) + hash -> block body
+	lookupPrefix    = []byte("l") // lookupPrefix + hash -> transaction lookup entry
+)
+
+// txLookupEntry tells the block and position of a transaction.
+type txLookupEntry struct {
+	BlockHash  common.Hash
+	BlockIndex uint64
+	Index      uint64
+}
+
+/*
+  OUTPUT
+*/
+
+// ExportChain walks back the chain from the given eth-block cid, fetching
+// its nodes from the NodeGetter, and writes it into the given go-ethereum
+// database, in its layout: headers, bodies (rebuilt from the transaction,
+// ommer and withdrawal tries), total difficulties, canonical hashes and
+// transaction lookups. The state under the head block, storage tries and
+// contract code included, is written as well, making the head block the
+// one of the database. Receipts are not exported.
+//
+// An interrupted export is resumed by calling it again. The walk back stops
+// at the first block already written, and the nodes of the state are written
+// after their children, so a node already written means the whole subtrie is.
+func ExportChain(ctx context.Context, ng node.NodeGetter, head *cid.Cid, db ethdb.Database) error {
+	// Walk back to the genesis, or to the last block written
+	var (
+		blocks []*cid.Cid
+		td     = new(big.Int)
+	)
+	c := head
+	for {
+		b, err := getEthBlock(ctx, ng, c)
+		if err != nil {
+			return err
+		}
+
+		written, err := readTd(db, b)
+		if err != nil {
+			return err
+		}
+		if written != nil {
+			td = written
+			break
+		}
+
+		blocks = append(blocks, c)
+		if b.Number.Sign() == 0 {
+			break
+		}
+		c = commonHashToCid(MEthBlock, b.ParentHash)
+	}
+
+	headBlock, err := getEthBlock(ctx, ng, head)
+	if err != nil {
+		return err
+	}
+	err = exportStateNode(ctx, ng, db, commonHashToCid(MEthStateTrie, headBlock.Root))
+	if err != nil {
+		return err
+	}
+
+	// Write the blocks from the oldest one
+	for i := len(blocks) - 1; i >= 0; i-- {
+		b, err := getEthBlock(ctx, ng, blocks[i])
+		if err != nil {
+			return err
+		}
+
+		td.Add(td, b.Difficulty)
+		err = exportBlock(ctx, ng, db, b, td)
+		if err != nil {
+			return fmt.Errorf("block %v: %v", b.Number, err)
+		}
+	}
+
+	hash := headBlock.Hash()
+	for _, key := range [][]byte{headHeaderKey, headBlockKey, headFastKey} {
+		if err := db.Put(key, hash[:]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+  AUXILIARS
+*/
+
+// getEthBlock fetches and decodes the eth-block of the given cid.
+func getEthBlock(ctx context.Context, ng node.NodeGetter, c *cid.Cid) (*EthBlock, error) {
+	nd, err := ng.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeEthBlock(c, nd.RawData())
+}
+
+// readTd returns the total difficulty of the given block,
+// if the block was already written as canonical, nil otherwise.
+func readTd(db ethdb.Database, b *EthBlock) (*big.Int, error) {
+	hash := b.Hash()
+	number := b.Number.Uint64()
+
+	canonical, err := db.Get(canonicalKey(number))
+	if err != nil || !bytes.Equal(canonical, hash[:]) {
+		return nil, nil
+	}
+
+	rawdata, err := db.Get(append(headerKey(number, hash), tdSuffix...))
+	if err != nil {
+		return nil, nil
+	}
+
+	td := new(big.Int)
+	if err := rlp.DecodeBytes(rawdata, td); err != nil {
+		return nil, err
+	}
+	return td, nil
+}
+
+// exportBlock writes the header, the body and the indexes of the given
+// block, in a single batch. The canonical hash tells the block is written.
+func exportBlock(ctx context.Context, ng node.NodeGetter, db ethdb.Database, b *EthBlock, td *big.Int) error {
+	hash := b.Hash()
+	number := b.Number.Uint64()
+
+	txs, err := getTrieLeaves(ctx, ng, commonHashToCid(MEthTxTrie, b.TxHash), decodeEthTxTrieLeaf)
+	if err != nil {
+		return err
+	}
+
+	body := make([]interface{}, 0, 3)
+	bodyTxs := make([]interface{}, 0, len(txs))
+	batch := db.NewBatch()
+	for i, v := range txs {
+		tx := v.(*EthTx)
+		if tx.typed != nil {
+			// Typed transactions are wrapped into an RLP string
+			bodyTxs = append(bodyTxs, tx.rawdata)
+		} else {
+			bodyTxs = append(bodyTxs, rlp.RawValue(tx.rawdata))
+		}
+
+		entry := getRLP(txLookupEntry{BlockHash: hash, BlockIndex: number, Index: uint64(i)})
+		txHash := crypto.Keccak256(tx.rawdata)
+		if err := batch.Put(append(append([]byte{}, lookupPrefix...), txHash...), entry); err != nil {
+			return err
+		}
+	}
+	body = append(body, bodyTxs)
+
+	uncles := rlp.RawValue(getRLP([]interface{}{}))
+	if b.UncleHash != types.EmptyUncleHash {
+		nd, err := ng.Get(ctx, commonHashToCid(MEthBlockList, b.UncleHash))
+		if err != nil {
+			return err
+		}
+		uncles = nd.RawData()
+	}
+	body = append(body, uncles)
+
+	if b.EthHeaderExt != nil && b.WithdrawalsHash != nil {
+		ws, err := getTrieLeaves(ctx, ng, commonHashToCid(MEthWithdrawalTrie, *b.WithdrawalsHash), decodeEthWithdrawalTrieLeaf)
+		if err != nil {
+			return err
+		}
+
+		bodyWs := make([]rlp.RawValue, 0, len(ws))
+		for _, w := range ws {
+			bodyWs = append(bodyWs, w.(*EthWithdrawal).rawdata)
+		}
+		body = append(body, bodyWs)
+	}
+
+	puts := []struct {
+		key, value []byte
+	}{
+		{headerKey(number, hash), b.RawData()},
+		{bodyKey(number, hash), getRLP(body)},
+		{append(headerKey(number, hash), tdSuffix...), getRLP(td)},
+		{append(append([]byte{}, blockHashPrefix...), hash[:]...), encodeBlockNumber(number)},
+		{canonicalKey(number), hash[:]},
+	}
+	for _, p := range puts {
+		if err := batch.Put(p.key, p.value); err != nil {
+			return err
+		}
+	}
+
+	return batch.Write()
+}
+
+// exportStateNode writes the state trie node of the given cid, after its
+// children, the storage tries and the code of its accounts. Nodes already
+// in the database are skipped, along with their children.
+func exportStateNode(ctx context.Context, ng node.NodeGetter, db ethdb.Database, c *cid.Cid) error {
+	hash, err := cidToHash(c)
+	if err != nil {
+		return err
+	}
+	if hash == types.EmptyRootHash {
+		return nil
+	}
+	if ok, err := db.Has(hash[:]); err != nil || ok {
+		return err
+	}
+
+	leafDecoder := secureTrieLeafDecoder(c.Type())
+	if leafDecoder == nil {
+		return fmt.Errorf("no state export for codec %x", c.Type())
+	}
+
+	nd, err := ng.Get(ctx, c)
+	if err != nil {
+		return err
+	}
+	tn, err := decodeTrieNode(c, nd.RawData(), leafDecoder)
+	if err != nil {
+		return err
+	}
+
+	switch tn.nodeKind {
+	case "branch":
+		for _, child := range tn.elements {
+			if child == nil {
+				continue
+			}
+			if err := exportStateNode(ctx, ng, db, child.(*cid.Cid)); err != nil {
+				return err
+			}
+		}
+	case "extension":
+		if err := exportStateNode(ctx, ng, db, tn.elements[1].(*cid.Cid)); err != nil {
+			return err
+		}
+	case "leaf":
+		if as, ok := tn.elements[1].(*EthAccountSnapshot); ok {
+			err := exportStateNode(ctx, ng, db, keccak256ToCid(MEthStorageTrie, as.Root))
+			if err != nil {
+				return err
+			}
+			if err := exportCode(ctx, ng, db, as.CodeHash); err != nil {
+				return err
+			}
+		}
+	}
+
+	return db.Put(hash[:], tn.rawdata)
+}
+
+// exportCode writes the contract code of the given hash, if any.
+func exportCode(ctx context.Context, ng node.NodeGetter, db ethdb.Database, codeHash []byte) error {
+	if bytes.Equal(codeHash, emptyCodeHash[:]) {
+		return nil
+	}
+	if ok, err := db.Has(codeHash); err != nil || ok {
+		return err
+	}
+
+	nd, err := ng.Get(ctx, keccak256ToCid(RawBinary, codeHash))
+	if err != nil {
+		return err
+	}
+	return db.Put(codeHash, nd.RawData())
+}
+
+// getTrieLeaves returns the values of the leaves of the trie under the given
+// root, whose keys are the RLP of their index, in the order of the index.
+func getTrieLeaves(ctx context.Context, ng node.NodeGetter, root *cid.Cid,
+	leafDecoder trieNodeLeafDecoder) ([]interface{}, error) {
+	leaves := make(map[uint64]interface{})
+	if err := collectTrieLeaves(ctx, ng, root, nil, leafDecoder, leaves); err != nil {
+		return nil, err
+	}
+
+	out := make([]interface{}, 0, len(leaves))
+	for i := uint64(0); i < uint64(len(leaves)); i++ {
+		v, ok := leaves[i]
+		if !ok {
+			return nil, fmt.Errorf("missing leaf of index %d in trie %s", i, root)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// collectTrieLeaves gathers the leaves under the trie node of the given cid,
+// found under the given path, by their index.
+func collectTrieLeaves(ctx context.Context, ng node.NodeGetter, c *cid.Cid, path []byte,
+	leafDecoder trieNodeLeafDecoder, out map[uint64]interface{}) error {
+	if c.Equals(commonHashToCid(c.Type(), types.EmptyRootHash)) {
+		return nil
+	}
+
+	nd, err := ng.Get(ctx, c)
+	if err != nil {
+		return err
+	}
+	tn, err := decodeTrieNode(c, nd.RawData(), leafDecoder)
+	if err != nil {
+		return err
+	}
+
+	switch tn.nodeKind {
+	case "branch":
+		for i, child := range tn.elements {
+			if child == nil {
+				continue
+			}
+			p := append(append([]byte{}, path...), byte(i))
+			if err := collectTrieLeaves(ctx, ng, child.(*cid.Cid), p, leafDecoder, out); err != nil {
+				return err
+			}
+		}
+	case "extension":
+		p := append(append([]byte{}, path...), tn.elements[0].([]byte)...)
+		return collectTrieLeaves(ctx, ng, tn.elements[1].(*cid.Cid), p, leafDecoder, out)
+	case "leaf":
+		key := append(append([]byte{}, path...), tn.elements[0].([]byte)...)
+		var idx uint64
+		if err := rlp.DecodeBytes(byteFromNibbles(key), &idx); err != nil {
+			return err
+		}
+		out[idx] = tn.elements[1]
+	}
+
+	return nil
+}
+
+// encodeBlockNumber encodes a block number as uint64 big endian.
+func encodeBlockNumber(number uint64) []byte {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, number)
+	return enc
+}
+
+// headerKey = headerPrefix + num (uint64 big endian) + hash
+func headerKey(number uint64, hash common.Hash) []byte {
+	return append(append(append([]byte{}, headerPrefix...), encodeBlockNumber(number)...), hash[:]...)
+}
+
+// canonicalKey = headerPrefix + num (uint64 big endian) + numSuffix
+func canonicalKey(number uint64) []byte {
+	return append(append(append([]byte{}, headerPrefix...), encodeBlockNumber(number)...), numSuffix...)
+}
+
+// bodyKey = bodyPrefix + num (uint64 big endian) + hash
+func bodyKey(number uint64, hash common.Hash) []byte {
+	return append(append(append([]byte{}, bodyPrefix...), encodeBlockNumber(number)...), hash[:]...)
+}
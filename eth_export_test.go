@@ -0,0 +1,164 @@
+package ipldeth
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+/*
+  OUTPUT
+*/
+
+func TestExportChain(t *testing.T) {
+	ng, genesis, head := prepareExportChain(t)
+
+	db, err := ethdb.NewMemDatabase()
+	checkError(err, t)
+
+	err = ExportChain(context.Background(), ng, head.Cid(), db)
+	checkError(err, t)
+
+	hash := head.Hash()
+	for _, key := range [][]byte{headHeaderKey, headBlockKey, headFastKey} {
+		v, err := db.Get(key)
+		checkError(err, t)
+		if !bytes.Equal(v, hash[:]) {
+			t.Fatalf("Wrong head %s", key)
+		}
+	}
+
+	for _, b := range []*EthBlock{genesis, head} {
+		h := b.Hash()
+		canonical, err := db.Get(canonicalKey(b.Number.Uint64()))
+		checkError(err, t)
+		if !bytes.Equal(canonical, h[:]) {
+			t.Fatalf("Wrong canonical hash of block %v", b.Number)
+		}
+
+		header, err := db.Get(headerKey(b.Number.Uint64(), h))
+		checkError(err, t)
+		if !bytes.Equal(header, b.RawData()) {
+			t.Fatalf("Wrong header of block %v", b.Number)
+		}
+	}
+
+	// The body of the head holds its transaction
+	body, err := db.Get(bodyKey(1, hash))
+	checkError(err, t)
+	var decoded struct {
+		Transactions []rlp.RawValue
+		Uncles       []rlp.RawValue
+	}
+	checkError(rlp.DecodeBytes(body, &decoded), t)
+	if len(decoded.Transactions) != 1 || len(decoded.Uncles) != 0 {
+		t.Fatal("Wrong body of the head")
+	}
+
+	td, err := readTd(db, head)
+	checkError(err, t)
+	if td.Int64() != 0x800 {
+		t.Fatalf("Wrong total difficulty %v", td)
+	}
+
+	// And the state is there, code included
+	if ok, _ := db.Has(head.Root[:]); !ok {
+		t.Fatal("Expected the state root node")
+	}
+	codeHash := common.HexToHash("0xe79ba7e68314ca4cdb0cf9281176e8c10862dc1648a48cdc8dc35cd9ff39ac3d")
+	if ok, _ := db.Has(codeHash[:]); !ok {
+		t.Fatal("Expected the contract code")
+	}
+}
+
+func TestExportChainResume(t *testing.T) {
+	ng, genesis, head := prepareExportChain(t)
+
+	db, err := ethdb.NewMemDatabase()
+	checkError(err, t)
+
+	// Interrupted after the genesis
+	err = ExportChain(context.Background(), ng, genesis.Cid(), db)
+	checkError(err, t)
+
+	// The state is not fetched again
+	for k, n := range ng {
+		switch n.(type) {
+		case *EthStateTrie, *EthStorageTrie, *EthCode:
+			delete(ng, k)
+		}
+	}
+
+	err = ExportChain(context.Background(), ng, head.Cid(), db)
+	checkError(err, t)
+
+	td, err := readTd(db, head)
+	checkError(err, t)
+	if td == nil || td.Int64() != 0x800 {
+		t.Fatalf("Wrong total difficulty %v", td)
+	}
+}
+
+/*
+  AUXILIARS
+*/
+
+// prepareExportChain returns a chain of two blocks on the genesis test
+// data state, the second one with a transaction, as a node.NodeGetter.
+func prepareExportChain(t *testing.T) (mapNodeGetter, *EthBlock, *EthBlock) {
+	ng, root := prepareGenesisNodeGetter(t)
+	stateRoot, err := cidToHash(root)
+	checkError(err, t)
+
+	// Add the code of the contract
+	code := common.FromHex("0x6080604052348015600f57600080fd5b50603580601d6000396000f3006080604052600080fd00")
+	ec, err := DecodeEthCode(rawdataToCid(RawBinary, code), code)
+	checkError(err, t)
+	ng[ec.Cid().String()] = ec
+
+	genesis := newEthBlock(&types.Header{
+		UncleHash:   types.EmptyUncleHash,
+		Root:        stateRoot,
+		TxHash:      types.EmptyRootHash,
+		ReceiptHash: types.EmptyRootHash,
+		Difficulty:  big.NewInt(0x400),
+		Number:      big.NewInt(0),
+		GasLimit:    big.NewInt(0x47b760),
+		GasUsed:     big.NewInt(0),
+		Time:        big.NewInt(0),
+	}, nil)
+
+	tx := NewTx(types.NewTransaction(0,
+		common.HexToAddress("0x5abfec25f74cd88437631a7731906932776356f9"),
+		big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil))
+	tt := newTxTrie()
+	tt.add(0, tx.RawData())
+
+	head := newEthBlock(&types.Header{
+		ParentHash:  genesis.Hash(),
+		UncleHash:   types.EmptyUncleHash,
+		Root:        stateRoot,
+		TxHash:      common.BytesToHash(tt.rootHash()),
+		ReceiptHash: types.EmptyRootHash,
+		Difficulty:  big.NewInt(0x400),
+		Number:      big.NewInt(1),
+		GasLimit:    big.NewInt(0x47b760),
+		GasUsed:     big.NewInt(21000),
+		Time:        big.NewInt(15),
+	}, nil)
+
+	for _, n := range []*EthBlock{genesis, head} {
+		ng[n.Cid().String()] = n
+	}
+	for _, n := range tt.getNodes() {
+		ng[n.Cid().String()] = n
+	}
+
+	return ng, genesis, head
+}
@@ -0,0 +1,206 @@
+package ipldeth
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+/*
+  INPUT
+*/
+
+// FromGenesisJSON takes a genesis.json, as given to geth init, and builds
+// the state of its allocation. It returns the nodes of the state trie, the
+// account snapshots, the nodes of every storage trie and the bytecode of
+// the contracts. The root of the built state is checked against the given
+// state root, the one of the genesis block.
+func FromGenesisJSON(r io.Reader, stateRoot common.Hash) ([]*EthStateTrie, []*EthAccountSnapshot, []*EthStorageTrie, []*EthCode, error) {
+	var obj objJSONGenesis
+	dec := json.NewDecoder(r)
+	err := dec.Decode(&obj)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	// Sort the accounts, for the output to be the same every time
+	var addrs []string
+	for a := range obj.Alloc {
+		addrs = append(addrs, a)
+	}
+	sort.Strings(addrs)
+
+	var (
+		accounts     []*EthAccountSnapshot
+		storageNodes []*EthStorageTrie
+		codes        []*EthCode
+	)
+	st := newLocalTrie()
+	seenCode := make(map[string]bool)
+	for _, a := range addrs {
+		if !common.IsHexAddress(a) {
+			return nil, nil, nil, nil, fmt.Errorf("invalid account address %s", a)
+		}
+		address := common.HexToAddress(a)
+
+		account, nodes, err := obj.Alloc[a].toAccount()
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("account %s: %v", a, err)
+		}
+		storageNodes = append(storageNodes, nodes...)
+
+		code := obj.Alloc[a].Code
+		if len(code) != 0 && !seenCode[string(account.CodeHash)] {
+			seenCode[string(account.CodeHash)] = true
+			ec, err := DecodeEthCode(keccak256ToCid(RawBinary, account.CodeHash), code)
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			codes = append(codes, ec)
+		}
+
+		rawdata := getRLP(account)
+		st.put(crypto.Keccak256(address[:]), rawdata)
+		accounts = append(accounts, &EthAccountSnapshot{
+			EthAccount: account,
+			cid:        rawdataToCid(MEthAccountSnapshot, rawdata),
+			rawdata:    rawdata,
+		})
+	}
+
+	if got := st.rootHash(); common.BytesToHash(got) != stateRoot {
+		return nil, nil, nil, nil, fmt.Errorf("wrong state root computed, expected %x, got %x", stateRoot, got)
+	}
+
+	var stateNodes []*EthStateTrie
+	for _, k := range st.getKeys() {
+		rawdata, err := st.db.Get(k)
+		if err != nil {
+			panic(err)
+		}
+
+		stn, err := DecodeEthStateTrie(rawdataToCid(MEthStateTrie, rawdata), rawdata)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		stateNodes = append(stateNodes, stn)
+	}
+
+	return stateNodes, accounts, storageNodes, codes, nil
+}
+
+/*
+  AUXILIARS
+*/
+
+// objJSONGenesis defines the part of a genesis.json we care about,
+// the allocation of its accounts.
+type objJSONGenesis struct {
+	Alloc map[string]objJSONGenesisAccount `json:"alloc"`
+}
+
+// objJSONGenesisAccount is an account of the genesis allocation.
+// Balance and nonce can be either in hex or decimal.
+type objJSONGenesisAccount struct {
+	Balance string         `json:"balance"`
+	Nonce   string         `json:"nonce"`
+	Code    hexutil.Bytes  `json:"code"`
+	Storage genesisStorage `json:"storage"`
+}
+
+// genesisStorage is the storage of a genesis account. As for geth, its
+// keys and values may be given in hex shorter than 32 bytes.
+type genesisStorage map[common.Hash]common.Hash
+
+// UnmarshalJSON left pads the keys and values of the storage.
+func (gs *genesisStorage) UnmarshalJSON(input []byte) error {
+	var dec map[storageJSON]storageJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+
+	*gs = make(genesisStorage, len(dec))
+	for k, v := range dec {
+		(*gs)[common.Hash(k)] = common.Hash(v)
+	}
+	return nil
+}
+
+// storageJSON is a storage key or value, in hex of up to 32 bytes.
+type storageJSON common.Hash
+
+// UnmarshalText decodes the hex, left padding it to 32 bytes.
+func (h *storageJSON) UnmarshalText(text []byte) error {
+	text = bytes.TrimPrefix(text, []byte("0x"))
+	if len(text) > 64 {
+		return fmt.Errorf("too many hex characters in storage key/value %q", text)
+	}
+	offset := len(h) - len(text)/2
+	if _, err := hex.Decode(h[offset:], text); err != nil {
+		return fmt.Errorf("invalid hex storage key/value %q", text)
+	}
+	return nil
+}
+
+// toAccount builds the storage trie of the account, returning
+// its nodes, along with the account itself.
+func (ga objJSONGenesisAccount) toAccount() (*EthAccount, []*EthStorageTrie, error) {
+	balance, ok := math.ParseBig256(ga.Balance)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid balance %s", ga.Balance)
+	}
+
+	var nonce uint64
+	if ga.Nonce != "" {
+		nonce, ok = math.ParseUint64(ga.Nonce)
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid nonce %s", ga.Nonce)
+		}
+	}
+
+	lt := newLocalTrie()
+	for k, v := range ga.Storage {
+		// Zero values are not stored, as if they were deleted
+		value := new(big.Int).SetBytes(v[:])
+		if value.Sign() == 0 {
+			continue
+		}
+
+		rawdata, err := rlp.EncodeToBytes(value)
+		if err != nil {
+			return nil, nil, err
+		}
+		lt.put(crypto.Keccak256(k[:]), rawdata)
+	}
+
+	var nodes []*EthStorageTrie
+	for _, k := range lt.getKeys() {
+		rawdata, err := lt.db.Get(k)
+		if err != nil {
+			panic(err)
+		}
+
+		stn, err := DecodeEthStorageTrie(rawdataToCid(MEthStorageTrie, rawdata), rawdata)
+		if err != nil {
+			return nil, nil, err
+		}
+		nodes = append(nodes, stn)
+	}
+
+	return &EthAccount{
+		Nonce:    nonce,
+		Balance:  balance,
+		Root:     lt.rootHash(),
+		CodeHash: crypto.Keccak256(ga.Code),
+	}, nodes, nil
+}
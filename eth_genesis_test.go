@@ -0,0 +1,124 @@
+package ipldeth
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+  INPUT
+*/
+
+func TestFromGenesisJSON(t *testing.T) {
+	fi, err := os.Open("test_data/eth-genesis-json-private")
+	checkError(err, t)
+
+	stateRoot := common.HexToHash("0x7377ff8b5e915adb7687adf17e88697e209d8ef510b921142a1b85d95e7963ec")
+	stateNodes, accounts, storageNodes, codes, err := FromGenesisJSON(fi, stateRoot)
+	checkError(err, t)
+
+	// A branch and the leaves of the three accounts
+	if len(stateNodes) != 4 {
+		t.Fatalf("Wrong number of state trie nodes\r\nexpected %d\r\ngot %d", 4, len(stateNodes))
+	}
+	var found bool
+	for _, stn := range stateNodes {
+		if stn.Cid().String() == keccak256ToCid(MEthStateTrie, stateRoot[:]).String() {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("State trie root node not found")
+	}
+
+	if len(accounts) != 3 {
+		t.Fatalf("Wrong number of accounts\r\nexpected %d\r\ngot %d", 3, len(accounts))
+	}
+	// Sorted by address
+	contract := accounts[0]
+	if contract.Nonce != 1 || contract.Balance.Sign() != 0 {
+		t.Fatal("Wrong contract account")
+	}
+	if common.BytesToHash(contract.Root).Hex() !=
+		"0x9a7bd2b2d3947e4b09f6fdb6042abeee57b0b14fee138b5adef223e08e6553bb" {
+		t.Fatal("Wrong contract storage root")
+	}
+	if accounts[1].Nonce != 3 || accounts[1].Balance.String() != "2000000000000000000" {
+		t.Fatal("Wrong account given in hex")
+	}
+	if accounts[2].Balance.String() != "11901484239480000000000000" {
+		t.Fatal("Wrong account given in decimal")
+	}
+
+	// A branch and the leaves of the two non zero slots
+	if len(storageNodes) != 3 {
+		t.Fatalf("Wrong number of storage trie nodes\r\nexpected %d\r\ngot %d", 3, len(storageNodes))
+	}
+
+	if len(codes) != 1 {
+		t.Fatal("Expected the bytecode of the contract")
+	}
+	if codes[0].Cid().String() != keccak256ToCid(RawBinary, contract.CodeHash).String() {
+		t.Fatal("Wrong bytecode cid")
+	}
+}
+
+func TestFromGenesisJSONShortStorage(t *testing.T) {
+	// The same allocation, its storage in hex shorter than 32 bytes
+	fi, err := os.Open("test_data/eth-genesis-json-short-storage")
+	checkError(err, t)
+
+	stateRoot := common.HexToHash("0x7377ff8b5e915adb7687adf17e88697e209d8ef510b921142a1b85d95e7963ec")
+	_, accounts, storageNodes, _, err := FromGenesisJSON(fi, stateRoot)
+	checkError(err, t)
+
+	if common.BytesToHash(accounts[0].Root).Hex() !=
+		"0x9a7bd2b2d3947e4b09f6fdb6042abeee57b0b14fee138b5adef223e08e6553bb" {
+		t.Fatal("Wrong contract storage root")
+	}
+	if len(storageNodes) != 3 {
+		t.Fatalf("Wrong number of storage trie nodes\r\nexpected %d\r\ngot %d", 3, len(storageNodes))
+	}
+}
+
+func TestFromGenesisJSONWrongRoot(t *testing.T) {
+	fi, err := os.Open("test_data/eth-genesis-json-private")
+	checkError(err, t)
+
+	_, _, _, _, err = FromGenesisJSON(fi, common.Hash{})
+	if err == nil || !strings.HasPrefix(err.Error(), "wrong state root computed") {
+		t.Fatal("Expected error 'wrong state root computed'")
+	}
+}
+
+func TestFromGenesisJSONBadAccount(t *testing.T) {
+	testCases := map[string]string{
+		`{"alloc": {"0x5abfec25": {"balance": "1"}}}`:                                 "invalid account address 0x5abfec25",
+		`{"alloc": {"0x5abfec25f74cd88437631a7731906932776356f9": {"balance": "x"}}}`: "account 0x5abfec25f74cd88437631a7731906932776356f9: invalid balance x",
+	}
+
+	for in, expected := range testCases {
+		_, _, _, _, err := FromGenesisJSON(strings.NewReader(in), common.Hash{})
+		if err == nil || err.Error() != expected {
+			t.Fatalf("Wrong error\r\nexpected %s\r\ngot %v", expected, err)
+		}
+	}
+}
+
+func TestFromGenesisJSONBadStorage(t *testing.T) {
+	testCases := map[string]string{
+		"0x2": `invalid hex storage key/value "2"`,
+		"0x000000000000000000000000000000000000000000000000000000000000000001": `too many hex characters in storage key/value "000000000000000000000000000000000000000000000000000000000000000001"`,
+	}
+
+	for value, expected := range testCases {
+		in := `{"alloc": {"0x5abfec25f74cd88437631a7731906932776356f9": {"balance": "1", "storage": {"0x01": "` + value + `"}}}}`
+		_, _, _, _, err := FromGenesisJSON(strings.NewReader(in), common.Hash{})
+		if err == nil || err.Error() != expected {
+			t.Fatalf("Wrong error\r\nexpected %s\r\ngot %v", expected, err)
+		}
+	}
+}
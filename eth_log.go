@@ -0,0 +1,197 @@
+package ipldeth
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	cid "github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+
+	types "github.com/ethereum/go-ethereum/core/types"
+	rlp "github.com/ethereum/go-ethereum/rlp"
+)
+
+// EthLog (eth-receipt-log, codec 0x9a), represents a log
+// emitted by a transaction, as found in its receipt.
+type EthLog struct {
+	*types.Log
+
+	cid     *cid.Cid
+	rawdata []byte
+}
+
+// Static (compile time) check that EthLog satisfies the node.Node interface.
+var _ node.Node = (*EthLog)(nil)
+
+/*
+  INPUT
+*/
+
+// Logs are parsed along with the receipt holding them,
+// as their trie is built there. See processLogs.
+
+// NewLog computes the cid and rlp-encodes a types.Log object
+// returning a proper EthLog node
+func NewLog(l *types.Log) *EthLog {
+	rawdata := getRLP(l)
+
+	return &EthLog{
+		Log:     l,
+		cid:     rawdataToCid(MEthLog, rawdata),
+		rawdata: rawdata,
+	}
+}
+
+/*
+  OUTPUT
+*/
+
+// DecodeEthLog takes a cid and its raw binary data
+// from IPFS and returns an EthLog object for further processing.
+func DecodeEthLog(c *cid.Cid, b []byte) (*EthLog, error) {
+	var l types.Log
+	err := rlp.DecodeBytes(b, &l)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EthLog{
+		Log:     &l,
+		cid:     c,
+		rawdata: b,
+	}, nil
+}
+
+/*
+  Block INTERFACE
+*/
+
+// RawData returns the binary of the RLP encode of the log.
+func (l *EthLog) RawData() []byte {
+	return l.rawdata
+}
+
+// Cid returns the cid of the log.
+func (l *EthLog) Cid() *cid.Cid {
+	return l.cid
+}
+
+// String is a helper for output
+func (l *EthLog) String() string {
+	return fmt.Sprintf("<EthereumLog %s>", l.cid)
+}
+
+// Loggable returns in a map the type of IPLD Link.
+func (l *EthLog) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "eth-receipt-log",
+	}
+}
+
+/*
+  Node INTERFACE
+*/
+
+// Resolve resolves a path through this node, stopping at any link boundary
+// and returning the object found as well as the remaining path to traverse
+func (l *EthLog) Resolve(p []string) (interface{}, []string, error) {
+	if len(p) == 0 {
+		return l, nil, nil
+	}
+
+	// Topics can be addressed one by one
+	if p[0] == "topics" && len(p) > 1 {
+		if len(p) > 2 {
+			return nil, nil, fmt.Errorf("unexpected path elements past %s", p[1])
+		}
+
+		idx, err := strconv.Atoi(p[1])
+		if err != nil || idx < 0 || idx >= len(l.Topics) {
+			return nil, nil, fmt.Errorf("no such link")
+		}
+		return l.Topics[idx], nil, nil
+	}
+
+	if len(p) > 1 {
+		return nil, nil, fmt.Errorf("unexpected path elements past %s", p[0])
+	}
+
+	switch p[0] {
+	case "address":
+		return l.Address, nil, nil
+	case "data":
+		// This is a []byte. By default they are marshalled into Base64.
+		return fmt.Sprintf("0x%x", l.Data), nil, nil
+	case "topics":
+		return l.Topics, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("no such link")
+	}
+}
+
+// Tree lists all paths within the object under 'path', and up to the given depth.
+// To list the entire object (similar to `find .`) pass "" and -1
+func (l *EthLog) Tree(p string, depth int) []string {
+	if p != "" || depth == 0 {
+		return nil
+	}
+
+	out := []string{"address", "data", "topics"}
+	if depth == 1 {
+		return out
+	}
+	for i := range l.Topics {
+		out = append(out, "topics/"+strconv.Itoa(i))
+	}
+	return out
+}
+
+// ResolveLink is a helper function that calls resolve and asserts the
+// output is a link
+func (l *EthLog) ResolveLink(p []string) (*node.Link, []string, error) {
+	obj, rest, err := l.Resolve(p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if lnk, ok := obj.(*node.Link); ok {
+		return lnk, rest, nil
+	}
+
+	return nil, nil, fmt.Errorf("resolved item was not a link")
+}
+
+// Copy will go away. It is here to comply with the interface.
+func (l *EthLog) Copy() node.Node {
+	panic("dont use this yet")
+}
+
+// Links is a helper function that returns all links within this object
+func (l *EthLog) Links() []*node.Link {
+	return nil
+}
+
+// Stat will go away. It is here to comply with the interface.
+func (l *EthLog) Stat() (*node.NodeStat, error) {
+	return &node.NodeStat{}, nil
+}
+
+// Size will go away. It is here to comply with the interface.
+func (l *EthLog) Size() (uint64, error) {
+	return uint64(len(l.rawdata)), nil
+}
+
+/*
+  EthLog functions
+*/
+
+// MarshalJSON processes the log into readable JSON format.
+func (l *EthLog) MarshalJSON() ([]byte, error) {
+	out := map[string]interface{}{
+		"address": l.Address,
+		"data":    fmt.Sprintf("0x%x", l.Data),
+		"topics":  l.Topics,
+	}
+	return json.Marshal(out)
+}
@@ -0,0 +1,177 @@
+package ipldeth
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	block "github.com/ipfs/go-block-format"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+/*
+  OUTPUT
+*/
+
+func TestDecodeLog(t *testing.T) {
+	l := prepareEthLog()
+
+	// Just to clarify: This `block` is an IPFS block
+	storedLog, err := block.NewBlockWithCid(l.RawData(), l.Cid())
+	checkError(err, t)
+
+	ethLog, err := DecodeEthLog(storedLog.Cid(), storedLog.RawData())
+	checkError(err, t)
+
+	if fmt.Sprintf("%x", ethLog.Address) != "dac17f958d2ee523a2206206994597c13d831ec7" {
+		t.Fatal("Wrong Address")
+	}
+	if len(ethLog.Topics) != 3 {
+		t.Fatal("Wrong number of topics")
+	}
+	if fmt.Sprintf("%x", ethLog.Data) != "0000000000000000000000000000000000000000000000000000000005f5e100" {
+		t.Fatal("Wrong Data")
+	}
+}
+
+/*
+  Block INTERFACE
+*/
+
+func TestEthLogLoggable(t *testing.T) {
+	l := prepareEthLog()
+
+	lg := l.Loggable()
+	if _, ok := lg["type"]; !ok {
+		t.Fatal("Loggable map expected the field 'type'")
+	}
+
+	if lg["type"] != "eth-receipt-log" {
+		t.Fatal("Wrong Loggable 'type' value")
+	}
+}
+
+/*
+  Node INTERFACE
+*/
+
+func TestEthLogResolve(t *testing.T) {
+	l := prepareEthLog()
+
+	// Empty path
+	obj, rest, err := l.Resolve([]string{})
+	checkError(err, t)
+	if obj.(*EthLog) != l {
+		t.Fatal("Should have returned the same eth-receipt-log object")
+	}
+	if rest != nil {
+		t.Fatal("rest should be nil")
+	}
+
+	testCases := map[string][]string{
+		"address": []string{"%x", "dac17f958d2ee523a2206206994597c13d831ec7"},
+		"data":    []string{"%s", "0x0000000000000000000000000000000000000000000000000000000005f5e100"},
+	}
+	for field, value := range testCases {
+		obj, _, err := l.Resolve([]string{field})
+		checkError(err, t)
+
+		if fmt.Sprintf(value[0], obj) != value[1] {
+			t.Fatalf("Wrong %v", field)
+		}
+	}
+
+	obj, _, err = l.Resolve([]string{"topics"})
+	checkError(err, t)
+	if len(obj.([]common.Hash)) != 3 {
+		t.Fatal("Wrong topics")
+	}
+
+	obj, rest, err = l.Resolve([]string{"topics", "0"})
+	checkError(err, t)
+	if obj.(common.Hash).Hex() != "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef" {
+		t.Fatal("Wrong topic")
+	}
+	if rest != nil {
+		t.Fatal("rest should be nil")
+	}
+
+	// Bad cases
+	for _, bc := range [][]string{
+		[]string{"topics", "3"},
+		[]string{"topics", "-1"},
+		[]string{"topics", "a"},
+		[]string{"logIndex"},
+	} {
+		_, _, err = l.Resolve(bc)
+		if err == nil || err.Error() != "no such link" {
+			t.Fatalf("Expected 'no such link' error for %v", bc)
+		}
+	}
+
+	_, _, err = l.Resolve([]string{"topics", "0", "extra"})
+	if err == nil || err.Error() != "unexpected path elements past 0" {
+		t.Fatal("Wrong error")
+	}
+	_, _, err = l.Resolve([]string{"data", "extra"})
+	if err == nil || err.Error() != "unexpected path elements past data" {
+		t.Fatal("Wrong error")
+	}
+}
+
+func TestEthLogTree(t *testing.T) {
+	l := prepareEthLog()
+
+	if l.Tree("non-empty-string", 1) != nil {
+		t.Fatal("Expected nil to be returned")
+	}
+	if l.Tree("", 0) != nil {
+		t.Fatal("Expected nil to be returned")
+	}
+
+	if len(l.Tree("", 1)) != 3 {
+		t.Fatal("Wrong number of elements")
+	}
+
+	tree := l.Tree("", -1)
+	if len(tree) != 6 || tree[5] != "topics/2" {
+		t.Fatal("Wrong tree")
+	}
+}
+
+func TestEthLogJSONMarshal(t *testing.T) {
+	l := prepareEthLog()
+
+	jsonOutput, err := l.MarshalJSON()
+	checkError(err, t)
+
+	var data map[string]interface{}
+	err = json.Unmarshal(jsonOutput, &data)
+	checkError(err, t)
+
+	if data["address"] != "0xdac17f958d2ee523a2206206994597c13d831ec7" {
+		t.Fatal("Wrong address")
+	}
+	if len(data["topics"].([]interface{})) != 3 {
+		t.Fatal("Wrong topics")
+	}
+}
+
+/*
+  AUXILIARS
+*/
+
+// prepareEthLog returns an ERC20 Transfer event of 100 tokens.
+func prepareEthLog() *EthLog {
+	return NewLog(&types.Log{
+		Address: common.HexToAddress("0xdac17f958d2ee523a2206206994597c13d831ec7"),
+		Topics: []common.Hash{
+			common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"),
+			common.HexToHash("0x00000000000000000000000032be343b94f860124dc4fee278fdcbd38c102d88"),
+			common.HexToHash("0x0000000000000000000000001c51bf013add0857c5d9cf2f71a7f15ca93d4816"),
+		},
+		Data: common.FromHex("0x0000000000000000000000000000000000000000000000000000000005f5e100"),
+	})
+}
@@ -0,0 +1,143 @@
+package ipldeth
+
+import (
+	"fmt"
+
+	cid "github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+
+	types "github.com/ethereum/go-ethereum/core/types"
+)
+
+// EthLogTrie (eth-receipt-log-trie codec 0x99) represents
+// a node from the trie of the logs of a receipt.
+// Unlike the other tries, it is not committed to by ethereum consensus,
+// but allows to address every log of a receipt.
+type EthLogTrie struct {
+	*TrieNode
+}
+
+// Static (compile time) check that EthLogTrie satisfies the node.Node interface.
+var _ node.Node = (*EthLogTrie)(nil)
+
+/*
+ INPUT
+*/
+
+// processLogs will take the logs of a receipt to return IPLD node
+// slices for eth-receipt-log and eth-receipt-log-trie, along with
+// the root of the trie.
+func processLogs(logs []*types.Log) ([]*EthLog, []*EthLogTrie, []byte) {
+	var ethLogNodes []*EthLog
+	logTrie := newLogTrie()
+
+	for idx, l := range logs {
+		ethLog := NewLog(l)
+		ethLogNodes = append(ethLogNodes, ethLog)
+		logTrie.add(idx, ethLog.RawData())
+	}
+
+	return ethLogNodes, logTrie.getNodes(), logTrie.rootHash()
+}
+
+// logTrieRoot returns the root hash of the trie of the given logs,
+// without building its nodes.
+func logTrieRoot(logs []*types.Log) []byte {
+	lt := newLogTrie()
+	for idx, l := range logs {
+		lt.add(idx, NewLog(l).RawData())
+	}
+
+	return lt.rootHash()
+}
+
+/*
+  OUTPUT
+*/
+
+// DecodeEthLogTrie returns an EthLogTrie object from its cid and rawdata.
+func DecodeEthLogTrie(c *cid.Cid, b []byte) (*EthLogTrie, error) {
+	tn, err := decodeTrieNode(c, b, decodeEthLogTrieLeaf)
+	if err != nil {
+		return nil, err
+	}
+	return &EthLogTrie{TrieNode: tn}, nil
+}
+
+// decodeEthLogTrieLeaf parses a eth-receipt-log-trie leaf
+// from decoded RLP elements
+func decodeEthLogTrieLeaf(i []interface{}) ([]interface{}, error) {
+	l, err := DecodeEthLog(rawdataToCid(MEthLog, i[1].([]byte)), i[1].([]byte))
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{
+		i[0].([]byte),
+		l,
+	}, nil
+}
+
+/*
+  Block INTERFACE
+*/
+
+// RawData returns the binary of the RLP encode of the log trie node.
+func (t *EthLogTrie) RawData() []byte {
+	return t.rawdata
+}
+
+// Cid returns the cid of the log trie node.
+func (t *EthLogTrie) Cid() *cid.Cid {
+	return t.cid
+}
+
+// String is a helper for output
+func (t *EthLogTrie) String() string {
+	return fmt.Sprintf("<EthereumLogTrie %s>", t.cid)
+}
+
+// Loggable returns in a map the type of IPLD Link.
+func (t *EthLogTrie) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "eth-receipt-log-trie",
+	}
+}
+
+/*
+  EthLogTrie functions
+*/
+
+// logTrie wraps a localTrie for use on the log trie.
+type logTrie struct {
+	*localTrie
+}
+
+// newLogTrie initializes and returns a logTrie.
+func newLogTrie() *logTrie {
+	return &logTrie{
+		localTrie: newLocalTrie(),
+	}
+}
+
+// getNodes invokes the localTrie, which computes the root hash of the
+// log trie and returns its database keys, to return a slice
+// of EthLogTrie nodes.
+func (lt *logTrie) getNodes() []*EthLogTrie {
+	keys := lt.getKeys()
+	var out []*EthLogTrie
+
+	for _, k := range keys {
+		rawdata, err := lt.db.Get(k)
+		if err != nil {
+			panic(err)
+		}
+
+		tn := &TrieNode{
+			cid:     rawdataToCid(MEthLogTrie, rawdata),
+			rawdata: rawdata,
+		}
+		out = append(out, &EthLogTrie{TrieNode: tn})
+	}
+
+	return out
+}
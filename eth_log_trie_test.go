@@ -0,0 +1,136 @@
+package ipldeth
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+/*
+  INPUT
+*/
+
+func TestProcessLogs(t *testing.T) {
+	logs := prepareLogs(3)
+
+	logNodes, logTrieNodes, root := processLogs(logs)
+	if len(logNodes) != 3 {
+		t.Fatal("Wrong number of log nodes")
+	}
+
+	var found bool
+	for _, ltn := range logTrieNodes {
+		if ltn.Cid().Equals(keccak256ToCid(MEthLogTrie, root)) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("Log trie root not found among the returned nodes")
+	}
+}
+
+func TestLogTrieRoot(t *testing.T) {
+	for _, n := range []int{0, 1, 3} {
+		_, _, root := processLogs(prepareLogs(n))
+		if !bytes.Equal(logTrieRoot(prepareLogs(n)), root) {
+			t.Fatalf("Wrong log trie root for %d logs", n)
+		}
+	}
+}
+
+/*
+  OUTPUT
+*/
+
+func TestLogTrieDecodeLeaf(t *testing.T) {
+	// A single log makes the root of the trie a leaf
+	_, logTrieNodes, _ := processLogs(prepareLogs(1))
+	if len(logTrieNodes) != 1 {
+		t.Fatal("Expected a single log trie node")
+	}
+
+	ethLogTrie, err := DecodeEthLogTrie(logTrieNodes[0].Cid(), logTrieNodes[0].RawData())
+	checkError(err, t)
+
+	if ethLogTrie.nodeKind != "leaf" {
+		t.Fatal("Wrong nodeKind")
+	}
+	if _, ok := ethLogTrie.elements[1].(*EthLog); !ok {
+		t.Fatal("Wrong Type. Element should be a log")
+	}
+
+	// Key of the first log is rlp(0) = 0x80
+	obj, rest, err := ethLogTrie.Resolve([]string{"8", "0", "topics", "0"})
+	checkError(err, t)
+	if rest != nil {
+		t.Fatal("rest should be nil")
+	}
+	if obj != prepareEthLog().Topics[0] {
+		t.Fatal("Wrong topic")
+	}
+}
+
+func TestReceiptLogPathThroughReceiptTrie(t *testing.T) {
+	// <block>/receipts/<nibbles>/logs/0/topics/1
+	rcts := []*types.Receipt{
+		&types.Receipt{
+			Status:            types.ReceiptStatusSuccessful,
+			CumulativeGasUsed: big.NewInt(52000),
+			Logs:              prepareLogs(2),
+		},
+	}
+	root := types.DeriveSha(types.Receipts(rcts))
+
+	_, rctTrieNodes, logNodes, logTrieNodes, err := processReceipts(prepareReceiptNodes(rcts), root[:])
+	checkError(err, t)
+	if len(logNodes) != 2 || len(logTrieNodes) == 0 {
+		t.Fatal("Expected the log nodes of the receipt")
+	}
+
+	ethRctTrie, err := DecodeEthTxReceiptTrie(rctTrieNodes[0].Cid(), rctTrieNodes[0].RawData())
+	checkError(err, t)
+
+	lnk, rest, err := ethRctTrie.ResolveLink([]string{"8", "0", "logs", "0", "topics", "1"})
+	checkError(err, t)
+	if !lnk.Cid.Equals(logNodes[0].Cid()) {
+		t.Fatal("Wrong link to the log")
+	}
+
+	obj, _, err := logNodes[0].Resolve(rest)
+	checkError(err, t)
+	if obj != logNodes[0].Topics[1] {
+		t.Fatal("Wrong topic")
+	}
+}
+
+/*
+  Block INTERFACE
+*/
+
+func TestEthLogTrieLoggable(t *testing.T) {
+	_, logTrieNodes, _ := processLogs(prepareLogs(3))
+
+	l := logTrieNodes[0].Loggable()
+	if _, ok := l["type"]; !ok {
+		t.Fatal("Loggable map expected the field 'type'")
+	}
+
+	if l["type"] != "eth-receipt-log-trie" {
+		t.Fatal("Wrong Loggable 'type' value")
+	}
+}
+
+/*
+  AUXILIARS
+*/
+
+// prepareLogs returns n copies of the log of prepareEthLog.
+func prepareLogs(n int) []*types.Log {
+	var out []*types.Log
+	for i := 0; i < n; i++ {
+		out = append(out, prepareEthLog().Log)
+	}
+	return out
+}
@@ -0,0 +1,422 @@
+package ipldeth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+
+	cid "github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// AccountResult is the proof of an account, and of some of its storage
+// slots, against a state root. It follows the shape of the result of
+// the eth_getProof JSON-RPC call.
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []string        `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// StorageResult is the proof of a storage slot against the storage
+// root of its account, as found in the result of eth_getProof.
+type StorageResult struct {
+	Key   string       `json:"key"`
+	Value *hexutil.Big `json:"value"`
+	Proof []string     `json:"proof"`
+}
+
+// ProofResult is what a verified proof shows about its key.
+type ProofResult struct {
+	// Included is false when the proof shows the key is not in the trie.
+	Included bool
+
+	// Value is the leaf value of the key, when included. This is,
+	// an *EthAccountSnapshot in the state trie, or an *EthStorageValue
+	// in a storage trie.
+	Value node.Resolver
+}
+
+// ProofError tells which node of a proof, by its position, could not
+// be verified, and why.
+type ProofError struct {
+	Step int
+	Err  error
+}
+
+// Error implements the error interface.
+func (e *ProofError) Error() string {
+	return fmt.Sprintf("proof step %d: %v", e.Step, e.Err)
+}
+
+// emptyCodeHash is the code hash of the accounts without code.
+var emptyCodeHash = crypto.Keccak256Hash(nil)
+
+/*
+  INPUT
+*/
+
+// FromProofJSON takes the JSON-RPC response of an eth_getProof call and
+// returns the account it proves, nil if the proof shows it does not exist,
+// along with the state trie nodes of the account proof, and the storage
+// trie nodes of every storage proof. As the response does not carry the
+// state root it was made at, the account proof is verified against the
+// hash of its first node, which makes it consistent, not bound to a block.
+// Use FromProofJSONAtRoot to bind it to the state root of a block.
+// The storage proofs are verified against the storage root of the account.
+// The fields of the response must match the account proven, or be the ones
+// of an empty account when it is absent.
+func FromProofJSON(r io.Reader) (*EthAccountSnapshot, []*EthStateTrie, []*EthStorageTrie, error) {
+	return fromProofJSON(r, nil)
+}
+
+// FromProofJSONAtRoot is FromProofJSON, verifying the account proof
+// against the given state root, the one of the block the call was made at.
+func FromProofJSONAtRoot(r io.Reader, stateRoot common.Hash) (*EthAccountSnapshot, []*EthStateTrie, []*EthStorageTrie, error) {
+	return fromProofJSON(r, &stateRoot)
+}
+
+// fromProofJSON implements FromProofJSON and FromProofJSONAtRoot.
+func fromProofJSON(r io.Reader, stateRoot *common.Hash) (*EthAccountSnapshot, []*EthStateTrie, []*EthStorageTrie, error) {
+	var obj objJSONProof
+	dec := json.NewDecoder(r)
+	err := dec.Decode(&obj)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	res := obj.Result
+
+	accountProof, err := decodeHexProof(res.AccountProof)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// The root of a proof is its first node
+	if stateRoot == nil {
+		if len(accountProof) == 0 {
+			return nil, nil, nil, fmt.Errorf("empty account proof")
+		}
+		h := crypto.Keccak256Hash(accountProof[0])
+		stateRoot = &h
+	}
+
+	pr, err := VerifyProof(MEthStateTrie, *stateRoot, res.Address[:], accountProof)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var account *EthAccountSnapshot
+	if pr.Included {
+		account = pr.Value.(*EthAccountSnapshot)
+		if !res.matches(account) {
+			return nil, nil, nil, fmt.Errorf("account proof does not match the account fields")
+		}
+	} else if !res.isEmpty() {
+		return nil, nil, nil, fmt.Errorf("absent account proof does not match the account fields")
+	}
+
+	var stateNodes []*EthStateTrie
+	for _, raw := range accountProof {
+		st, err := DecodeEthStateTrie(rawdataToCid(MEthStateTrie, raw), raw)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		stateNodes = append(stateNodes, st)
+	}
+
+	// The storage proofs share their first nodes
+	var storageNodes []*EthStorageTrie
+	seen := make(map[string]bool)
+	for _, sr := range res.StorageProof {
+		key, err := parseStorageSlot(sr.Key)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		proof, err := decodeHexProof(sr.Proof)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		pr, err := VerifyProof(MEthStorageTrie, res.StorageHash, key[:], proof)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		value := new(big.Int)
+		if pr.Included {
+			value = pr.Value.(*EthStorageValue).Value
+		}
+		if sr.Value == nil || value.Cmp(sr.Value.ToInt()) != 0 {
+			return nil, nil, nil, fmt.Errorf("storage proof does not match the value of slot %s", sr.Key)
+		}
+
+		for _, raw := range proof {
+			c := rawdataToCid(MEthStorageTrie, raw)
+			if seen[c.KeyString()] {
+				continue
+			}
+			seen[c.KeyString()] = true
+
+			st, err := DecodeEthStorageTrie(c, raw)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			storageNodes = append(storageNodes, st)
+		}
+	}
+
+	return account, stateNodes, storageNodes, nil
+}
+
+/*
+  OUTPUT
+*/
+
+// GetProof walks the state trie under the given root cid, fetching its
+// nodes from the NodeGetter, to prove the account of the given address
+// and the given storage slots, in hex, of this account.
+// The proofs are made of the RLP, in hex, of every node found on the way
+// to the key. When the key is not in the trie, they prove its absence,
+// and the fields of the account or the slot value are left to zero.
+func GetProof(ctx context.Context, ng node.NodeGetter, root *cid.Cid,
+	address common.Address, slots []string) (*AccountResult, error) {
+	proof, leaf, err := proveTrieKey(ctx, ng, root, address[:], decodeEthStateTrieLeaf)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &AccountResult{
+		Address:      address,
+		AccountProof: proof,
+		Balance:      (*hexutil.Big)(new(big.Int)),
+		CodeHash:     emptyCodeHash,
+		StorageHash:  types.EmptyRootHash,
+		StorageProof: make([]StorageResult, 0, len(slots)),
+	}
+
+	if leaf != nil {
+		as, ok := leaf.(*EthAccountSnapshot)
+		if !ok {
+			return nil, fmt.Errorf("state trie leaf is not an account")
+		}
+		res.Balance = (*hexutil.Big)(as.Balance)
+		res.CodeHash = common.BytesToHash(as.CodeHash)
+		res.Nonce = hexutil.Uint64(as.Nonce)
+		res.StorageHash = common.BytesToHash(as.Root)
+	}
+
+	storageRoot := commonHashToCid(MEthStorageTrie, res.StorageHash)
+	for _, s := range slots {
+		key, err := parseStorageSlot(s)
+		if err != nil {
+			return nil, err
+		}
+
+		proof, leaf, err := proveTrieKey(ctx, ng, storageRoot, key[:], decodeEthStorageTrieLeaf)
+		if err != nil {
+			return nil, err
+		}
+
+		sr := StorageResult{
+			Key:   s,
+			Value: (*hexutil.Big)(new(big.Int)),
+			Proof: proof,
+		}
+		if leaf != nil {
+			sv, ok := leaf.(*EthStorageValue)
+			if !ok {
+				return nil, fmt.Errorf("storage trie leaf is not a slot value")
+			}
+			sr.Value = (*hexutil.Big)(sv.Value)
+		}
+
+		res.StorageProof = append(res.StorageProof, sr)
+	}
+
+	return res, nil
+}
+
+// VerifyProof checks the given proof, the ordered RLP of the trie nodes
+// on the way to the key, against the root hash of a secure trie.
+// The codec tells the trie the proof is from, either MEthStateTrie,
+// for the key to be an address, or MEthStorageTrie, for a 32 bytes slot.
+// Every node is checked to hash to the link its parent has, the first one
+// to the root. The proof either shows the value of the key or, when the
+// path to the key does not lead to a leaf, that the key is not in the trie.
+func VerifyProof(codec uint64, root common.Hash, key []byte,
+	proof [][]byte) (*ProofResult, error) {
+	leafDecoder := secureTrieLeafDecoder(codec)
+	if leafDecoder == nil {
+		return nil, fmt.Errorf("no proofs for codec %x", codec)
+	}
+
+	if len(proof) == 0 {
+		if root != types.EmptyRootHash {
+			return nil, &ProofError{0, fmt.Errorf("missing node %x", root)}
+		}
+		return &ProofResult{}, nil
+	}
+
+	path := nibbleToByte(crypto.Keccak256(key))
+	expected := keccak256ToCid(codec, root[:])
+	for i, raw := range proof {
+		c := rawdataToCid(codec, raw)
+		if !c.Equals(expected) {
+			return nil, &ProofError{i, fmt.Errorf("node hash mismatch, expected %s, got %s", expected, c)}
+		}
+
+		tn, err := decodeTrieNode(c, raw, leafDecoder)
+		if err != nil {
+			return nil, &ProofError{i, err}
+		}
+
+		var value interface{}
+		expected, path, value, err = tn.followPath(path)
+		if err != nil {
+			return nil, &ProofError{i, err}
+		}
+		if expected != nil {
+			continue
+		}
+
+		if i != len(proof)-1 {
+			return nil, &ProofError{i + 1, fmt.Errorf("unexpected node past the end of the path")}
+		}
+		if value == nil {
+			return &ProofResult{}, nil
+		}
+
+		res, ok := value.(node.Resolver)
+		if !ok {
+			return nil, &ProofError{i, fmt.Errorf("leaf children is not an IPLD node")}
+		}
+		return &ProofResult{Included: true, Value: res}, nil
+	}
+
+	return nil, &ProofError{len(proof), fmt.Errorf("missing node %s", expected)}
+}
+
+/*
+  AUXILIARS
+*/
+
+// objJSONProof defines the output of the JSON RPC API for eth_getProof.
+type objJSONProof struct {
+	Result AccountResult `json:"result"`
+}
+
+// matches tells whether the given account has the fields of the result.
+func (res *AccountResult) matches(as *EthAccountSnapshot) bool {
+	return res.Balance != nil && as.Balance.Cmp(res.Balance.ToInt()) == 0 &&
+		uint64(res.Nonce) == as.Nonce &&
+		bytes.Equal(res.CodeHash[:], as.CodeHash) &&
+		bytes.Equal(res.StorageHash[:], as.Root)
+}
+
+// isEmpty tells whether the fields of the result are the ones of an
+// account that is not in the state trie, as GetProof leaves them.
+func (res *AccountResult) isEmpty() bool {
+	return res.Balance != nil && res.Balance.ToInt().Sign() == 0 &&
+		res.Nonce == 0 &&
+		res.CodeHash == emptyCodeHash &&
+		res.StorageHash == types.EmptyRootHash
+}
+
+// secureTrieLeafDecoder returns the leaf decoder of the secure trie
+// of the given codec, nil if the codec is not the one of a secure trie.
+func secureTrieLeafDecoder(codec uint64) trieNodeLeafDecoder {
+	switch codec {
+	case MEthStateTrie:
+		return decodeEthStateTrieLeaf
+	case MEthStorageTrie:
+		return decodeEthStorageTrieLeaf
+	default:
+		return nil
+	}
+}
+
+// decodeHexProof returns the bytes of the given proof nodes, in hex.
+func decodeHexProof(proof []string) ([][]byte, error) {
+	var out [][]byte
+	for _, p := range proof {
+		b, err := hexutil.Decode(p)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// proveTrieKey follows the hashed key down the secure trie under the given
+// root, and returns the RLP, in hex, of every node on its way, along with
+// the decoded value of the leaf holding the key. The value is nil when the
+// nodes prove that the key is not in the trie.
+func proveTrieKey(ctx context.Context, ng node.NodeGetter, root *cid.Cid,
+	key []byte, leafDecoder trieNodeLeafDecoder) ([]string, interface{}, error) {
+	proof := []string{}
+
+	// The empty trie has no nodes to show
+	if root.Equals(commonHashToCid(root.Type(), types.EmptyRootHash)) {
+		return proof, nil, nil
+	}
+
+	path := nibbleToByte(crypto.Keccak256(key))
+	c := root
+	for {
+		nd, err := ng.Get(ctx, c)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tn, err := decodeTrieNode(c, nd.RawData(), leafDecoder)
+		if err != nil {
+			return nil, nil, err
+		}
+		proof = append(proof, hexutil.Encode(tn.rawdata))
+
+		var value interface{}
+		c, path, value, err = tn.followPath(path)
+		if err != nil || c == nil {
+			return proof, value, err
+		}
+	}
+}
+
+// followPath takes the nibbles of a key from this trie node, and returns
+// the child to follow, with the nibbles left. When there is no child to
+// follow, it returns the value of the key, nil if the key is not in the trie.
+func (t *TrieNode) followPath(path []byte) (*cid.Cid, []byte, interface{}, error) {
+	switch t.nodeKind {
+	case "branch":
+		if len(path) == 0 || t.elements[path[0]] == nil {
+			return nil, nil, nil, nil
+		}
+		return t.elements[path[0]].(*cid.Cid), path[1:], nil, nil
+	case "extension":
+		nibbles := t.elements[0].([]byte)
+		if !bytes.HasPrefix(path, nibbles) {
+			return nil, nil, nil, nil
+		}
+		return t.elements[1].(*cid.Cid), path[len(nibbles):], nil, nil
+	case "leaf":
+		if !bytes.Equal(path, t.elements[0].([]byte)) {
+			return nil, nil, nil, nil
+		}
+		return nil, nil, t.elements[1], nil
+	default:
+		return nil, nil, nil, fmt.Errorf("nodeKind case not implemented")
+	}
+}
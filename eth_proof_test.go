@@ -0,0 +1,357 @@
+package ipldeth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math/big"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+/*
+  INPUT
+*/
+
+func TestFromProofJSON(t *testing.T) {
+	ng, root := prepareStateNodeGetter(t)
+
+	address := common.HexToAddress("0x5abfec25f74cd88437631a7731906932776356f9")
+	res, err := GetProof(context.Background(), ng, root, address, []string{"0x0"})
+	checkError(err, t)
+	stateRoot := crypto.Keccak256Hash(ng[root.String()].RawData())
+
+	account, stateNodes, storageNodes, err := FromProofJSONAtRoot(prepareProofJSON(t, res), stateRoot)
+	checkError(err, t)
+
+	if account == nil || account.Balance.String() != "11901484239480000000000000" {
+		t.Fatal("Wrong account")
+	}
+	if len(stateNodes) != 4 {
+		t.Fatalf("Wrong number of state trie nodes\r\nexpected %d\r\ngot %d", 4, len(stateNodes))
+	}
+	if stateNodes[0].Cid().String() != root.String() {
+		t.Fatal("Wrong first state trie node, expected the root")
+	}
+	if len(storageNodes) != 0 {
+		t.Fatal("Expected no storage trie nodes for an empty storage")
+	}
+
+	// Without a state root, the proof is checked against its first node
+	account, stateNodes, _, err = FromProofJSON(prepareProofJSON(t, res))
+	checkError(err, t)
+	if account == nil || len(stateNodes) != 4 {
+		t.Fatal("Wrong account proven without a state root")
+	}
+
+	// With one, against the given state root, not its own
+	_, _, _, err = FromProofJSONAtRoot(prepareProofJSON(t, res), common.HexToHash("0x01"))
+	if _, ok := err.(*ProofError); !ok {
+		t.Fatalf("Expected a ProofError, got %v", err)
+	}
+
+	// The account proof must match the account fields
+	res.Nonce = 1
+	_, _, _, err = FromProofJSONAtRoot(prepareProofJSON(t, res), stateRoot)
+	if err == nil || err.Error() != "account proof does not match the account fields" {
+		t.Fatal("Expected error 'account proof does not match the account fields'")
+	}
+
+	// And the storage proofs their values
+	res.Nonce = 0
+	res.StorageProof[0].Value = (*hexutil.Big)(big.NewInt(1))
+	_, _, _, err = FromProofJSONAtRoot(prepareProofJSON(t, res), stateRoot)
+	if err == nil || err.Error() != "storage proof does not match the value of slot 0x0" {
+		t.Fatal("Expected error 'storage proof does not match the value of slot 0x0'")
+	}
+}
+
+func TestFromProofJSONWrongRoot(t *testing.T) {
+	ng, root := prepareStateNodeGetter(t)
+
+	address := common.HexToAddress("0x5abfec25f74cd88437631a7731906932776356f9")
+	res, err := GetProof(context.Background(), ng, root, address, nil)
+	checkError(err, t)
+
+	// A first node not linking to the second breaks the chain of hashes
+	res.AccountProof[0], res.AccountProof[1] = res.AccountProof[1], res.AccountProof[0]
+	_, _, _, err = FromProofJSON(prepareProofJSON(t, res))
+	if _, ok := err.(*ProofError); !ok {
+		t.Fatalf("Expected a ProofError, got %v", err)
+	}
+
+	res.AccountProof = nil
+	_, _, _, err = FromProofJSON(prepareProofJSON(t, res))
+	if err == nil || err.Error() != "empty account proof" {
+		t.Fatalf("Expected error 'empty account proof'\r\ngot %v", err)
+	}
+}
+
+func TestFromProofJSONAbsentAccount(t *testing.T) {
+	ng, root := prepareStateNodeGetter(t)
+
+	address := common.HexToAddress("0x0000000000000000000000000000000000000932")
+	res, err := GetProof(context.Background(), ng, root, address, nil)
+	checkError(err, t)
+	stateRoot := crypto.Keccak256Hash(ng[root.String()].RawData())
+
+	account, stateNodes, _, err := FromProofJSONAtRoot(prepareProofJSON(t, res), stateRoot)
+	checkError(err, t)
+
+	if account != nil {
+		t.Fatal("Expected no account")
+	}
+	if len(stateNodes) != 4 {
+		t.Fatalf("Wrong number of state trie nodes\r\nexpected %d\r\ngot %d", 4, len(stateNodes))
+	}
+
+	// An absent account can't have the fields of an existing one
+	edits := []func(*AccountResult){
+		func(res *AccountResult) { res.Balance = (*hexutil.Big)(big.NewInt(1)) },
+		func(res *AccountResult) { res.Nonce = 1 },
+		func(res *AccountResult) { res.CodeHash = common.HexToHash("0x01") },
+		func(res *AccountResult) { res.StorageHash = common.HexToHash("0x01") },
+	}
+	for _, edit := range edits {
+		res, err := GetProof(context.Background(), ng, root, address, nil)
+		checkError(err, t)
+		edit(res)
+
+		_, _, _, err = FromProofJSONAtRoot(prepareProofJSON(t, res), stateRoot)
+		if err == nil || err.Error() != "absent account proof does not match the account fields" {
+			t.Fatalf("Expected error 'absent account proof does not match the account fields'\r\ngot %v", err)
+		}
+	}
+}
+
+/*
+  OUTPUT
+*/
+
+func TestGetProofAccount(t *testing.T) {
+	ng, root := prepareStateNodeGetter(t)
+
+	address := common.HexToAddress("0x5abfec25f74cd88437631a7731906932776356f9")
+	res, err := GetProof(context.Background(), ng, root, address, []string{"0x0"})
+	checkError(err, t)
+
+	if len(res.AccountProof) != 4 {
+		t.Fatalf("Wrong number of proof nodes\r\nexpected %d\r\ngot %d", 4, len(res.AccountProof))
+	}
+	if res.AccountProof[0] != hexutil.Encode(ng[root.String()].RawData()) {
+		t.Fatal("Wrong first proof node, expected the root")
+	}
+
+	if res.Balance.ToInt().String() != "11901484239480000000000000" {
+		t.Fatal("Wrong balance")
+	}
+	if res.Nonce != 0 {
+		t.Fatal("Wrong nonce")
+	}
+	if res.StorageHash.Hex() != "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421" {
+		t.Fatal("Wrong storage hash")
+	}
+	if res.CodeHash != emptyCodeHash {
+		t.Fatal("Wrong code hash")
+	}
+
+	// The storage of this account is empty
+	if len(res.StorageProof) != 1 {
+		t.Fatal("Expected one storage proof")
+	}
+	sp := res.StorageProof[0]
+	if sp.Key != "0x0" || len(sp.Proof) != 0 || sp.Value.ToInt().Sign() != 0 {
+		t.Fatal("Wrong storage proof for an empty storage")
+	}
+}
+
+func TestGetProofAbsentAccount(t *testing.T) {
+	ng, root := prepareStateNodeGetter(t)
+
+	// Its keccak-256 is cdd60470..., diverging at the leaf of the account above
+	address := common.HexToAddress("0x0000000000000000000000000000000000000932")
+	res, err := GetProof(context.Background(), ng, root, address, nil)
+	checkError(err, t)
+
+	if len(res.AccountProof) != 4 {
+		t.Fatalf("Wrong number of proof nodes\r\nexpected %d\r\ngot %d", 4, len(res.AccountProof))
+	}
+	if res.Balance.ToInt().Sign() != 0 || res.Nonce != 0 {
+		t.Fatal("Expected an empty account")
+	}
+	if res.StorageProof == nil || len(res.StorageProof) != 0 {
+		t.Fatal("Expected an empty storage proof list")
+	}
+}
+
+func TestGetProofErrors(t *testing.T) {
+	ng, root := prepareStateNodeGetter(t)
+	address := common.HexToAddress("0x5abfec25f74cd88437631a7731906932776356f9")
+
+	_, err := GetProof(context.Background(), ng, root, address, []string{"12"})
+	if err == nil || err.Error() != "invalid storage slot 12" {
+		t.Fatal("Expected error 'invalid storage slot 12'")
+	}
+
+	// Remove the leaves, the one of the account among them
+	for k, n := range ng {
+		if n.(*EthStateTrie).nodeKind == "leaf" {
+			delete(ng, k)
+		}
+	}
+
+	_, err = GetProof(context.Background(), ng, root, address, nil)
+	if err != node.ErrNotFound {
+		t.Fatalf("Expected error %v, got %v", node.ErrNotFound, err)
+	}
+}
+
+func TestVerifyProof(t *testing.T) {
+	root, proof := prepareAccountProof(t)
+	address := common.HexToAddress("0x5abfec25f74cd88437631a7731906932776356f9")
+
+	res, err := VerifyProof(MEthStateTrie, root, address[:], proof)
+	checkError(err, t)
+
+	if !res.Included {
+		t.Fatal("Expected the account to be included")
+	}
+	as, ok := res.Value.(*EthAccountSnapshot)
+	if !ok {
+		t.Fatal("Expected an account snapshot")
+	}
+	if as.Balance.String() != "11901484239480000000000000" {
+		t.Fatal("Wrong balance")
+	}
+
+	// The same nodes prove the absence of this address
+	absent := common.HexToAddress("0x0000000000000000000000000000000000000932")
+	res, err = VerifyProof(MEthStateTrie, root, absent[:], proof)
+	checkError(err, t)
+	if res.Included || res.Value != nil {
+		t.Fatal("Expected the account not to be included")
+	}
+
+	// So does nothing for the empty trie
+	res, err = VerifyProof(MEthStorageTrie, types.EmptyRootHash, make([]byte, 32), nil)
+	checkError(err, t)
+	if res.Included {
+		t.Fatal("Expected the slot not to be included")
+	}
+}
+
+func TestVerifyProofErrors(t *testing.T) {
+	root, proof := prepareAccountProof(t)
+	address := common.HexToAddress("0x5abfec25f74cd88437631a7731906932776356f9")
+	malformed := getRLP([]interface{}{[]byte{}, make([]byte, 32)})
+
+	testCases := []struct {
+		root  common.Hash
+		proof [][]byte
+		step  int
+	}{
+		// wrong root
+		{common.Hash{}, proof, 0},
+		// missing the leaf
+		{root, proof[:3], 3},
+		// nodes past the leaf
+		{root, append(proof[:4:4], proof[0]), 4},
+		// out of order
+		{root, [][]byte{proof[0], proof[2], proof[1], proof[3]}, 1},
+		// tampered node
+		{root, [][]byte{proof[0], proof[1], append([]byte{}, proof[2][:40]...), proof[3]}, 2},
+		// a node hashing to the root, which is no trie node
+		{crypto.Keccak256Hash(malformed), [][]byte{malformed}, 0},
+	}
+
+	for _, tc := range testCases {
+		_, err := VerifyProof(MEthStateTrie, tc.root, address[:], tc.proof)
+		perr, ok := err.(*ProofError)
+		if !ok {
+			t.Fatalf("Expected a ProofError, got %v", err)
+		}
+		if perr.Step != tc.step {
+			t.Fatalf("Wrong failing step\r\nexpected %d\r\ngot %d", tc.step, perr.Step)
+		}
+	}
+
+	_, err := VerifyProof(MEthTxTrie, root, address[:], proof)
+	if err == nil || err.Error() != "no proofs for codec 92" {
+		t.Fatal("Expected error 'no proofs for codec 92'")
+	}
+}
+
+/*
+  AUXILIARS
+*/
+
+// mapNodeGetter is a node.NodeGetter over a map of nodes keyed by cid.
+type mapNodeGetter map[string]node.Node
+
+func (m mapNodeGetter) Get(ctx context.Context, c *cid.Cid) (node.Node, error) {
+	n, ok := m[c.String()]
+	if !ok {
+		return nil, node.ErrNotFound
+	}
+	return n, nil
+}
+
+func (m mapNodeGetter) GetMany(ctx context.Context, cs []*cid.Cid) <-chan *node.NodeOption {
+	out := make(chan *node.NodeOption, len(cs))
+	for _, c := range cs {
+		n, err := m.Get(ctx, c)
+		out <- &node.NodeOption{Node: n, Err: err}
+	}
+	close(out)
+	return out
+}
+
+// prepareStateNodeGetter returns the state trie nodes of the test data
+// as a node.NodeGetter, along with the cid of the state root of block 0.
+func prepareStateNodeGetter(t *testing.T) (mapNodeGetter, *cid.Cid) {
+	ng := make(mapNodeGetter)
+	for k, n := range prepareStateTrieMap(t) {
+		ng[k] = n
+	}
+
+	root, err := cid.Decode("z45oqTS97WG4WsMjquajJ8PB9Ubt3ks7rGmo14P5XWjnPL7LHDM")
+	checkError(err, t)
+
+	return ng, root
+}
+
+// prepareAccountProof returns the state root of block 0 and the proof of
+// the account 0x5abfec25f74cd88437631a7731906932776356f9 against it.
+func prepareAccountProof(t *testing.T) (common.Hash, [][]byte) {
+	ng, root := prepareStateNodeGetter(t)
+
+	address := common.HexToAddress("0x5abfec25f74cd88437631a7731906932776356f9")
+	res, err := GetProof(context.Background(), ng, root, address, nil)
+	checkError(err, t)
+
+	proof, err := decodeHexProof(res.AccountProof)
+	checkError(err, t)
+
+	return crypto.Keccak256Hash(proof[0]), proof
+}
+
+// prepareProofJSON returns the given result as the JSON-RPC
+// response of eth_getProof.
+func prepareProofJSON(t *testing.T, res *AccountResult) io.Reader {
+	b, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"result":  res,
+	})
+	checkError(err, t)
+
+	return bytes.NewReader(b)
+}
@@ -0,0 +1,340 @@
+package ipldeth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// RPCIngester pulls blocks from an ethereum JSON-RPC endpoint, along with
+// their ommers and receipts, and turns them into IPLD nodes, as FromBlockJSON,
+// FromUnclesJSON and FromReceipts do. Every block is checked against the
+// roots of its header.
+type RPCIngester struct {
+	// URL of the JSON-RPC endpoint
+	URL string
+
+	// Client makes the requests, http.DefaultClient when nil.
+	Client *http.Client
+
+	// Concurrency is the number of blocks fetched at once, 1 when unset.
+	Concurrency int
+
+	// Retries is the number of times a failed request is tried
+	// again, waiting RetryDelay between tries.
+	Retries    int
+	RetryDelay time.Duration
+
+	// Checkpoint, when set, keeps the number of the next block to
+	// ingest, for an interrupted ingestion to resume where it stopped.
+	Checkpoint Checkpoint
+
+	id uint64
+}
+
+// Checkpoint keeps the progress of an ingestion.
+type Checkpoint interface {
+	// Load returns the number of the next block to ingest,
+	// and false when there is no checkpoint yet.
+	Load() (uint64, bool, error)
+
+	// Save keeps the number of the next block to ingest.
+	Save(next uint64) error
+}
+
+// FileCheckpoint is a Checkpoint kept in the file of the given
+// name, holding the number of the next block to ingest.
+type FileCheckpoint string
+
+// rpcError is the error of a JSON-RPC response.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface.
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+/*
+  INPUT
+*/
+
+// Ingest pulls the blocks from first to last, both included, and hands
+// them to fn in order. Blocks are fetched concurrently, but fn is called
+// from a single goroutine. With a Checkpoint, the ingestion starts from
+// the block it holds when it is past first, and the checkpoint moves on
+// once fn returns for each block. The first error stops the ingestion.
+func (ri *RPCIngester) Ingest(ctx context.Context, first, last uint64, fn func(*BlockNodes) error) error {
+	if ri.Checkpoint != nil {
+		next, ok, err := ri.Checkpoint.Load()
+		if err != nil {
+			return err
+		}
+		if ok && next > first {
+			first = next
+		}
+	}
+	if first > last {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	concurrency := ri.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// The blocks are fetched in the background, and handed in order.
+	// Queued blocks, and the one being waited for, are the ones fetched.
+	type result struct {
+		rb  *BlockNodes
+		err error
+	}
+	pending := make(chan chan result, concurrency-1)
+	go func() {
+		defer close(pending)
+		for n := first; ; n++ {
+			ch := make(chan result, 1)
+			select {
+			case pending <- ch:
+			case <-ctx.Done():
+				return
+			}
+			go func(n uint64) {
+				rb, err := ri.GetBlock(ctx, n)
+				ch <- result{rb: rb, err: err}
+			}(n)
+
+			if n == last {
+				return
+			}
+		}
+	}()
+
+	for ch := range pending {
+		res := <-ch
+		if res.err != nil {
+			return res.err
+		}
+		if err := fn(res.rb); err != nil {
+			return err
+		}
+		if ri.Checkpoint != nil {
+			if err := ri.Checkpoint.Save(res.rb.Block.Number.Uint64() + 1); err != nil {
+				return err
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// GetBlock pulls the block of the given number, along with its ommers
+// and its receipts, the latter with a single eth_getBlockReceipts.
+func (ri *RPCIngester) GetBlock(ctx context.Context, number uint64) (*BlockNodes, error) {
+	res, err := ri.call(ctx, "eth_getBlockByNumber", hexutil.EncodeUint64(number), true)
+	if err != nil {
+		return nil, fmt.Errorf("block %d: %v", number, err)
+	}
+
+	rb, err := FromBlockJSON(bytes.NewReader(res))
+	if err != nil {
+		return nil, fmt.Errorf("block %d: %v", number, err)
+	}
+
+	// The block only gave the hashes of its ommers
+	if rb.UncleList == nil {
+		var obj struct {
+			Result struct {
+				Uncles []json.RawMessage `json:"uncles"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal(res, &obj); err != nil {
+			return nil, fmt.Errorf("block %d: %v", number, err)
+		}
+
+		var rs []io.Reader
+		for i := range obj.Result.Uncles {
+			u, err := ri.call(ctx, "eth_getUncleByBlockHashAndIndex",
+				rb.Block.Hash(), hexutil.EncodeUint64(uint64(i)))
+			if err != nil {
+				return nil, fmt.Errorf("block %d: ommer %d: %v", number, i, err)
+			}
+			rs = append(rs, bytes.NewReader(u))
+		}
+
+		rb.Uncles, rb.UncleList, err = FromUnclesJSON(rb.Block, rs)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %v", number, err)
+		}
+	}
+
+	// The JSON receipts do not carry their encoding, FromReceipts rebuilds
+	// it from the types of the transactions, which must match theirs
+	var rcts []*types.Receipt
+	if len(rb.Txs) > 0 {
+		res, err := ri.call(ctx, "eth_getBlockReceipts", rb.Block.Hash())
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %v", number, err)
+		}
+
+		var obj struct {
+			Result []*types.Receipt `json:"result"`
+		}
+		if err := json.Unmarshal(res, &obj); err != nil {
+			return nil, fmt.Errorf("block %d: receipts: %v", number, err)
+		}
+		var objTypes struct {
+			Result []struct {
+				Type *hexutil.Uint64 `json:"type"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal(res, &objTypes); err != nil {
+			return nil, fmt.Errorf("block %d: receipts: %v", number, err)
+		}
+		for i, r := range objTypes.Result {
+			if i < len(rb.Txs) && r.Type != nil && uint64(*r.Type) != uint64(rb.Txs[i].Type()) {
+				return nil, fmt.Errorf("block %d: receipt %d of type %d for a transaction of type %d",
+					number, i, uint64(*r.Type), rb.Txs[i].Type())
+			}
+		}
+		rcts = obj.Result
+	}
+
+	rb.Receipts, rb.ReceiptTrieNodes, rb.Logs, rb.LogTrieNodes, err = FromReceipts(rb.Block, rb.Txs, rcts)
+	if err != nil {
+		return nil, fmt.Errorf("block %d: %v", number, err)
+	}
+
+	return rb, nil
+}
+
+/*
+  Checkpoint INTERFACE
+*/
+
+// Load reads the checkpoint file, if there is one.
+func (f FileCheckpoint) Load() (uint64, bool, error) {
+	b, err := ioutil.ReadFile(string(f))
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	next, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid checkpoint file %s: %v", string(f), err)
+	}
+	return next, true, nil
+}
+
+// Save writes the checkpoint file. It is written aside and renamed,
+// not to be left half written.
+func (f FileCheckpoint) Save(next uint64) error {
+	tmp := string(f) + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.FormatUint(next, 10)+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, string(f))
+}
+
+/*
+  AUXILIARS
+*/
+
+// call makes a JSON-RPC request, trying it again on failure, and returns
+// the whole response. A null result is an error, as the endpoint does not
+// have what was asked for, at least not yet.
+func (ri *RPCIngester) call(ctx context.Context, method string, params ...interface{}) ([]byte, error) {
+	var err error
+	for try := 0; try <= ri.Retries; try++ {
+		if try > 0 {
+			select {
+			case <-time.After(ri.RetryDelay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		var res []byte
+		res, err = ri.callOnce(ctx, method, params)
+		if err == nil {
+			return res, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+
+	return nil, fmt.Errorf("%s: %v", method, err)
+}
+
+// callOnce makes a JSON-RPC request.
+func (ri *RPCIngester) callOnce(ctx context.Context, method string, params []interface{}) ([]byte, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      atomic.AddUint64(&ri.id, 1),
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", ri.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := ri.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	res, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http status %s", resp.Status)
+	}
+
+	var obj struct {
+		Result json.RawMessage `json:"result"`
+		Error  *rpcError       `json:"error"`
+	}
+	if err := json.Unmarshal(res, &obj); err != nil {
+		return nil, err
+	}
+	if obj.Error != nil {
+		return nil, obj.Error
+	}
+	if len(obj.Result) == 0 || string(obj.Result) == "null" {
+		return nil, fmt.Errorf("not found")
+	}
+
+	return res, nil
+}
@@ -0,0 +1,372 @@
+package ipldeth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+/*
+  INPUT
+*/
+
+func TestRPCIngesterIngest(t *testing.T) {
+	srv := httptest.NewServer(prepareFakeRPC(t))
+	defer srv.Close()
+
+	ri := &RPCIngester{URL: srv.URL, Concurrency: 2}
+
+	var blocks []*BlockNodes
+	err := ri.Ingest(context.Background(), 0, 2, func(rb *BlockNodes) error {
+		blocks = append(blocks, rb)
+		return nil
+	})
+	checkError(err, t)
+
+	if len(blocks) != 3 {
+		t.Fatalf("Wrong number of blocks\r\nexpected %d\r\ngot %d", 3, len(blocks))
+	}
+	for i, rb := range blocks {
+		if rb.Block.Number.Int64() != int64(i) {
+			t.Fatalf("Wrong block order\r\nexpected %d\r\ngot %s", i, rb.Block.Number)
+		}
+	}
+
+	rb := blocks[1]
+	if len(rb.Txs) != 1 || len(rb.TxTrieNodes) != 1 {
+		t.Fatal("Wrong transactions")
+	}
+	if len(rb.Uncles) != 1 || rb.UncleList == nil {
+		t.Fatal("Wrong ommers")
+	}
+	if len(rb.Receipts) != 1 || len(rb.ReceiptTrieNodes) != 1 || len(rb.Logs) != 2 {
+		t.Fatal("Wrong receipts")
+	}
+}
+
+func TestRPCIngesterRetries(t *testing.T) {
+	fake := prepareFakeRPC(t)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	fake.failures = 2
+	ri := &RPCIngester{URL: srv.URL, Retries: 2}
+	_, err := ri.GetBlock(context.Background(), 1)
+	checkError(err, t)
+
+	fake.failures = 2
+	ri.Retries = 1
+	_, err = ri.GetBlock(context.Background(), 1)
+	expected := "block 1: eth_getBlockByNumber: http status 503 Service Unavailable"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("Expected error '%s'\r\ngot %v", expected, err)
+	}
+}
+
+func TestRPCIngesterNotFound(t *testing.T) {
+	srv := httptest.NewServer(prepareFakeRPC(t))
+	defer srv.Close()
+
+	ri := &RPCIngester{URL: srv.URL, Concurrency: 4}
+
+	var n int
+	err := ri.Ingest(context.Background(), 0, 5, func(rb *BlockNodes) error {
+		n++
+		return nil
+	})
+	expected := "block 3: eth_getBlockByNumber: not found"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("Expected error '%s'\r\ngot %v", expected, err)
+	}
+	if n != 3 {
+		t.Fatalf("Wrong number of blocks handed\r\nexpected %d\r\ngot %d", 3, n)
+	}
+}
+
+func TestRPCIngesterRPCError(t *testing.T) {
+	fake := prepareFakeRPC(t)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	// The receipt of the transaction of block 1 is missing
+	fake.receipts = nil
+	ri := &RPCIngester{URL: srv.URL}
+	_, err := ri.GetBlock(context.Background(), 1)
+	if err == nil {
+		t.Fatal("Expected an error for the missing receipt")
+	}
+}
+
+func TestRPCIngesterTypedReceipts(t *testing.T) {
+	block := readRPCResult(t, "test_data/eth-block-body-json-cancun")
+	receipts := readRPCResult(t, "test_data/eth-block-receipts-json-cancun")
+
+	fake := &fakeRPC{
+		blocks:   map[uint64]json.RawMessage{0x1312d00: block},
+		receipts: map[common.Hash]json.RawMessage{common.HexToHash("0xc683c8eaecaeb01be406f157e15483d94e32770fb12e14b2720a141b42600914"): receipts},
+	}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	ri := &RPCIngester{URL: srv.URL}
+	rb, err := ri.GetBlock(context.Background(), 0x1312d00)
+	checkError(err, t)
+
+	if len(rb.Receipts) != 5 || len(rb.Logs) != 2 {
+		t.Fatal("Wrong receipts")
+	}
+	for i, rct := range rb.Receipts {
+		if rct.Type() != rb.Txs[i].Type() {
+			t.Fatalf("Wrong type of receipt %d\r\nexpected %d\r\ngot %d", i, rb.Txs[i].Type(), rct.Type())
+		}
+	}
+
+	root := commonHashToCid(MEthTxReceiptTrie,
+		common.HexToHash("1d33a16fc5b9027f23c0ef5828c9e777c8fdd683231b46848be08eb368f47d21"))
+	var found bool
+	for _, rtn := range rb.ReceiptTrieNodes {
+		if rtn.Cid().Equals(root) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("Receipt trie root not found among the returned nodes")
+	}
+}
+
+func TestRPCIngesterCheckpoint(t *testing.T) {
+	srv := httptest.NewServer(prepareFakeRPC(t))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "ipldeth")
+	checkError(err, t)
+	defer os.RemoveAll(dir)
+
+	cp := FileCheckpoint(filepath.Join(dir, "checkpoint"))
+	ri := &RPCIngester{URL: srv.URL, Checkpoint: cp}
+
+	// Stop at block 1
+	stop := fmt.Errorf("stop")
+	err = ri.Ingest(context.Background(), 0, 2, func(rb *BlockNodes) error {
+		if rb.Block.Number.Int64() == 1 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Fatalf("Expected error '%v'\r\ngot %v", stop, err)
+	}
+
+	next, ok, err := cp.Load()
+	checkError(err, t)
+	if !ok || next != 1 {
+		t.Fatalf("Wrong checkpoint\r\nexpected %d\r\ngot %d", 1, next)
+	}
+
+	// And resume from it
+	var numbers []int64
+	err = ri.Ingest(context.Background(), 0, 2, func(rb *BlockNodes) error {
+		numbers = append(numbers, rb.Block.Number.Int64())
+		return nil
+	})
+	checkError(err, t)
+	if len(numbers) != 2 || numbers[0] != 1 || numbers[1] != 2 {
+		t.Fatalf("Wrong blocks resumed\r\nexpected [1 2]\r\ngot %v", numbers)
+	}
+
+	next, _, err = cp.Load()
+	checkError(err, t)
+	if next != 3 {
+		t.Fatalf("Wrong checkpoint\r\nexpected %d\r\ngot %d", 3, next)
+	}
+}
+
+/*
+  AUXILIARS
+*/
+
+// fakeRPC is a JSON-RPC endpoint serving a few blocks, their
+// ommers and their receipts, the latter by block hash.
+type fakeRPC struct {
+	blocks   map[uint64]json.RawMessage
+	uncles   map[string]json.RawMessage
+	receipts map[common.Hash]json.RawMessage
+
+	// failures is the number of requests to fail before answering
+	failures int32
+}
+
+func (f *fakeRPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if atomic.AddInt32(&f.failures, -1) >= 0 {
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	var result json.RawMessage
+	switch req.Method {
+	case "eth_getBlockByNumber":
+		var number hexutil.Uint64
+		json.Unmarshal(req.Params[0], &number)
+		result = f.blocks[uint64(number)]
+	case "eth_getUncleByBlockHashAndIndex":
+		var hash common.Hash
+		var index hexutil.Uint64
+		json.Unmarshal(req.Params[0], &hash)
+		json.Unmarshal(req.Params[1], &index)
+		result = f.uncles[fmt.Sprintf("%x/%d", hash, index)]
+	case "eth_getBlockReceipts":
+		var hash common.Hash
+		json.Unmarshal(req.Params[0], &hash)
+		result = f.receipts[hash]
+	default:
+		res["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+	}
+	if _, ok := res["error"]; !ok {
+		if result == nil {
+			result = json.RawMessage("null")
+		}
+		res["result"] = result
+	}
+
+	json.NewEncoder(w).Encode(res)
+}
+
+// prepareFakeRPC returns a fakeRPC serving blocks 0 to 2. Block 1
+// holds a transaction, with its receipt, and an ommer.
+func prepareFakeRPC(t *testing.T) *fakeRPC {
+	f := &fakeRPC{
+		blocks:   make(map[uint64]json.RawMessage),
+		uncles:   make(map[string]json.RawMessage),
+		receipts: make(map[common.Hash]json.RawMessage),
+	}
+
+	genesis := &types.Header{
+		UncleHash:   types.EmptyUncleHash,
+		TxHash:      types.EmptyRootHash,
+		ReceiptHash: types.EmptyRootHash,
+		Difficulty:  big.NewInt(0x400),
+		Number:      big.NewInt(0),
+		GasLimit:    big.NewInt(0x47b760),
+		GasUsed:     big.NewInt(0),
+		Time:        big.NewInt(0),
+	}
+	f.blocks[0] = prepareRPCBlockJSON(t, genesis, nil, nil)
+
+	tx := types.NewTransaction(0,
+		common.HexToAddress("0x5abfec25f74cd88437631a7731906932776356f9"),
+		big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil)
+	tt := newTxTrie()
+	tt.add(0, NewTx(tx).RawData())
+
+	rct := &types.Receipt{
+		Status:            types.ReceiptStatusSuccessful,
+		CumulativeGasUsed: big.NewInt(21000),
+		Logs:              prepareLogs(2),
+		TxHash:            tx.Hash(),
+		GasUsed:           big.NewInt(21000),
+	}
+	uncle := &types.Header{
+		ParentHash:  genesis.Hash(),
+		UncleHash:   types.EmptyUncleHash,
+		TxHash:      types.EmptyRootHash,
+		ReceiptHash: types.EmptyRootHash,
+		Difficulty:  big.NewInt(0x400),
+		Number:      big.NewInt(1),
+		GasLimit:    big.NewInt(0x47b760),
+		GasUsed:     big.NewInt(0),
+		Time:        big.NewInt(14),
+	}
+
+	block1 := &types.Header{
+		ParentHash:  genesis.Hash(),
+		UncleHash:   types.CalcUncleHash([]*types.Header{uncle}),
+		TxHash:      common.BytesToHash(tt.rootHash()),
+		ReceiptHash: types.DeriveSha(types.Receipts{rct}),
+		Difficulty:  big.NewInt(0x400),
+		Number:      big.NewInt(1),
+		GasLimit:    big.NewInt(0x47b760),
+		GasUsed:     big.NewInt(21000),
+		Time:        big.NewInt(15),
+	}
+	f.blocks[1] = prepareRPCBlockJSON(t, block1, []*types.Transaction{tx}, []*types.Header{uncle})
+	b, err := json.Marshal(uncle)
+	checkError(err, t)
+	f.uncles[fmt.Sprintf("%x/%d", block1.Hash(), 0)] = b
+	b, err = json.Marshal([]*types.Receipt{rct})
+	checkError(err, t)
+	f.receipts[block1.Hash()] = b
+
+	block2 := &types.Header{
+		ParentHash:  block1.Hash(),
+		UncleHash:   types.EmptyUncleHash,
+		TxHash:      types.EmptyRootHash,
+		ReceiptHash: types.EmptyRootHash,
+		Difficulty:  big.NewInt(0x400),
+		Number:      big.NewInt(2),
+		GasLimit:    big.NewInt(0x47b760),
+		GasUsed:     big.NewInt(0),
+		Time:        big.NewInt(30),
+	}
+	f.blocks[2] = prepareRPCBlockJSON(t, block2, nil, nil)
+
+	return f
+}
+
+// readRPCResult returns the "result" field of a recorded JSON-RPC response.
+func readRPCResult(t *testing.T, filepath string) json.RawMessage {
+	b, err := ioutil.ReadFile(filepath)
+	checkError(err, t)
+
+	var obj struct {
+		Result json.RawMessage `json:"result"`
+	}
+	checkError(json.Unmarshal(b, &obj), t)
+	return obj.Result
+}
+
+// prepareRPCBlockJSON returns the result of eth_getBlockByNumber for
+// the given header, with full transactions and the hashes of the ommers.
+func prepareRPCBlockJSON(t *testing.T, h *types.Header, txs []*types.Transaction, uncles []*types.Header) json.RawMessage {
+	b, err := json.Marshal(h)
+	checkError(err, t)
+
+	var fields map[string]interface{}
+	checkError(json.Unmarshal(b, &fields), t)
+
+	hashes := []common.Hash{}
+	for _, u := range uncles {
+		hashes = append(hashes, u.Hash())
+	}
+	if txs == nil {
+		txs = []*types.Transaction{}
+	}
+	fields["transactions"] = txs
+	fields["uncles"] = hashes
+
+	b, err = json.Marshal(fields)
+	checkError(err, t)
+	return b
+}
@@ -0,0 +1,324 @@
+package ipldeth
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	cid "github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DiffKind tells how an account or a slot changed between two states.
+type DiffKind int
+
+// Kinds of changes found by DiffState
+const (
+	DiffCreated DiffKind = iota
+	DiffDeleted
+	DiffModified
+)
+
+// String is a helper for output
+func (k DiffKind) String() string {
+	switch k {
+	case DiffCreated:
+		return "created"
+	case DiffDeleted:
+		return "deleted"
+	case DiffModified:
+		return "modified"
+	default:
+		return fmt.Sprintf("DiffKind(%d)", int(k))
+	}
+}
+
+// StateDiff holds the changes between two states, and the cids of the
+// trie nodes of the second state not found in the first one.
+type StateDiff struct {
+	Accounts []AccountDiff
+	NewNodes []*cid.Cid
+}
+
+// AccountDiff is a change to an account. As the state trie does not hold
+// the addresses, the account is given by the keccak256 of its address.
+// From is nil for created accounts, and To is nil for deleted ones.
+type AccountDiff struct {
+	Key  common.Hash
+	Kind DiffKind
+	From *EthAccountSnapshot
+	To   *EthAccountSnapshot
+
+	// Storage holds the slots changed along with the account
+	Storage []SlotDiff
+}
+
+// SlotDiff is a change to a storage slot, given by the keccak256 of
+// its position. From is nil for created slots, To for deleted ones.
+type SlotDiff struct {
+	Key  common.Hash
+	Kind DiffKind
+	From *EthStorageValue
+	To   *EthStorageValue
+}
+
+/*
+  OUTPUT
+*/
+
+// DiffState walks the state tries under the given roots, along with the
+// storage tries of the accounts they hold, and returns the accounts and
+// slots that changed from the first state to the second one.
+// Subtries with the same cid on both sides are equal, and not walked.
+func DiffState(ctx context.Context, ng node.NodeGetter, from, to *cid.Cid) (*StateDiff, error) {
+	d := &stateDiffer{ctx: ctx, ng: ng}
+
+	var diffs []AccountDiff
+	err := d.walk(nil, d.ref(from), d.ref(to), func(key []byte, a, b interface{}) error {
+		ad := AccountDiff{Key: common.BytesToHash(key)}
+		ad.From, _ = a.(*EthAccountSnapshot)
+		ad.To, _ = b.(*EthAccountSnapshot)
+		if (a != nil && ad.From == nil) || (b != nil && ad.To == nil) {
+			return fmt.Errorf("state trie leaf is not an account")
+		}
+
+		fromRoot := commonHashToCid(MEthStorageTrie, types.EmptyRootHash)
+		toRoot := fromRoot
+		switch {
+		case ad.From == nil:
+			ad.Kind = DiffCreated
+			toRoot = keccak256ToCid(MEthStorageTrie, ad.To.Root)
+		case ad.To == nil:
+			ad.Kind = DiffDeleted
+			fromRoot = keccak256ToCid(MEthStorageTrie, ad.From.Root)
+		default:
+			ad.Kind = DiffModified
+			fromRoot = keccak256ToCid(MEthStorageTrie, ad.From.Root)
+			toRoot = keccak256ToCid(MEthStorageTrie, ad.To.Root)
+		}
+
+		err := d.walk(nil, d.ref(fromRoot), d.ref(toRoot), func(key []byte, a, b interface{}) error {
+			sd := SlotDiff{Key: common.BytesToHash(key)}
+			sd.From, _ = a.(*EthStorageValue)
+			sd.To, _ = b.(*EthStorageValue)
+			if (a != nil && sd.From == nil) || (b != nil && sd.To == nil) {
+				return fmt.Errorf("storage trie leaf is not a slot value")
+			}
+
+			switch {
+			case sd.From == nil:
+				sd.Kind = DiffCreated
+			case sd.To == nil:
+				sd.Kind = DiffDeleted
+			default:
+				sd.Kind = DiffModified
+			}
+			ad.Storage = append(ad.Storage, sd)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		diffs = append(diffs, ad)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &StateDiff{
+		Accounts: diffs,
+		NewNodes: d.newNodes,
+	}, nil
+}
+
+/*
+  AUXILIARS
+*/
+
+// stateDiffer walks two tries side by side, keeping the cids
+// of the nodes found only in the second one.
+type stateDiffer struct {
+	ctx context.Context
+	ng  node.NodeGetter
+
+	newNodes []*cid.Cid
+}
+
+// diffRef is a subtrie to compare. In extensions, skip tells how many of
+// the nibbles of the node were already walked.
+type diffRef struct {
+	cid  *cid.Cid
+	tn   *TrieNode
+	skip int
+}
+
+// diffLeafFunc takes the key of a leaf that changed, and its values
+// in both tries, nil when the leaf is not found in one of them.
+type diffLeafFunc func(key []byte, a, b interface{}) error
+
+// ref returns the subtrie of the given root, nil if the trie is empty.
+func (d *stateDiffer) ref(c *cid.Cid) *diffRef {
+	if c.Equals(commonHashToCid(c.Type(), types.EmptyRootHash)) {
+		return nil
+	}
+	return &diffRef{cid: c}
+}
+
+// walk compares the subtries a and b found under the given path.
+func (d *stateDiffer) walk(path []byte, a, b *diffRef, fn diffLeafFunc) error {
+	if a == nil && b == nil {
+		return nil
+	}
+	if a != nil && b != nil && a.cid.Equals(b.cid) && a.skip == b.skip {
+		return nil
+	}
+
+	if err := d.load(a, false); err != nil {
+		return err
+	}
+	if err := d.load(b, true); err != nil {
+		return err
+	}
+
+	// Subtries of different shapes are compared leaf by leaf
+	if a == nil || b == nil || a.tn.nodeKind == "leaf" || b.tn.nodeKind == "leaf" {
+		return d.diffLeaves(path, a, b, fn)
+	}
+
+	ca, cb := a.expand(), b.expand()
+	for i := range ca {
+		p := append(append([]byte{}, path...), byte(i))
+		if err := d.walk(p, ca[i], cb[i], fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffLeaves compares all the leaves of the subtries a and b.
+func (d *stateDiffer) diffLeaves(path []byte, a, b *diffRef, fn diffLeafFunc) error {
+	la := make(map[string]interface{})
+	if err := d.leaves(path, a, false, la); err != nil {
+		return err
+	}
+	lb := make(map[string]interface{})
+	if err := d.leaves(path, b, true, lb); err != nil {
+		return err
+	}
+
+	var keys []string
+	for k := range la {
+		keys = append(keys, k)
+	}
+	for k := range lb {
+		if _, ok := la[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		va, vb := la[k], lb[k]
+		if va != nil && vb != nil && bytes.Equal(leafRawData(va), leafRawData(vb)) {
+			continue
+		}
+		if err := fn(byteFromNibbles([]byte(k)), va, vb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// leaves gathers the values of the leaves of the given subtrie,
+// by their keys in nibbles.
+func (d *stateDiffer) leaves(path []byte, r *diffRef, isNew bool, out map[string]interface{}) error {
+	if r == nil {
+		return nil
+	}
+	if err := d.load(r, isNew); err != nil {
+		return err
+	}
+
+	if r.tn.nodeKind == "leaf" {
+		key := append(append([]byte{}, path...), r.tn.elements[0].([]byte)[r.skip:]...)
+		out[string(key)] = r.tn.elements[1]
+		return nil
+	}
+
+	for i, c := range r.expand() {
+		p := append(append([]byte{}, path...), byte(i))
+		if err := d.leaves(p, c, isNew, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load fetches and decodes the trie node of the given subtrie, if it was
+// not yet. The nodes of the second trie are kept as new ones.
+func (d *stateDiffer) load(r *diffRef, isNew bool) error {
+	if r == nil || r.tn != nil {
+		return nil
+	}
+
+	leafDecoder := secureTrieLeafDecoder(r.cid.Type())
+	if leafDecoder == nil {
+		return fmt.Errorf("no state diff for codec %x", r.cid.Type())
+	}
+
+	nd, err := d.ng.Get(d.ctx, r.cid)
+	if err != nil {
+		return err
+	}
+	r.tn, err = decodeTrieNode(r.cid, nd.RawData(), leafDecoder)
+	if err != nil {
+		return err
+	}
+
+	if isNew && r.skip == 0 {
+		d.newNodes = append(d.newNodes, r.cid)
+	}
+	return nil
+}
+
+// expand returns the subtries under each of the 16 nibbles that follow
+// the given branch or extension.
+func (r *diffRef) expand() [16]*diffRef {
+	var out [16]*diffRef
+
+	switch r.tn.nodeKind {
+	case "branch":
+		for i := range out {
+			if c := r.tn.elements[i]; c != nil {
+				out[i] = &diffRef{cid: c.(*cid.Cid)}
+			}
+		}
+	case "extension":
+		rem := r.tn.elements[0].([]byte)[r.skip:]
+		if len(rem) == 1 {
+			out[rem[0]] = &diffRef{cid: r.tn.elements[1].(*cid.Cid)}
+		} else {
+			out[rem[0]] = &diffRef{cid: r.cid, tn: r.tn, skip: r.skip + 1}
+		}
+	}
+
+	return out
+}
+
+// leafRawData returns the RLP of a leaf value, to compare it.
+func leafRawData(v interface{}) []byte {
+	switch lv := v.(type) {
+	case *EthAccountSnapshot:
+		return lv.rawdata
+	case *EthStorageValue:
+		return lv.rawdata
+	default:
+		return nil
+	}
+}
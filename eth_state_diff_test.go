@@ -0,0 +1,116 @@
+package ipldeth
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+/*
+  OUTPUT
+*/
+
+func TestDiffState(t *testing.T) {
+	ng, root := prepareGenesisNodeGetter(t)
+
+	contract := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	deleted := common.HexToAddress("0x3282791d6fd713f1e94f4bfd565eaa78b3a0599d")
+	modified := common.HexToAddress("0x5abfec25f74cd88437631a7731906932776356f9")
+	created := common.HexToAddress("0x0000000000000000000000000000000000000932")
+
+	upd, err := UpdateState(context.Background(), ng, root, []AccountChange{
+		{
+			Address: contract,
+			Storage: map[common.Hash]common.Hash{
+				common.BigToHash(big.NewInt(0)): common.Hash{},
+				common.BigToHash(big.NewInt(1)): common.BigToHash(big.NewInt(5)),
+				common.BigToHash(big.NewInt(2)): common.BigToHash(big.NewInt(7)),
+			},
+		},
+		{Address: deleted, Delete: true},
+		{Address: modified, Balance: big.NewInt(1)},
+		{Address: created, Balance: big.NewInt(5)},
+	})
+	checkError(err, t)
+
+	newNodes := make(map[string]bool)
+	for _, stn := range upd.StateNodes {
+		ng[stn.Cid().String()] = stn
+		newNodes[stn.Cid().String()] = true
+	}
+	for _, stn := range upd.StorageNodes {
+		ng[stn.Cid().String()] = stn
+		newNodes[stn.Cid().String()] = true
+	}
+
+	diff, err := DiffState(context.Background(), ng, root, upd.Root)
+	checkError(err, t)
+
+	// In the order of their hashes: 3ed0..., 4cfa..., cdd3... and cdd6...
+	expected := []struct {
+		address common.Address
+		kind    DiffKind
+	}{
+		{contract, DiffModified},
+		{deleted, DiffDeleted},
+		{modified, DiffModified},
+		{created, DiffCreated},
+	}
+	if len(diff.Accounts) != len(expected) {
+		t.Fatalf("Wrong number of changed accounts\r\nexpected %d\r\ngot %d", len(expected), len(diff.Accounts))
+	}
+	for i, e := range expected {
+		ad := diff.Accounts[i]
+		if ad.Key != crypto.Keccak256Hash(e.address[:]) || ad.Kind != e.kind {
+			t.Fatalf("Wrong change %d\r\nexpected %s %s\r\ngot %x %s", i, e.address.Hex(), e.kind, ad.Key, ad.Kind)
+		}
+	}
+	if diff.Accounts[1].To != nil || diff.Accounts[3].From != nil {
+		t.Fatal("Expected no account on the missing side")
+	}
+	if diff.Accounts[2].From.Balance.String() != "11901484239480000000000000" ||
+		diff.Accounts[2].To.Balance.Int64() != 1 {
+		t.Fatal("Wrong balances of the modified account")
+	}
+
+	// Slots 0 (290d...), 2 (4057...) and 1 (b10e...)
+	storage := diff.Accounts[0].Storage
+	if fmt.Sprintf("%v %v %v", storage[0].Kind, storage[1].Kind, storage[2].Kind) != "deleted created modified" {
+		t.Fatal("Wrong storage changes")
+	}
+	if storage[1].To.Value.Int64() != 7 || storage[2].To.Value.Int64() != 5 {
+		t.Fatal("Wrong storage values")
+	}
+
+	// The new nodes are the ones created by the update, the root among them
+	if len(diff.NewNodes) == 0 || diff.NewNodes[0].String() != upd.Root.String() {
+		t.Fatal("Expected the new root to be the first new node")
+	}
+	for _, c := range diff.NewNodes {
+		if !newNodes[c.String()] {
+			t.Fatalf("Unexpected new node %s", c)
+		}
+	}
+}
+
+func TestDiffStateSameRoot(t *testing.T) {
+	ng, root := prepareGenesisNodeGetter(t)
+
+	// Nothing to fetch
+	for k := range ng {
+		if k != root.String() {
+			delete(ng, k)
+		}
+	}
+
+	diff, err := DiffState(context.Background(), ng, root, root)
+	checkError(err, t)
+
+	if len(diff.Accounts) != 0 || len(diff.NewNodes) != 0 {
+		t.Fatal("Expected no changes")
+	}
+}
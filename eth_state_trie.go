@@ -8,6 +8,7 @@ import (
 	cid "github.com/ipfs/go-cid"
 	node "github.com/ipfs/go-ipld-format"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -96,3 +97,43 @@ func (st *EthStateTrie) Loggable() map[string]interface{} {
 		"type": "eth-state-trie",
 	}
 }
+
+/*
+  Node INTERFACE
+*/
+
+// Resolve resolves a path through this node, stopping at any link boundary
+// and returning the object found as well as the remaining path to traverse.
+// Besides the nibbles of the trie, accounts can be reached by their address,
+// as in "accounts/<address>/balance".
+func (st *EthStateTrie) Resolve(p []string) (interface{}, []string, error) {
+	if len(p) != 0 && p[0] == "accounts" {
+		if len(p) == 1 {
+			return nil, nil, fmt.Errorf("missing account address")
+		}
+		if !common.IsHexAddress(p[1]) {
+			return nil, nil, fmt.Errorf("invalid account address %s", p[1])
+		}
+
+		key := common.HexToAddress(p[1])
+		p = append(secureKeyPath(key[:]), p[2:]...)
+	}
+
+	return st.TrieNode.Resolve(p)
+}
+
+// ResolveLink is a helper function that calls resolve and asserts the
+// output is a link
+func (st *EthStateTrie) ResolveLink(p []string) (*node.Link, []string, error) {
+	obj, rest, err := st.Resolve(p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lnk, ok := obj.(*node.Link)
+	if !ok {
+		return nil, nil, fmt.Errorf("was not a link")
+	}
+
+	return lnk, rest, nil
+}
@@ -116,6 +116,36 @@ func TestStateTrieNodeOddLeafParsing(t *testing.T) {
 	}
 }
 
+func TestStateTrieNodeMalformed(t *testing.T) {
+	hash := make([]byte, 32)
+	embedded := []interface{}{[]byte{0x20}, []byte{0x01}}
+	branch := make([]interface{}, 17)
+	for i := range branch {
+		branch[i] = []byte{}
+	}
+	branch[3] = embedded
+
+	testCases := []struct {
+		rawdata []byte
+		err     string
+	}{
+		{getRLP([]interface{}{[]byte{}, hash}), "invalid compact key"},
+		{getRLP([]interface{}{[]interface{}{}, hash}), "invalid compact key"},
+		{getRLP([]interface{}{[]byte{0x40}, hash}), "unknown hex prefix"},
+		{getRLP([]interface{}{[]byte{0x00, 0x12}, embedded}), "unsupported embedded trie node"},
+		{getRLP([]interface{}{[]byte{0x00, 0x12}, []byte{0x01, 0x02}}), "unrecognized object: [1 2]"},
+		{getRLP(branch), "unsupported embedded trie node"},
+		{getRLP([]interface{}{[]byte{0x20}}), "unknown trie node type"},
+	}
+
+	for _, tc := range testCases {
+		_, err := DecodeEthStateTrie(rawdataToCid(MEthStateTrie, tc.rawdata), tc.rawdata)
+		if err == nil || err.Error() != tc.err {
+			t.Fatalf("Expected error '%s'\r\ngot %v", tc.err, err)
+		}
+	}
+}
+
 /*
   Block INTERFACE
 */
@@ -212,6 +242,52 @@ func TestTraverseStateTrieWithResolve(t *testing.T) {
 	}
 }
 
+func TestTraverseStateTrieByAddress(t *testing.T) {
+	var err error
+
+	stMap := prepareStateTrieMap(t)
+	currentNode := stMap["z45oqTS97WG4WsMjquajJ8PB9Ubt3ks7rGmo14P5XWjnPL7LHDM"]
+
+	// Same account as above, the state trie hashes the address for us
+	traversePath := []string{
+		"accounts",
+		"0x5abfec25f74cd88437631a7731906932776356f9",
+		"balance",
+	}
+
+	var obj interface{}
+	for {
+		obj, traversePath, err = currentNode.Resolve(traversePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		link, ok := obj.(*node.Link)
+		if !ok {
+			break
+		}
+
+		currentNode = stMap[link.Cid.String()]
+		if currentNode == nil {
+			t.Fatal("state trie node not found in memory map")
+		}
+	}
+
+	if fmt.Sprintf("%v", obj) != "11901484239480000000000000" {
+		t.Fatal("Wrong value, expected a balance")
+	}
+
+	// bad cases
+	_, _, err = currentNode.Resolve([]string{"accounts"})
+	if err == nil || err.Error() != "missing account address" {
+		t.Fatal("Expected error 'missing account address'")
+	}
+
+	_, _, err = currentNode.Resolve([]string{"accounts", "0x5abfec25"})
+	if err == nil || err.Error() != "invalid account address 0x5abfec25" {
+		t.Fatal("Expected error 'invalid account address 0x5abfec25'")
+	}
+}
+
 func TestStateTrieResolveLinks(t *testing.T) {
 	fi, err := os.Open("test_data/eth-state-trie-rlp-eb2f5f")
 	checkError(err, t)
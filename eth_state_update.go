@@ -0,0 +1,268 @@
+package ipldeth
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	cid "github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+	mh "github.com/multiformats/go-multihash"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// AccountChange is a change to an account of the state. The fields left
+// to nil are not changed. Accounts not found in the state are created.
+type AccountChange struct {
+	Address common.Address
+
+	Nonce   *uint64
+	Balance *big.Int
+	Code    []byte
+
+	// Storage maps slots to their new values. A zero value
+	// removes the slot from the storage.
+	Storage map[common.Hash]common.Hash
+
+	// Delete removes the account from the state. The other
+	// fields are ignored.
+	Delete bool
+}
+
+// StateUpdate is the outcome of UpdateState. It holds the new state root
+// and the blocks created to get to it, the ones to add to the DAG.
+type StateUpdate struct {
+	Root *cid.Cid
+
+	StateNodes   []*EthStateTrie
+	StorageNodes []*EthStorageTrie
+	Accounts     []*EthAccountSnapshot
+	Codes        []*EthCode
+}
+
+/*
+  INPUT
+*/
+
+// UpdateState applies the given changes to the state under the given root
+// cid, and returns the new state root along with the nodes created for it.
+// Only the nodes on the path of the changed accounts and slots are loaded
+// from the NodeGetter and rewritten, the rest of the tries are left as they
+// are, and so are their blocks.
+func UpdateState(ctx context.Context, ng node.NodeGetter, root *cid.Cid,
+	changes []AccountChange) (*StateUpdate, error) {
+	rootHash, err := cidToHash(root)
+	if err != nil {
+		return nil, err
+	}
+
+	stateDB := newIpldTrieDB(ctx, ng, MEthStateTrie)
+	storageDB := newIpldTrieDB(ctx, ng, MEthStorageTrie)
+
+	st, err := trie.New(rootHash, stateDB)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &StateUpdate{}
+	for _, ch := range changes {
+		key := crypto.Keccak256(ch.Address[:])
+
+		if ch.Delete {
+			if err := st.TryDelete(key); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		account, err := getTrieAccount(st, key)
+		if err != nil {
+			return nil, fmt.Errorf("account %x: %v", ch.Address, err)
+		}
+
+		if ch.Nonce != nil {
+			account.Nonce = *ch.Nonce
+		}
+		if ch.Balance != nil {
+			account.Balance = ch.Balance
+		}
+		if ch.Code != nil {
+			account.CodeHash = crypto.Keccak256(ch.Code)
+			ec, err := DecodeEthCode(keccak256ToCid(RawBinary, account.CodeHash), ch.Code)
+			if err != nil {
+				return nil, err
+			}
+			out.Codes = append(out.Codes, ec)
+		}
+		if len(ch.Storage) != 0 {
+			account.Root, err = updateStorage(storageDB, account.Root, ch.Storage)
+			if err != nil {
+				return nil, fmt.Errorf("account %x: %v", ch.Address, err)
+			}
+		}
+
+		rawdata := getRLP(account)
+		if err := st.TryUpdate(key, rawdata); err != nil {
+			return nil, err
+		}
+		out.Accounts = append(out.Accounts, &EthAccountSnapshot{
+			EthAccount: account,
+			cid:        rawdataToCid(MEthAccountSnapshot, rawdata),
+			rawdata:    rawdata,
+		})
+	}
+
+	newRoot, err := st.CommitTo(stateDB)
+	if err != nil {
+		return nil, err
+	}
+	out.Root = commonHashToCid(MEthStateTrie, newRoot)
+
+	for _, rawdata := range stateDB.written {
+		stn, err := DecodeEthStateTrie(rawdataToCid(MEthStateTrie, rawdata), rawdata)
+		if err != nil {
+			return nil, err
+		}
+		out.StateNodes = append(out.StateNodes, stn)
+	}
+	for _, rawdata := range storageDB.written {
+		stn, err := DecodeEthStorageTrie(rawdataToCid(MEthStorageTrie, rawdata), rawdata)
+		if err != nil {
+			return nil, err
+		}
+		out.StorageNodes = append(out.StorageNodes, stn)
+	}
+
+	return out, nil
+}
+
+/*
+  AUXILIARS
+*/
+
+// getTrieAccount returns the account found in the state trie under the
+// given key, or a new empty account if there is none.
+func getTrieAccount(st *trie.Trie, key []byte) (*EthAccount, error) {
+	rawdata, err := st.TryGet(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rawdata) == 0 {
+		return &EthAccount{
+			Balance:  new(big.Int),
+			Root:     types.EmptyRootHash.Bytes(),
+			CodeHash: emptyCodeHash[:],
+		}, nil
+	}
+
+	var account EthAccount
+	if err := rlp.DecodeBytes(rawdata, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// updateStorage applies the given slot changes to the storage trie under
+// the given root, returning the new root.
+func updateStorage(db *ipldTrieDB, root []byte, changes map[common.Hash]common.Hash) ([]byte, error) {
+	sr, err := trie.New(common.BytesToHash(root), db)
+	if err != nil {
+		return nil, err
+	}
+
+	for slot, v := range changes {
+		key := crypto.Keccak256(slot[:])
+
+		// Zero values are not stored, as if they were deleted
+		value := new(big.Int).SetBytes(v[:])
+		if value.Sign() == 0 {
+			err = sr.TryDelete(key)
+		} else {
+			err = sr.TryUpdate(key, getRLP(value))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	newRoot, err := sr.CommitTo(db)
+	if err != nil {
+		return nil, err
+	}
+	return newRoot[:], nil
+}
+
+// cidToHash returns the keccak256 hash a cid is made of.
+func cidToHash(c *cid.Cid) (common.Hash, error) {
+	dmh, err := mh.Decode(c.Hash())
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if dmh.Code != mh.KECCAK_256 {
+		return common.Hash{}, fmt.Errorf("cid %s is not a keccak256 hash", c)
+	}
+
+	return common.BytesToHash(dmh.Digest), nil
+}
+
+// ipldTrieDB is a trie.Database reading the trie nodes from a NodeGetter,
+// by their hash and the codec of the trie. It keeps the nodes written to
+// it, the ones created by the changes to the trie, in the order they were.
+type ipldTrieDB struct {
+	ctx   context.Context
+	ng    node.NodeGetter
+	codec uint64
+
+	nodes   map[string][]byte
+	written [][]byte
+}
+
+// newIpldTrieDB returns a trie.Database over the given NodeGetter.
+func newIpldTrieDB(ctx context.Context, ng node.NodeGetter, codec uint64) *ipldTrieDB {
+	return &ipldTrieDB{
+		ctx:   ctx,
+		ng:    ng,
+		codec: codec,
+		nodes: make(map[string][]byte),
+	}
+}
+
+// Get returns the node of the given hash.
+func (db *ipldTrieDB) Get(key []byte) ([]byte, error) {
+	if rawdata, ok := db.nodes[string(key)]; ok {
+		return rawdata, nil
+	}
+
+	nd, err := db.ng.Get(db.ctx, keccak256ToCid(db.codec, key))
+	if err != nil {
+		return nil, err
+	}
+	return nd.RawData(), nil
+}
+
+// Has tells whether there is a node of the given hash.
+func (db *ipldTrieDB) Has(key []byte) (bool, error) {
+	_, err := db.Get(key)
+	if err == node.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Put keeps the given node, as written by the trie on commit.
+func (db *ipldTrieDB) Put(key, value []byte) error {
+	if _, ok := db.nodes[string(key)]; ok {
+		return nil
+	}
+
+	rawdata := common.CopyBytes(value)
+	db.nodes[string(key)] = rawdata
+	db.written = append(db.written, rawdata)
+	return nil
+}
@@ -0,0 +1,117 @@
+package ipldeth
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+/*
+  INPUT
+*/
+
+func TestUpdateStateBalance(t *testing.T) {
+	ng, root := prepareStateNodeGetter(t)
+
+	address := common.HexToAddress("0x5abfec25f74cd88437631a7731906932776356f9")
+	upd, err := UpdateState(context.Background(), ng, root, []AccountChange{
+		{Address: address, Balance: big.NewInt(1)},
+	})
+	checkError(err, t)
+
+	expected := keccak256ToCid(MEthStateTrie,
+		common.FromHex("0xf4fb09c9666e64bdf4a5def3e09970cd2397f6301ce527691b93aae81445808c"))
+	if upd.Root.String() != expected.String() {
+		t.Fatalf("Wrong state root\r\nexpected %s\r\ngot %s", expected, upd.Root)
+	}
+
+	// Only the path to the account is rewritten
+	if len(upd.StateNodes) != 4 {
+		t.Fatalf("Wrong number of state trie nodes\r\nexpected %d\r\ngot %d", 4, len(upd.StateNodes))
+	}
+	if len(upd.StorageNodes) != 0 || len(upd.Codes) != 0 {
+		t.Fatal("Expected no storage trie nodes nor code")
+	}
+	if len(upd.Accounts) != 1 || upd.Accounts[0].Balance.Int64() != 1 {
+		t.Fatal("Wrong account snapshot")
+	}
+
+	// The old state is still there
+	if _, err := GetProof(context.Background(), ng, root, address, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateStateNewContract(t *testing.T) {
+	ng, root := prepareStateNodeGetter(t)
+
+	address := common.HexToAddress("0x0000000000000000000000000000000000000932")
+	nonce := uint64(1)
+	code := common.FromHex("0x6080604052600080fd00")
+	upd, err := UpdateState(context.Background(), ng, root, []AccountChange{
+		{
+			Address: address,
+			Nonce:   &nonce,
+			Code:    code,
+			Storage: map[common.Hash]common.Hash{
+				common.Hash{}:                 common.BigToHash(big.NewInt(42)),
+				common.BytesToHash([]byte{1}): common.Hash{},
+			},
+		},
+	})
+	checkError(err, t)
+
+	if len(upd.Codes) != 1 || upd.Codes[0].Cid().String() != rawdataToCid(RawBinary, code).String() {
+		t.Fatal("Wrong code")
+	}
+	// A single slot makes a single leaf
+	if len(upd.StorageNodes) != 1 {
+		t.Fatalf("Wrong number of storage trie nodes\r\nexpected %d\r\ngot %d", 1, len(upd.StorageNodes))
+	}
+
+	// The new state has both the new contract, and the former account
+	for _, stn := range upd.StateNodes {
+		ng[stn.Cid().String()] = stn
+	}
+	for _, stn := range upd.StorageNodes {
+		ng[stn.Cid().String()] = stn
+	}
+
+	res, err := GetProof(context.Background(), ng, upd.Root, address, []string{"0x0"})
+	checkError(err, t)
+	if uint64(res.Nonce) != 1 || res.CodeHash != crypto.Keccak256Hash(code) {
+		t.Fatal("Wrong contract account")
+	}
+	if res.StorageProof[0].Value.ToInt().Int64() != 42 {
+		t.Fatal("Wrong storage value")
+	}
+
+	res, err = GetProof(context.Background(), ng, upd.Root,
+		common.HexToAddress("0x5abfec25f74cd88437631a7731906932776356f9"), nil)
+	checkError(err, t)
+	if res.Balance.ToInt().String() != "11901484239480000000000000" {
+		t.Fatal("Wrong balance of the former account")
+	}
+}
+
+func TestUpdateStateMissingNode(t *testing.T) {
+	ng, root := prepareStateNodeGetter(t)
+
+	// Remove the leaves, the one of the account among them
+	for k, n := range ng {
+		if n.(*EthStateTrie).nodeKind == "leaf" {
+			delete(ng, k)
+		}
+	}
+
+	address := common.HexToAddress("0x5abfec25f74cd88437631a7731906932776356f9")
+	_, err := UpdateState(context.Background(), ng, root, []AccountChange{
+		{Address: address, Balance: big.NewInt(1)},
+	})
+	if err == nil {
+		t.Fatal("Expected an error for a missing node")
+	}
+}
@@ -7,6 +7,9 @@ import (
 
 	cid "github.com/ipfs/go-cid"
 	node "github.com/ipfs/go-ipld-format"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
 // EthStorageTrie (eth-storage-trie, codec 0x98), represents
@@ -51,12 +54,16 @@ func DecodeEthStorageTrie(c *cid.Cid, b []byte) (*EthStorageTrie, error) {
 	return &EthStorageTrie{TrieNode: tn}, nil
 }
 
-// decodeEthStorageTrieLeaf parses a eth-tx-trie leaf
+// decodeEthStorageTrieLeaf parses a eth-storage-trie leaf
 // from decoded RLP elements
 func decodeEthStorageTrieLeaf(i []interface{}) ([]interface{}, error) {
+	sv, err := decodeEthStorageValue(i[1].([]byte))
+	if err != nil {
+		return nil, err
+	}
 	return []interface{}{
 		i[0].([]byte),
-		i[1].([]byte),
+		sv,
 	}, nil
 }
 
@@ -85,3 +92,72 @@ func (st *EthStorageTrie) Loggable() map[string]interface{} {
 		"type": "eth-storage-trie",
 	}
 }
+
+/*
+  Node INTERFACE
+*/
+
+// Resolve resolves a path through this node, stopping at any link boundary
+// and returning the object found as well as the remaining path to traverse.
+// Besides the nibbles of the trie, slots can be reached by their hex
+// position, as in "slot/0x0".
+func (st *EthStorageTrie) Resolve(p []string) (interface{}, []string, error) {
+	if len(p) != 0 && p[0] == "slot" {
+		if len(p) == 1 {
+			return nil, nil, fmt.Errorf("missing storage slot")
+		}
+
+		key, err := parseStorageSlot(p[1])
+		if err != nil {
+			return nil, nil, err
+		}
+		p = append(secureKeyPath(key[:]), p[2:]...)
+	}
+
+	return st.TrieNode.Resolve(p)
+}
+
+// ResolveLink is a helper function that calls resolve and asserts the
+// output is a link
+func (st *EthStorageTrie) ResolveLink(p []string) (*node.Link, []string, error) {
+	obj, rest, err := st.Resolve(p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lnk, ok := obj.(*node.Link)
+	if !ok {
+		return nil, nil, fmt.Errorf("was not a link")
+	}
+
+	return lnk, rest, nil
+}
+
+/*
+  EthStorageTrie functions
+*/
+
+// parseStorageSlot takes the hex position of a storage slot, with
+// or without leading zeros, and returns it as its 32 bytes key.
+func parseStorageSlot(s string) (common.Hash, error) {
+	if len(s) < 3 || len(s) > 66 || !hasHexPrefix(s) {
+		return common.Hash{}, fmt.Errorf("invalid storage slot %s", s)
+	}
+
+	// hexutil wants an even number of digits
+	h := s[2:]
+	if len(h)%2 == 1 {
+		h = "0" + h
+	}
+	b, err := hexutil.Decode("0x" + h)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("invalid storage slot %s", s)
+	}
+
+	return common.BytesToHash(b), nil
+}
+
+// hasHexPrefix tells whether the given string starts with 0x or 0X.
+func hasHexPrefix(s string) bool {
+	return len(s) >= 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X')
+}
@@ -2,10 +2,14 @@ package ipldeth
 
 import (
 	"fmt"
+	"math/big"
 	"os"
 	"testing"
 
 	cid "github.com/ipfs/go-cid"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 /*
@@ -58,7 +62,11 @@ func TestStateTrieNodeLeafParsing(t *testing.T) {
 		t.Fatal("Wrong key")
 	}
 
-	if fmt.Sprintf("%x", output.elements[1]) != "89056c31f304b2530000" {
+	sv, ok := output.elements[1].(*EthStorageValue)
+	if !ok {
+		t.Fatal("Wrong Type. Element should be a storage value")
+	}
+	if fmt.Sprintf("%x", sv.RawData()) != "89056c31f304b2530000" {
 		t.Fatal("Wrong Value")
 	}
 }
@@ -90,7 +98,7 @@ func TestStateTrieNodeBranchParsing(t *testing.T) {
 }
 
 /*
-  Block INTERFACE
+Block INTERFACE
 */
 func TestStorageTrieBlockElements(t *testing.T) {
 	fi, err := os.Open("test_data/eth-storage-trie-rlp-ffbcad")
@@ -138,3 +146,55 @@ func TestStorageTrieLoggable(t *testing.T) {
 		t.Fatal("Wrong Loggable 'type' value")
 	}
 }
+
+/*
+  TRIE NODE (Through EthStorageTrie)
+  Node INTERFACE
+*/
+
+func TestStorageTrieResolveSlot(t *testing.T) {
+	// A storage trie holding 42 in slot 0 is a single leaf
+	lt := newLocalTrie()
+	val, err := rlp.EncodeToBytes(big.NewInt(42))
+	checkError(err, t)
+	lt.trie.Update(crypto.Keccak256(make([]byte, 32)), val)
+	lt.getKeys()
+
+	raw, err := lt.db.Get(lt.rootHash())
+	checkError(err, t)
+
+	stNode, err := DecodeEthStorageTrie(keccak256ToCid(MEthStorageTrie, lt.rootHash()), raw)
+	checkError(err, t)
+
+	for _, slot := range []string{"0x0", "0x00", "0x" + fmt.Sprintf("%064x", 0)} {
+		obj, rest, err := stNode.Resolve([]string{"slot", slot, "int"})
+		checkError(err, t)
+		if rest != nil {
+			t.Fatal("Expected rest to be nil")
+		}
+		if fmt.Sprintf("%v", obj) != "42" {
+			t.Fatalf("Wrong value for slot %s", slot)
+		}
+	}
+
+	// bad cases
+	_, _, err = stNode.Resolve([]string{"slot", "0x1"})
+	if err == nil || err.Error() != "no such link in this extension" {
+		t.Fatal("Expected error 'no such link in this extension'")
+	}
+
+	_, _, err = stNode.Resolve([]string{"slot", "12"})
+	if err == nil || err.Error() != "invalid storage slot 12" {
+		t.Fatal("Expected error 'invalid storage slot 12'")
+	}
+
+	_, _, err = stNode.Resolve([]string{"slot", "0x" + fmt.Sprintf("%066x", 0)})
+	if err == nil {
+		t.Fatal("Expected an error for a slot over 32 bytes")
+	}
+
+	_, _, err = stNode.ResolveLink([]string{"slot", "0x0"})
+	if err == nil || err.Error() != "was not a link" {
+		t.Fatal("Expected error 'was not a link'")
+	}
+}
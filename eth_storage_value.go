@@ -0,0 +1,108 @@
+package ipldeth
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	node "github.com/ipfs/go-ipld-format"
+
+	common "github.com/ethereum/go-ethereum/common"
+	rlp "github.com/ethereum/go-ethereum/rlp"
+)
+
+// EthStorageValue represents the value of a storage slot, as found
+// in the leaves of the eth-storage-trie. It is not an IPLD block
+// on its own, as it is embedded in the leaf holding it.
+type EthStorageValue struct {
+	Value *big.Int
+
+	rawdata []byte
+}
+
+// Static (compile time) check that EthStorageValue satisfies the node.Resolver interface.
+var _ node.Resolver = (*EthStorageValue)(nil)
+
+/*
+  INPUT
+*/
+
+// decodeEthStorageValue takes the RLP encoded value of a storage slot,
+// a uint256 with its leading zeros trimmed, and returns it
+// as an EthStorageValue.
+func decodeEthStorageValue(b []byte) (*EthStorageValue, error) {
+	var v *big.Int
+	err := rlp.DecodeBytes(b, &v)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.BitLen() > 256 {
+		return nil, fmt.Errorf("storage value exceeds 32 bytes")
+	}
+
+	return &EthStorageValue{
+		Value:   v,
+		rawdata: b,
+	}, nil
+}
+
+/*
+  Resolver INTERFACE
+*/
+
+// Resolve resolves a path through this value. The empty path gives
+// the 32 bytes of the slot, as eth_getStorageAt does.
+func (sv *EthStorageValue) Resolve(p []string) (interface{}, []string, error) {
+	if len(p) == 0 {
+		return sv.hex(), nil, nil
+	}
+
+	if len(p) > 1 {
+		return nil, nil, fmt.Errorf("unexpected path elements past %s", p[0])
+	}
+
+	switch p[0] {
+	case "int":
+		return sv.Value, nil, nil
+	case "rlp":
+		return fmt.Sprintf("0x%x", sv.rawdata), nil, nil
+	case "value":
+		return sv.hex(), nil, nil
+	default:
+		return nil, nil, fmt.Errorf("no such link")
+	}
+}
+
+// Tree lists all paths within the object under 'path', and up to the given depth.
+// To list the entire object (similar to `find .`) pass "" and -1
+func (sv *EthStorageValue) Tree(p string, depth int) []string {
+	if p != "" || depth == 0 {
+		return nil
+	}
+	return []string{"int", "rlp", "value"}
+}
+
+/*
+  EthStorageValue functions
+*/
+
+// RawData returns the RLP encode of the storage value.
+func (sv *EthStorageValue) RawData() []byte {
+	return sv.rawdata
+}
+
+// MarshalJSON processes the storage value into readable JSON format.
+func (sv *EthStorageValue) MarshalJSON() ([]byte, error) {
+	out := map[string]interface{}{
+		"int":   sv.Value,
+		"rlp":   fmt.Sprintf("0x%x", sv.rawdata),
+		"value": sv.hex(),
+	}
+	return json.Marshal(out)
+}
+
+// hex returns the slot value left-padded to 32 bytes.
+func (sv *EthStorageValue) hex() string {
+	return common.BigToHash(sv.Value).Hex()
+}
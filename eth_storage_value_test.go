@@ -0,0 +1,153 @@
+package ipldeth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+)
+
+/*
+  INPUT
+*/
+
+func TestDecodeStorageValue(t *testing.T) {
+	sv := prepareEthStorageValue(t)
+
+	if sv.Value.String() != "100030000000000000000" {
+		t.Fatal("Wrong Value")
+	}
+	if fmt.Sprintf("%x", sv.RawData()) != "89056c31f304b2530000" {
+		t.Fatal("Wrong rawdata")
+	}
+}
+
+func TestDecodeStorageValueTooLong(t *testing.T) {
+	// 33 bytes
+	b := append([]byte{0xa1, 0x01}, make([]byte, 32)...)
+
+	_, err := decodeEthStorageValue(b)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if err.Error() != "storage value exceeds 32 bytes" {
+		t.Fatal("Wrong error")
+	}
+}
+
+/*
+  Resolver INTERFACE
+*/
+
+func TestEthStorageValueResolve(t *testing.T) {
+	sv := prepareEthStorageValue(t)
+
+	// The empty path gives away the slot as eth_getStorageAt
+	obj, rest, err := sv.Resolve([]string{})
+	checkError(err, t)
+	if obj.(string) != "0x0000000000000000000000000000000000000000000000056c31f304b2530000" {
+		t.Fatal("Wrong slot value")
+	}
+	if rest != nil {
+		t.Fatal("rest should be nil")
+	}
+
+	testCases := map[string]string{
+		"int":   "100030000000000000000",
+		"rlp":   "0x89056c31f304b2530000",
+		"value": "0x0000000000000000000000000000000000000000000000056c31f304b2530000",
+	}
+	for field, value := range testCases {
+		obj, _, err := sv.Resolve([]string{field})
+		checkError(err, t)
+
+		if fmt.Sprintf("%v", obj) != value {
+			t.Fatalf("Wrong %v", field)
+		}
+	}
+
+	_, _, err = sv.Resolve([]string{"value", "extra"})
+	if err == nil || err.Error() != "unexpected path elements past value" {
+		t.Fatal("Wrong error")
+	}
+	_, _, err = sv.Resolve([]string{"slot"})
+	if err == nil || err.Error() != "no such link" {
+		t.Fatal("Wrong error")
+	}
+
+	if len(sv.Tree("", 1)) != 3 {
+		t.Fatal("Wrong tree")
+	}
+}
+
+func TestEthStorageValueThroughLeaf(t *testing.T) {
+	fi, err := os.Open("test_data/eth-storage-trie-rlp-ffbcad")
+	checkError(err, t)
+
+	output, err := FromStorageTrieRLP(fi)
+	checkError(err, t)
+
+	var p []string
+	for _, n := range output.elements[0].([]byte) {
+		p = append(p, fmt.Sprintf("%x", n))
+	}
+
+	obj, rest, err := output.Resolve(p)
+	checkError(err, t)
+	if obj.(string) != "0x0000000000000000000000000000000000000000000000056c31f304b2530000" {
+		t.Fatal("Wrong slot value")
+	}
+	if len(rest) != 0 {
+		t.Fatal("Wrong rest of the path returned")
+	}
+
+	obj, _, err = output.Resolve(append(p, "int"))
+	checkError(err, t)
+	if fmt.Sprintf("%v", obj) != "100030000000000000000" {
+		t.Fatal("Wrong slot int value")
+	}
+}
+
+func TestEthStorageValueJSONMarshal(t *testing.T) {
+	fi, err := os.Open("test_data/eth-storage-trie-rlp-ffbcad")
+	checkError(err, t)
+
+	output, err := FromStorageTrieRLP(fi)
+	checkError(err, t)
+
+	// The leaf shows the value, rather than its RLP
+	jsonOutput, err := output.MarshalJSON()
+	checkError(err, t)
+
+	var data map[string]interface{}
+	err = json.Unmarshal(jsonOutput, &data)
+	checkError(err, t)
+
+	var found bool
+	for k, v := range data {
+		if k == "type" {
+			continue
+		}
+		sv := v.(map[string]interface{})
+		if sv["value"] != "0x0000000000000000000000000000000000000000000000056c31f304b2530000" {
+			t.Fatal("Wrong value")
+		}
+		if sv["rlp"] != "0x89056c31f304b2530000" {
+			t.Fatal("Wrong rlp")
+		}
+		found = true
+	}
+	if !found {
+		t.Fatal("Leaf value not found")
+	}
+}
+
+/*
+  AUXILIARS
+*/
+
+func prepareEthStorageValue(t *testing.T) *EthStorageValue {
+	sv, err := decodeEthStorageValue([]byte{0x89, 0x05, 0x6c, 0x31, 0xf3, 0x04, 0xb2, 0x53, 0x00, 0x00})
+	checkError(err, t)
+	return sv
+}
@@ -4,23 +4,60 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"math/big"
+	"sort"
 
 	cid "github.com/ipfs/go-cid"
 	node "github.com/ipfs/go-ipld-format"
 
+	common "github.com/ethereum/go-ethereum/common"
 	hexutil "github.com/ethereum/go-ethereum/common/hexutil"
 	types "github.com/ethereum/go-ethereum/core/types"
+	crypto "github.com/ethereum/go-ethereum/crypto"
 	rlp "github.com/ethereum/go-ethereum/rlp"
 )
 
 // EthTx (eth-tx codec 0x93) represents an ethereum transaction
 type EthTx struct {
+	// Transaction is the legacy transaction, nil for EIP-2718 typed ones.
+	// The accessors of EthTx work for both kinds, use them instead of
+	// the ones of the embedded types.Transaction.
 	*types.Transaction
 
+	// typed holds the fields of an EIP-2718 typed transaction, nil
+	// for legacy ones.
+	typed *typedTx
+
 	cid     *cid.Cid
 	rawdata []byte
 }
 
+// EIP-2718 transaction types.
+const (
+	LegacyTxType     = 0x00
+	AccessListTxType = 0x01
+	DynamicFeeTxType = 0x02
+	BlobTxType       = 0x03
+	SetCodeTxType    = 0x04
+)
+
+// AccessTuple is the element type of an EIP-2930 access list.
+type AccessTuple struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []common.Hash  `json:"storageKeys"`
+}
+
+// SetCodeAuthorization is the element type of an EIP-7702 authorization
+// list, the delegation of an account to the code of Address, signed by
+// the account. V is the y-parity of the signature.
+type SetCodeAuthorization struct {
+	ChainID *big.Int
+	Address common.Address
+	Nonce   uint64
+	V       uint8
+	R, S    *big.Int
+}
+
 // Static (compile time) check that EthTx satisfies the node.Node interface.
 var _ node.Node = (*EthTx)(nil)
 
@@ -44,26 +81,79 @@ func NewTx(t *types.Transaction) *EthTx {
 	}
 }
 
+// txFromJSON takes a transaction as given by the JSON API of an ethereum
+// client and returns it as an EthTx node. Typed transactions are encoded
+// back into their EIP-2718 envelope.
+func txFromJSON(input []byte) (*EthTx, error) {
+	var dec txJSON
+	err := json.Unmarshal(input, &dec)
+	if err != nil {
+		return nil, err
+	}
+
+	if dec.Type == nil || *dec.Type == LegacyTxType {
+		var t types.Transaction
+		err = t.UnmarshalJSON(input)
+		if err != nil {
+			return nil, err
+		}
+		return NewTx(&t), nil
+	}
+
+	tt, err := dec.typedTx()
+	if err != nil {
+		return nil, err
+	}
+	rawdata, err := tt.encode()
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeEthTx(rawdataToCid(MEthTx, rawdata), rawdata)
+}
+
 /*
  OUTPUT
 */
 
 // DecodeEthTx takes a cid and its raw binary data
 // from IPFS and returns an EthTx object for further processing.
+// The raw binary data may be either a legacy RLP transaction or
+// an EIP-2718 typed transaction envelope.
 func DecodeEthTx(c *cid.Cid, b []byte) (*EthTx, error) {
-	var t types.Transaction
-	err := rlp.DecodeBytes(b, &t)
+	t, typed, err := decodeTx(b)
 	if err != nil {
 		return nil, err
 	}
 
 	return &EthTx{
-		Transaction: &t,
+		Transaction: t,
+		typed:       typed,
 		cid:         c,
 		rawdata:     b,
 	}, nil
 }
 
+// decodeTx parses the consensus encoding of a transaction. Typed
+// transactions are prefixed with their type, always below 0x7f,
+// while legacy ones are RLP lists, starting at 0xc0.
+func decodeTx(b []byte) (*types.Transaction, *typedTx, error) {
+	if len(b) > 0 && b[0] <= 0x7f {
+		typed, err := decodeTypedTx(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, typed, nil
+	}
+
+	var t types.Transaction
+	err := rlp.DecodeBytes(b, &t)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &t, nil, nil
+}
+
 /*
   Block INTERFACE
 */
@@ -109,8 +199,6 @@ func (t *EthTx) Resolve(p []string) (interface{}, []string, error) {
 
 	case "gas":
 		return t.Gas(), nil, nil
-	case "gasPrice":
-		return t.GasPrice(), nil, nil
 	case "input":
 		return fmt.Sprintf("%x", t.Data()), nil, nil
 	case "nonce":
@@ -123,14 +211,20 @@ func (t *EthTx) Resolve(p []string) (interface{}, []string, error) {
 		return hexutil.EncodeBig(s), nil, nil
 	case "toAddress":
 		return t.To(), nil, nil
+	case "type":
+		return t.Type(), nil, nil
 	case "v":
 		v, _, _ := t.RawSignatureValues()
 		return hexutil.EncodeBig(v), nil, nil
 	case "value":
 		return hexutil.EncodeBig(t.Value()), nil, nil
-	default:
-		return nil, nil, fmt.Errorf("no such link")
 	}
+
+	if v, ok := t.typeFields()[p[0]]; ok {
+		return v, nil, nil
+	}
+
+	return nil, nil, fmt.Errorf("no such link")
 }
 
 // Tree lists all paths within the object under 'path', and up to the given depth.
@@ -139,7 +233,14 @@ func (t *EthTx) Tree(p string, depth int) []string {
 	if p != "" || depth == 0 {
 		return nil
 	}
-	return []string{"gas", "gasPrice", "input", "nonce", "r", "s", "toAddress", "v", "value"}
+
+	out := []string{"gas", "input", "nonce", "r", "s", "toAddress", "type", "v", "value"}
+	for k := range t.typeFields() {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+
+	return out
 }
 
 // ResolveLink is a helper function that calls resolve and asserts the
@@ -174,7 +275,7 @@ func (t *EthTx) Stat() (*node.NodeStat, error) {
 
 // Size will go away. It is here to comply with the interface.
 func (t *EthTx) Size() (uint64, error) {
-	return uint64(t.Transaction.Size().Int64()), nil
+	return uint64(len(t.rawdata)), nil
 }
 
 /*
@@ -187,14 +288,466 @@ func (t *EthTx) MarshalJSON() ([]byte, error) {
 
 	out := map[string]interface{}{
 		"gas":       t.Gas(),
-		"gasPrice":  t.GasPrice(),
 		"input":     fmt.Sprintf("%x", t.Data()),
 		"nonce":     t.Nonce(),
 		"r":         hexutil.EncodeBig(r),
 		"s":         hexutil.EncodeBig(s),
 		"toAddress": t.To(),
+		"type":      t.Type(),
 		"v":         hexutil.EncodeBig(v),
 		"value":     hexutil.EncodeBig(t.Value()),
 	}
+	for k, val := range t.typeFields() {
+		out[k] = val
+	}
 	return json.Marshal(out)
 }
+
+// Type returns the EIP-2718 type of the transaction,
+// LegacyTxType for the ones predating typed envelopes.
+func (t *EthTx) Type() uint8 {
+	if t.typed == nil {
+		return LegacyTxType
+	}
+	return t.typed.Type
+}
+
+// RawSignatureValues returns the V, R, S signature values of the transaction.
+// For typed transactions, V is the y-parity of the signature.
+func (t *EthTx) RawSignatureValues() (*big.Int, *big.Int, *big.Int) {
+	if t.typed == nil {
+		return t.Transaction.RawSignatureValues()
+	}
+	return t.typed.V, t.typed.R, t.typed.S
+}
+
+// Hash returns the transaction hash, this is, the keccak256
+// of its consensus encoding.
+func (t *EthTx) Hash() common.Hash {
+	return crypto.Keccak256Hash(t.rawdata)
+}
+
+// Nonce returns the sender account nonce of the transaction.
+func (t *EthTx) Nonce() uint64 {
+	if t.typed == nil {
+		return t.Transaction.Nonce()
+	}
+	return t.typed.Nonce
+}
+
+// Gas returns the gas limit of the transaction.
+func (t *EthTx) Gas() *big.Int {
+	if t.typed == nil {
+		return t.Transaction.Gas()
+	}
+	return t.typed.Gas
+}
+
+// GasPrice returns the gas price of the transaction. Transactions
+// with dynamic fees have none, their fee cap is returned instead.
+func (t *EthTx) GasPrice() *big.Int {
+	if t.typed == nil {
+		return t.Transaction.GasPrice()
+	}
+	if t.typed.Type == AccessListTxType {
+		return t.typed.GasPrice
+	}
+	return t.typed.GasFeeCap
+}
+
+// Value returns the amount of wei transferred by the transaction.
+func (t *EthTx) Value() *big.Int {
+	if t.typed == nil {
+		return t.Transaction.Value()
+	}
+	return t.typed.Value
+}
+
+// To returns the recipient of the transaction,
+// nil for a contract creation.
+func (t *EthTx) To() *common.Address {
+	if t.typed == nil {
+		return t.Transaction.To()
+	}
+	return t.typed.To
+}
+
+// Data returns the input data of the transaction.
+func (t *EthTx) Data() []byte {
+	if t.typed == nil {
+		return t.Transaction.Data()
+	}
+	return t.typed.Data
+}
+
+// ChainId returns the chain the transaction was signed for, zero
+// for legacy transactions predating EIP-155.
+func (t *EthTx) ChainId() *big.Int {
+	if t.typed == nil {
+		return t.Transaction.ChainId()
+	}
+	return t.typed.ChainID
+}
+
+// Protected tells whether the transaction is replay protected,
+// which typed transactions always are.
+func (t *EthTx) Protected() bool {
+	if t.typed == nil {
+		return t.Transaction.Protected()
+	}
+	return true
+}
+
+// typeFields returns the readable fields of the transaction
+// that depend on its type.
+func (t *EthTx) typeFields() map[string]interface{} {
+	if t.typed == nil {
+		return map[string]interface{}{
+			"gasPrice": t.GasPrice(),
+		}
+	}
+
+	out := map[string]interface{}{
+		"accessList": t.typed.AccessList,
+		"chainId":    hexutil.EncodeBig(t.typed.ChainID),
+	}
+
+	switch t.typed.Type {
+	case AccessListTxType:
+		out["gasPrice"] = t.typed.GasPrice
+	case BlobTxType:
+		out["maxFeePerBlobGas"] = t.typed.BlobFeeCap
+		out["blobVersionedHashes"] = t.typed.BlobHashes
+		fallthrough
+	case DynamicFeeTxType, SetCodeTxType:
+		out["maxFeePerGas"] = t.typed.GasFeeCap
+		out["maxPriorityFeePerGas"] = t.typed.GasTipCap
+	}
+	if t.typed.Type == SetCodeTxType {
+		out["authorizationList"] = t.typed.AuthList
+	}
+
+	return out
+}
+
+/*
+  EIP-2718 typed transactions
+*/
+
+// typedTx gathers the fields of all the supported typed transactions.
+// Which of them are in use, and their RLP order, depend on Type.
+type typedTx struct {
+	Type       uint8
+	ChainID    *big.Int
+	Nonce      uint64
+	GasPrice   *big.Int // access list transactions only
+	GasTipCap  *big.Int // a.k.a. maxPriorityFeePerGas
+	GasFeeCap  *big.Int // a.k.a. maxFeePerGas
+	Gas        *big.Int
+	To         *common.Address
+	Value      *big.Int
+	Data       []byte
+	AccessList []AccessTuple
+	BlobFeeCap *big.Int // a.k.a. maxFeePerBlobGas
+	BlobHashes []common.Hash
+	AuthList   []SetCodeAuthorization // a.k.a. authorizationList
+	V, R, S    *big.Int
+}
+
+// accessListTxRLP is the EIP-2930 transaction payload (type 0x01).
+type accessListTxRLP struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasPrice   *big.Int
+	Gas        *big.Int
+	To         *common.Address `rlp:"nil"`
+	Value      *big.Int
+	Data       []byte
+	AccessList []AccessTuple
+	V, R, S    *big.Int
+}
+
+// dynamicFeeTxRLP is the EIP-1559 transaction payload (type 0x02).
+type dynamicFeeTxRLP struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasTipCap  *big.Int
+	GasFeeCap  *big.Int
+	Gas        *big.Int
+	To         *common.Address `rlp:"nil"`
+	Value      *big.Int
+	Data       []byte
+	AccessList []AccessTuple
+	V, R, S    *big.Int
+}
+
+// blobTxRLP is the EIP-4844 transaction payload (type 0x03),
+// which can't be a contract creation.
+type blobTxRLP struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasTipCap  *big.Int
+	GasFeeCap  *big.Int
+	Gas        *big.Int
+	To         common.Address
+	Value      *big.Int
+	Data       []byte
+	AccessList []AccessTuple
+	BlobFeeCap *big.Int
+	BlobHashes []common.Hash
+	V, R, S    *big.Int
+}
+
+// setCodeTxRLP is the EIP-7702 transaction payload (type 0x04),
+// which can't be a contract creation either.
+type setCodeTxRLP struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasTipCap  *big.Int
+	GasFeeCap  *big.Int
+	Gas        *big.Int
+	To         common.Address
+	Value      *big.Int
+	Data       []byte
+	AccessList []AccessTuple
+	AuthList   []SetCodeAuthorization
+	V, R, S    *big.Int
+}
+
+// decodeTypedTx parses an EIP-2718 envelope, this is,
+// the transaction type followed by its RLP payload.
+func decodeTypedTx(b []byte) (*typedTx, error) {
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty typed transaction")
+	}
+
+	tt := &typedTx{Type: b[0]}
+	payload := b[1:]
+
+	switch tt.Type {
+	case AccessListTxType:
+		var dec accessListTxRLP
+		if err := rlp.DecodeBytes(payload, &dec); err != nil {
+			return nil, err
+		}
+		tt.ChainID, tt.Nonce, tt.GasPrice, tt.Gas = dec.ChainID, dec.Nonce, dec.GasPrice, dec.Gas
+		tt.To, tt.Value, tt.Data, tt.AccessList = dec.To, dec.Value, dec.Data, dec.AccessList
+		tt.V, tt.R, tt.S = dec.V, dec.R, dec.S
+	case DynamicFeeTxType:
+		var dec dynamicFeeTxRLP
+		if err := rlp.DecodeBytes(payload, &dec); err != nil {
+			return nil, err
+		}
+		tt.ChainID, tt.Nonce, tt.GasTipCap, tt.GasFeeCap, tt.Gas = dec.ChainID, dec.Nonce, dec.GasTipCap, dec.GasFeeCap, dec.Gas
+		tt.To, tt.Value, tt.Data, tt.AccessList = dec.To, dec.Value, dec.Data, dec.AccessList
+		tt.V, tt.R, tt.S = dec.V, dec.R, dec.S
+	case BlobTxType:
+		var dec blobTxRLP
+		if err := rlp.DecodeBytes(payload, &dec); err != nil {
+			return nil, err
+		}
+		tt.ChainID, tt.Nonce, tt.GasTipCap, tt.GasFeeCap, tt.Gas = dec.ChainID, dec.Nonce, dec.GasTipCap, dec.GasFeeCap, dec.Gas
+		tt.To, tt.Value, tt.Data, tt.AccessList = &dec.To, dec.Value, dec.Data, dec.AccessList
+		tt.BlobFeeCap, tt.BlobHashes = dec.BlobFeeCap, dec.BlobHashes
+		tt.V, tt.R, tt.S = dec.V, dec.R, dec.S
+	case SetCodeTxType:
+		var dec setCodeTxRLP
+		if err := rlp.DecodeBytes(payload, &dec); err != nil {
+			return nil, err
+		}
+		tt.ChainID, tt.Nonce, tt.GasTipCap, tt.GasFeeCap, tt.Gas = dec.ChainID, dec.Nonce, dec.GasTipCap, dec.GasFeeCap, dec.Gas
+		tt.To, tt.Value, tt.Data, tt.AccessList = &dec.To, dec.Value, dec.Data, dec.AccessList
+		tt.AuthList = dec.AuthList
+		tt.V, tt.R, tt.S = dec.V, dec.R, dec.S
+	default:
+		return nil, fmt.Errorf("unsupported transaction type 0x%02x", tt.Type)
+	}
+
+	return tt, nil
+}
+
+// encode returns the EIP-2718 envelope of the transaction.
+func (tt *typedTx) encode() ([]byte, error) {
+	var payload interface{}
+
+	switch tt.Type {
+	case AccessListTxType:
+		payload = &accessListTxRLP{
+			ChainID: tt.ChainID, Nonce: tt.Nonce, GasPrice: tt.GasPrice, Gas: tt.Gas,
+			To: tt.To, Value: tt.Value, Data: tt.Data, AccessList: tt.AccessList,
+			V: tt.V, R: tt.R, S: tt.S,
+		}
+	case DynamicFeeTxType:
+		payload = &dynamicFeeTxRLP{
+			ChainID: tt.ChainID, Nonce: tt.Nonce, GasTipCap: tt.GasTipCap, GasFeeCap: tt.GasFeeCap, Gas: tt.Gas,
+			To: tt.To, Value: tt.Value, Data: tt.Data, AccessList: tt.AccessList,
+			V: tt.V, R: tt.R, S: tt.S,
+		}
+	case BlobTxType:
+		if tt.To == nil {
+			return nil, fmt.Errorf("blob transactions can't create contracts")
+		}
+		payload = &blobTxRLP{
+			ChainID: tt.ChainID, Nonce: tt.Nonce, GasTipCap: tt.GasTipCap, GasFeeCap: tt.GasFeeCap, Gas: tt.Gas,
+			To: *tt.To, Value: tt.Value, Data: tt.Data, AccessList: tt.AccessList,
+			BlobFeeCap: tt.BlobFeeCap, BlobHashes: tt.BlobHashes,
+			V: tt.V, R: tt.R, S: tt.S,
+		}
+	case SetCodeTxType:
+		if tt.To == nil {
+			return nil, fmt.Errorf("set code transactions can't create contracts")
+		}
+		payload = &setCodeTxRLP{
+			ChainID: tt.ChainID, Nonce: tt.Nonce, GasTipCap: tt.GasTipCap, GasFeeCap: tt.GasFeeCap, Gas: tt.Gas,
+			To: *tt.To, Value: tt.Value, Data: tt.Data, AccessList: tt.AccessList, AuthList: tt.AuthList,
+			V: tt.V, R: tt.R, S: tt.S,
+		}
+	default:
+		return nil, fmt.Errorf("unsupported transaction type 0x%02x", tt.Type)
+	}
+
+	b, err := rlp.EncodeToBytes(payload)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{tt.Type}, b...), nil
+}
+
+// txJSON is the JSON representation of a typed transaction,
+// as given away by the ethereum clients.
+type txJSON struct {
+	Type                 *hexutil.Uint64        `json:"type"`
+	ChainID              *hexutil.Big           `json:"chainId"`
+	Nonce                *hexutil.Uint64        `json:"nonce"`
+	GasPrice             *hexutil.Big           `json:"gasPrice"`
+	MaxPriorityFeePerGas *hexutil.Big           `json:"maxPriorityFeePerGas"`
+	MaxFeePerGas         *hexutil.Big           `json:"maxFeePerGas"`
+	Gas                  *hexutil.Big           `json:"gas"`
+	To                   *common.Address        `json:"to"`
+	Value                *hexutil.Big           `json:"value"`
+	Input                hexutil.Bytes          `json:"input"`
+	AccessList           []AccessTuple          `json:"accessList"`
+	MaxFeePerBlobGas     *hexutil.Big           `json:"maxFeePerBlobGas"`
+	BlobVersionedHashes  []common.Hash          `json:"blobVersionedHashes"`
+	AuthorizationList    []SetCodeAuthorization `json:"authorizationList"`
+	V                    *hexutil.Big           `json:"v"`
+	R                    *hexutil.Big           `json:"r"`
+	S                    *hexutil.Big           `json:"s"`
+}
+
+// setCodeAuthorizationJSON is the JSON representation of an
+// EIP-7702 authorization, as given away by the ethereum clients.
+type setCodeAuthorizationJSON struct {
+	ChainID *hexutil.Big    `json:"chainId"`
+	Address common.Address  `json:"address"`
+	Nonce   *hexutil.Uint64 `json:"nonce"`
+	V       *hexutil.Uint64 `json:"yParity"`
+	R       *hexutil.Big    `json:"r"`
+	S       *hexutil.Big    `json:"s"`
+}
+
+// MarshalJSON encodes the authorization as the ethereum clients do.
+func (a SetCodeAuthorization) MarshalJSON() ([]byte, error) {
+	nonce, v := hexutil.Uint64(a.Nonce), hexutil.Uint64(a.V)
+	return json.Marshal(&setCodeAuthorizationJSON{
+		ChainID: (*hexutil.Big)(a.ChainID),
+		Address: a.Address,
+		Nonce:   &nonce,
+		V:       &v,
+		R:       (*hexutil.Big)(a.R),
+		S:       (*hexutil.Big)(a.S),
+	})
+}
+
+// UnmarshalJSON decodes the authorization as given by the ethereum clients.
+func (a *SetCodeAuthorization) UnmarshalJSON(input []byte) error {
+	var dec setCodeAuthorizationJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if dec.ChainID == nil || dec.Nonce == nil || dec.V == nil || dec.R == nil || dec.S == nil {
+		return fmt.Errorf("missing required fields for authorization")
+	}
+	if *dec.V > 1 {
+		return fmt.Errorf("invalid authorization yParity %d", uint64(*dec.V))
+	}
+
+	a.ChainID, a.Address, a.Nonce = dec.ChainID.ToInt(), dec.Address, uint64(*dec.Nonce)
+	a.V, a.R, a.S = uint8(*dec.V), dec.R.ToInt(), dec.S.ToInt()
+	return nil
+}
+
+// typedTx checks the presence of the fields required by the
+// transaction type, returning the resulting typedTx.
+func (dec *txJSON) typedTx() (*typedTx, error) {
+	present := map[string]bool{
+		"chainId": dec.ChainID != nil,
+		"nonce":   dec.Nonce != nil,
+		"gas":     dec.Gas != nil,
+		"value":   dec.Value != nil,
+		"v":       dec.V != nil,
+		"r":       dec.R != nil,
+		"s":       dec.S != nil,
+	}
+	switch *dec.Type {
+	case AccessListTxType:
+		present["gasPrice"] = dec.GasPrice != nil
+	case SetCodeTxType:
+		present["authorizationList"] = dec.AuthorizationList != nil
+		present["to"] = dec.To != nil
+		present["maxFeePerGas"] = dec.MaxFeePerGas != nil
+		present["maxPriorityFeePerGas"] = dec.MaxPriorityFeePerGas != nil
+	case BlobTxType:
+		present["maxFeePerBlobGas"] = dec.MaxFeePerBlobGas != nil
+		present["to"] = dec.To != nil
+		fallthrough
+	case DynamicFeeTxType:
+		present["maxFeePerGas"] = dec.MaxFeePerGas != nil
+		present["maxPriorityFeePerGas"] = dec.MaxPriorityFeePerGas != nil
+	default:
+		return nil, fmt.Errorf("unsupported transaction type 0x%02x", uint64(*dec.Type))
+	}
+
+	var missing []string
+	for k, ok := range present {
+		if !ok {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) != 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing required fields for typed transaction: %v", missing)
+	}
+
+	tt := &typedTx{
+		Type:       uint8(*dec.Type),
+		ChainID:    dec.ChainID.ToInt(),
+		Nonce:      uint64(*dec.Nonce),
+		Gas:        dec.Gas.ToInt(),
+		To:         dec.To,
+		Value:      dec.Value.ToInt(),
+		Data:       dec.Input,
+		AccessList: dec.AccessList,
+		BlobHashes: dec.BlobVersionedHashes,
+		AuthList:   dec.AuthorizationList,
+		V:          dec.V.ToInt(),
+		R:          dec.R.ToInt(),
+		S:          dec.S.ToInt(),
+	}
+	if tt.AccessList == nil {
+		tt.AccessList = []AccessTuple{}
+	}
+
+	switch tt.Type {
+	case AccessListTxType:
+		tt.GasPrice = dec.GasPrice.ToInt()
+	case BlobTxType:
+		tt.BlobFeeCap = dec.MaxFeePerBlobGas.ToInt()
+		fallthrough
+	case DynamicFeeTxType, SetCodeTxType:
+		tt.GasFeeCap = dec.MaxFeePerGas.ToInt()
+		tt.GasTipCap = dec.MaxPriorityFeePerGas.ToInt()
+	}
+
+	return tt, nil
+}
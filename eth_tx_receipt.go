@@ -0,0 +1,297 @@
+package ipldeth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"sync"
+
+	cid "github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+
+	types "github.com/ethereum/go-ethereum/core/types"
+	rlp "github.com/ethereum/go-ethereum/rlp"
+)
+
+// EthTxReceipt (eth-tx-receipt codec 0x95) represents an ethereum
+// transaction receipt
+type EthTxReceipt struct {
+	*types.Receipt
+
+	// txType is the EIP-2718 type of the receipt, the one of its
+	// transaction. Typed receipts are stored prefixed with it.
+	txType uint8
+
+	// logTrie is the cid of the root of the trie of the logs of the
+	// receipt. Without logs, it is the one of the empty trie, which,
+	// as the empty tries linked from a block header, has no node.
+	// It is only computed once asked for, see logTrieCid.
+	logTrie     *cid.Cid
+	logTrieOnce sync.Once
+
+	cid     *cid.Cid
+	rawdata []byte
+}
+
+// Static (compile time) check that EthTxReceipt satisfies the node.Node interface.
+var _ node.Node = (*EthTxReceipt)(nil)
+
+/*
+  INPUT
+*/
+
+// NewReceipt computes the cid and rlp-encodes a types.Receipt object
+// returning a proper EthTxReceipt node. The receipt is a legacy one,
+// see NewTypedReceipt for the receipts of typed transactions.
+func NewReceipt(r *types.Receipt) *EthTxReceipt {
+	return NewTypedReceipt(LegacyTxType, r)
+}
+
+// NewTypedReceipt computes the cid and the consensus encoding of a
+// types.Receipt object, the receipt of a transaction of the given
+// EIP-2718 type, returning a proper EthTxReceipt node. Typed receipts
+// are encoded as legacy ones, prefixed with their type.
+func NewTypedReceipt(txType uint8, r *types.Receipt) *EthTxReceipt {
+	rawdata := getRLP(r)
+	if txType != LegacyTxType {
+		rawdata = append([]byte{txType}, rawdata...)
+	}
+
+	return newEthTxReceipt(r, txType, rawdataToCid(MEthTxReceipt, rawdata), rawdata)
+}
+
+// FromTxReceiptRLP takes the consensus encoding of an ethereum transaction
+// receipt, either legacy RLP or an EIP-2718 typed receipt, to return it as an
+// IPLD node for further processing, along with the eth-receipt-log and
+// eth-receipt-log-trie nodes of its logs.
+func FromTxReceiptRLP(r io.Reader) (*EthTxReceipt, []*EthLog, []*EthLogTrie, error) {
+	rawdata, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	rct, err := DecodeEthTxReceipt(rawdataToCid(MEthTxReceipt, rawdata), rawdata)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	logNodes, logTrieNodes, _ := processLogs(rct.Logs)
+	return rct, logNodes, logTrieNodes, nil
+}
+
+/*
+ OUTPUT
+*/
+
+// DecodeEthTxReceipt takes a cid and its raw binary data
+// from IPFS and returns an EthTxReceipt object for further processing.
+// The raw binary data may be either a legacy RLP receipt or an
+// EIP-2718 typed receipt, prefixed with the type of its transaction.
+func DecodeEthTxReceipt(c *cid.Cid, b []byte) (*EthTxReceipt, error) {
+	r, txType, err := decodeReceipt(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return newEthTxReceipt(r, txType, c, b), nil
+}
+
+// newEthTxReceipt returns the EthTxReceipt node of a decoded receipt.
+func newEthTxReceipt(r *types.Receipt, txType uint8, c *cid.Cid, rawdata []byte) *EthTxReceipt {
+	return &EthTxReceipt{
+		Receipt: r,
+		txType:  txType,
+		cid:     c,
+		rawdata: rawdata,
+	}
+}
+
+// decodeReceipt parses the consensus encoding of a receipt. As with
+// transactions, typed receipts are prefixed with their type, always
+// below 0x7f, while legacy ones are RLP lists, starting at 0xc0.
+// Past the type, a typed receipt is the same list as a legacy one.
+func decodeReceipt(b []byte) (*types.Receipt, uint8, error) {
+	txType := uint8(LegacyTxType)
+	payload := b
+	if len(b) > 0 && b[0] <= 0x7f {
+		txType, payload = b[0], b[1:]
+		switch txType {
+		case AccessListTxType, DynamicFeeTxType, BlobTxType, SetCodeTxType:
+		default:
+			return nil, 0, fmt.Errorf("unsupported receipt type 0x%02x", txType)
+		}
+	}
+
+	var r types.Receipt
+	err := rlp.DecodeBytes(payload, &r)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &r, txType, nil
+}
+
+/*
+  Block INTERFACE
+*/
+
+// RawData returns the consensus encoding of the receipt, prefixed
+// with its type for typed receipts.
+func (r *EthTxReceipt) RawData() []byte {
+	return r.rawdata
+}
+
+// Cid returns the cid of the receipt.
+func (r *EthTxReceipt) Cid() *cid.Cid {
+	return r.cid
+}
+
+// String is a helper for output
+func (r *EthTxReceipt) String() string {
+	return fmt.Sprintf("<EthereumTxReceipt %s>", r.cid)
+}
+
+// Loggable returns in a map the type of IPLD Link.
+func (r *EthTxReceipt) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "eth-tx-receipt",
+	}
+}
+
+/*
+  Node INTERFACE
+*/
+
+// Resolve resolves a path through this node, stopping at any link boundary
+// and returning the object found as well as the remaining path to traverse
+func (r *EthTxReceipt) Resolve(p []string) (interface{}, []string, error) {
+	if len(p) == 0 {
+		return r, nil, nil
+	}
+
+	// "logs" links to the root of the log trie, while
+	// "logs/N" links to the N-th log of the receipt.
+	if p[0] == "logs" {
+		if len(p) == 1 {
+			return &node.Link{Cid: r.logTrieCid()}, nil, nil
+		}
+
+		idx, err := strconv.Atoi(p[1])
+		if err != nil || idx < 0 || idx >= len(r.Logs) {
+			return nil, nil, fmt.Errorf("no such link")
+		}
+		return &node.Link{Cid: NewLog(r.Logs[idx]).Cid()}, p[2:], nil
+	}
+
+	if len(p) > 1 {
+		return nil, nil, fmt.Errorf("unexpected path elements past %s", p[0])
+	}
+
+	switch p[0] {
+	case "bloom":
+		return r.Bloom, nil, nil
+	case "cumulativeGasUsed":
+		return r.CumulativeGasUsed, nil, nil
+	case "postState":
+		// Only pre-byzantium receipts carry the intermediate state root
+		if len(r.PostState) != 0 {
+			return fmt.Sprintf("0x%x", r.PostState), nil, nil
+		}
+	case "status":
+		if len(r.PostState) == 0 {
+			return r.Status, nil, nil
+		}
+	case "type":
+		return r.Type(), nil, nil
+	}
+
+	return nil, nil, fmt.Errorf("no such link")
+}
+
+// Tree lists all paths within the object under 'path', and up to the given depth.
+// To list the entire object (similar to `find .`) pass "" and -1
+func (r *EthTxReceipt) Tree(p string, depth int) []string {
+	if p != "" || depth == 0 {
+		return nil
+	}
+
+	if len(r.PostState) != 0 {
+		return []string{"bloom", "cumulativeGasUsed", "logs", "postState", "type"}
+	}
+	return []string{"bloom", "cumulativeGasUsed", "logs", "status", "type"}
+}
+
+// ResolveLink is a helper function that calls resolve and asserts the
+// output is a link
+func (r *EthTxReceipt) ResolveLink(p []string) (*node.Link, []string, error) {
+	obj, rest, err := r.Resolve(p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if lnk, ok := obj.(*node.Link); ok {
+		return lnk, rest, nil
+	}
+
+	return nil, nil, fmt.Errorf("resolved item was not a link")
+}
+
+// Copy will go away. It is here to comply with the interface.
+func (r *EthTxReceipt) Copy() node.Node {
+	panic("dont use this yet")
+}
+
+// Links is a helper function that returns all links within this object
+func (r *EthTxReceipt) Links() []*node.Link {
+	return []*node.Link{
+		&node.Link{Cid: r.logTrieCid()},
+	}
+}
+
+// Stat will go away. It is here to comply with the interface.
+func (r *EthTxReceipt) Stat() (*node.NodeStat, error) {
+	return &node.NodeStat{}, nil
+}
+
+// Size will go away. It is here to comply with the interface.
+func (r *EthTxReceipt) Size() (uint64, error) {
+	return uint64(len(r.rawdata)), nil
+}
+
+/*
+  EthTxReceipt functions
+*/
+
+// MarshalJSON processes the receipt into readable JSON format.
+func (r *EthTxReceipt) MarshalJSON() ([]byte, error) {
+	out := map[string]interface{}{
+		"bloom":             r.Bloom,
+		"cumulativeGasUsed": r.CumulativeGasUsed,
+		"logs":              r.logTrieCid(),
+		"type":              r.Type(),
+	}
+
+	if len(r.PostState) != 0 {
+		out["postState"] = fmt.Sprintf("0x%x", r.PostState)
+	} else {
+		out["status"] = r.Status
+	}
+
+	return json.Marshal(out)
+}
+
+// logTrieCid returns the cid of the root of the trie of the logs of
+// the receipt, computing it the first time only.
+func (r *EthTxReceipt) logTrieCid() *cid.Cid {
+	r.logTrieOnce.Do(func() {
+		r.logTrie = keccak256ToCid(MEthLogTrie, logTrieRoot(r.Logs))
+	})
+	return r.logTrie
+}
+
+// Type returns the EIP-2718 type of the receipt, the one of its
+// transaction, LegacyTxType for the ones predating typed envelopes.
+func (r *EthTxReceipt) Type() uint8 {
+	return r.txType
+}
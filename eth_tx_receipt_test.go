@@ -0,0 +1,406 @@
+package ipldeth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"testing"
+
+	block "github.com/ipfs/go-block-format"
+	node "github.com/ipfs/go-ipld-format"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+/*
+  INPUT
+*/
+
+func TestTxReceiptRlpParsing(t *testing.T) {
+	rct := prepareEthTxReceipt(t)
+
+	output, _, _, err := FromTxReceiptRLP(bytes.NewReader(rct.RawData()))
+	checkError(err, t)
+
+	if !output.Cid().Equals(rct.Cid()) {
+		t.Fatal("Wrong cid")
+	}
+	testTxReceiptFields(output, t)
+}
+
+func TestTxReceiptTypedRlpParsing(t *testing.T) {
+	raws := prepareRawReceipts("test_data/eth-block-receipts-raw-json-cancun", t)
+
+	testCases := []struct {
+		txType            uint8
+		status            uint
+		cumulativeGasUsed string
+		logs              int
+		hash              string
+	}{
+		{LegacyTxType, 1, "21000", 0, "0xe38e5532717f12f769b07ea016014bd39b74fb72def4de8442114cc2728609f2"},
+		{AccessListTxType, 1, "67109", 1, "0xc239ac6007e12edff7c4e8b31b73072fdec000df998371381c454bf2531ddadf"},
+		{DynamicFeeTxType, 0, "98986", 0, "0x2c54c7fa54c37a4f2df75fd2119e40c4103a6a41a7a3c92750a9fce7fed60d9c"},
+		{DynamicFeeTxType, 1, "166052", 1, "0xe306994758b6c6ba734163c56bafb462f19996e3c859413bebfe9aa2e07a52aa"},
+		{BlobTxType, 1, "187052", 0, "0xae270d7252a91d77a251246465b91617712f240a36ac6df0ee9a3c52805b3e0f"},
+	}
+	if len(raws) != len(testCases) {
+		t.Fatal("Wrong number of receipts in the test data")
+	}
+
+	for i, tc := range testCases {
+		output, logs, _, err := FromTxReceiptRLP(bytes.NewReader(raws[i]))
+		checkError(err, t)
+
+		if output.Type() != tc.txType {
+			t.Fatalf("Receipt %d: wrong type %d", i, output.Type())
+		}
+		if output.Status != tc.status {
+			t.Fatalf("Receipt %d: wrong status", i)
+		}
+		if output.CumulativeGasUsed.String() != tc.cumulativeGasUsed {
+			t.Fatalf("Receipt %d: wrong cumulative gas used", i)
+		}
+		if len(output.Logs) != tc.logs || len(logs) != tc.logs {
+			t.Fatalf("Receipt %d: wrong number of logs", i)
+		}
+
+		// The type is kept in the stored bytes, and so in the cid
+		if !bytes.Equal(output.RawData(), raws[i]) {
+			t.Fatalf("Receipt %d was not kept as given", i)
+		}
+		if !output.Cid().Equals(commonHashToCid(MEthTxReceipt, common.HexToHash(tc.hash))) {
+			t.Fatalf("Receipt %d: wrong cid", i)
+		}
+	}
+}
+
+func TestTxReceiptUnsupportedType(t *testing.T) {
+	_, _, _, err := FromTxReceiptRLP(bytes.NewReader([]byte{0x05, 0xc0}))
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if err.Error() != "unsupported receipt type 0x05" {
+		t.Fatalf("Wrong error %v", err)
+	}
+}
+
+/*
+  OUTPUT
+*/
+
+func TestDecodeTxReceipt(t *testing.T) {
+	rct := prepareEthTxReceipt(t)
+
+	// Just to clarify: This `block` is an IPFS block
+	storedReceipt, err := block.NewBlockWithCid(rct.RawData(), rct.Cid())
+	checkError(err, t)
+
+	ethReceipt, err := DecodeEthTxReceipt(storedReceipt.Cid(), storedReceipt.RawData())
+	checkError(err, t)
+
+	testTxReceiptFields(ethReceipt, t)
+}
+
+/*
+  Block INTERFACE
+*/
+
+func TestEthTxReceiptLoggable(t *testing.T) {
+	rct := prepareEthTxReceipt(t)
+
+	l := rct.Loggable()
+	if _, ok := l["type"]; !ok {
+		t.Fatal("Loggable map expected the field 'type'")
+	}
+
+	if l["type"] != "eth-tx-receipt" {
+		t.Fatal("Wrong Loggable 'type' value")
+	}
+}
+
+/*
+  Node INTERFACE
+*/
+
+func TestEthTxReceiptResolve(t *testing.T) {
+	rct := prepareEthTxReceipt(t)
+
+	// Empty path
+	obj, rest, err := rct.Resolve([]string{})
+	rrct, ok := obj.(*EthTxReceipt)
+	if !ok {
+		t.Fatal("Wrong type of returned object")
+	}
+	if rrct.Cid() != rct.Cid() {
+		t.Fatal("wrong returned object")
+	}
+	if rest != nil {
+		t.Fatal("rest should be nil")
+	}
+	if err != nil {
+		t.Fatal("err should be nil")
+	}
+
+	// len(p) > 1
+	badCases := [][]string{
+		[]string{"two", "elements"},
+		[]string{"here", "three", "elements"},
+	}
+	for _, bc := range badCases {
+		obj, rest, err = rct.Resolve(bc)
+		if obj != nil {
+			t.Fatal("obj should be nil")
+		}
+		if rest != nil {
+			t.Fatal("rest should be nil")
+		}
+		if err.Error() != fmt.Sprintf("unexpected path elements past %s", bc[0]) {
+			t.Fatal("wrong error")
+		}
+	}
+
+	// A post-byzantium receipt has no intermediate state root
+	moreBadCases := []string{
+		"not",
+		"a",
+		"receipt",
+		"field",
+		"postState",
+	}
+	for _, mbc := range moreBadCases {
+		obj, rest, err = rct.Resolve([]string{mbc})
+		if obj != nil {
+			t.Fatal("obj should be nil")
+		}
+		if rest != nil {
+			t.Fatal("rest should be nil")
+		}
+		if err.Error() != "no such link" {
+			t.Fatal("wrong error")
+		}
+	}
+
+	goodCases := []string{
+		"bloom",
+		"cumulativeGasUsed",
+		"logs",
+		"status",
+		"type",
+	}
+	for _, gc := range goodCases {
+		_, _, err = rct.Resolve([]string{gc})
+		if err != nil {
+			t.Fatalf("error should be nil %v", gc)
+		}
+	}
+}
+
+func TestEthTxReceiptResolveLogs(t *testing.T) {
+	rct := prepareEthTxReceipt(t)
+
+	// Only computed once asked for
+	if rct.logTrie != nil {
+		t.Fatal("Unexpected log trie root before resolving logs")
+	}
+
+	// The root of the log trie
+	obj, rest, err := rct.Resolve([]string{"logs"})
+	checkError(err, t)
+	_, logTrieNodes, root := processLogs(rct.Logs)
+	if !obj.(*node.Link).Cid.Equals(keccak256ToCid(MEthLogTrie, root)) {
+		t.Fatal("Wrong log trie link")
+	}
+	if len(logTrieNodes) != 1 || !logTrieNodes[0].Cid().Equals(obj.(*node.Link).Cid) {
+		t.Fatal("Log trie root not among the log trie nodes")
+	}
+	if len(rest) != 0 {
+		t.Fatal("Wrong rest of the path returned")
+	}
+
+	// A single log, leaving the rest of the path to it
+	obj, rest, err = rct.Resolve([]string{"logs", "0", "topics", "0"})
+	checkError(err, t)
+	if !obj.(*node.Link).Cid.Equals(NewLog(rct.Logs[0]).Cid()) {
+		t.Fatal("Wrong log link")
+	}
+	if len(rest) != 2 || rest[0] != "topics" || rest[1] != "0" {
+		t.Fatal("Wrong rest of the path returned")
+	}
+
+	for _, bc := range []string{"1", "-1", "a"} {
+		_, _, err = rct.Resolve([]string{"logs", bc})
+		if err == nil || err.Error() != "no such link" {
+			t.Fatalf("Expected 'no such link' error for %s", bc)
+		}
+	}
+}
+
+func TestEthTxReceiptResolveNoLogs(t *testing.T) {
+	rct := NewReceipt(&types.Receipt{
+		Status:            types.ReceiptStatusSuccessful,
+		CumulativeGasUsed: big.NewInt(21000),
+	})
+
+	// Without logs, the link is to the empty trie, which has no nodes
+	obj, _, err := rct.Resolve([]string{"logs"})
+	checkError(err, t)
+	if !obj.(*node.Link).Cid.Equals(commonHashToCid(MEthLogTrie, types.EmptyRootHash)) {
+		t.Fatal("Expected a link to the empty trie")
+	}
+	_, logTrieNodes, _ := processLogs(rct.Logs)
+	if len(logTrieNodes) != 0 {
+		t.Fatal("The empty trie should have no nodes")
+	}
+
+	// Decoding the receipt gets the same link
+	decoded, err := DecodeEthTxReceipt(rct.Cid(), rct.RawData())
+	checkError(err, t)
+	lnk, _, err := decoded.ResolveLink([]string{"logs"})
+	checkError(err, t)
+	if !lnk.Cid.Equals(obj.(*node.Link).Cid) {
+		t.Fatal("Wrong log trie link of the decoded receipt")
+	}
+}
+
+func TestEthTxReceiptTree(t *testing.T) {
+	rct := prepareEthTxReceipt(t)
+
+	// Bad cases
+	if rct.Tree("non-empty-string", 1) != nil {
+		t.Fatal("Expected nil to be returned")
+	}
+	if rct.Tree("", 0) != nil {
+		t.Fatal("Expected nil to be returned")
+	}
+
+	// Good cases
+	tree := rct.Tree("", 1)
+	lookupElements := map[string]interface{}{
+		"bloom":             nil,
+		"cumulativeGasUsed": nil,
+		"logs":              nil,
+		"status":            nil,
+		"type":              nil,
+	}
+
+	if len(tree) != len(lookupElements) {
+		t.Fatalf("Wrong number of elements. Got %d. Expecting %d", len(tree), len(lookupElements))
+	}
+
+	for _, te := range tree {
+		if _, ok := lookupElements[te]; !ok {
+			t.Fatalf("Unexpected Element: %v", te)
+		}
+	}
+}
+
+func TestEthTxReceiptResolveLink(t *testing.T) {
+	rct := prepareEthTxReceipt(t)
+
+	obj, rest, err := rct.ResolveLink([]string{"status"})
+	if obj != nil {
+		t.Fatalf("Expected obj to be nil")
+	}
+	if rest != nil {
+		t.Fatal("Expected rest to be nil")
+	}
+	if err.Error() != "resolved item was not a link" {
+		t.Fatal("Wrong error")
+	}
+}
+
+func TestEthTxReceiptLinks(t *testing.T) {
+	rct := prepareEthTxReceipt(t)
+
+	links := rct.Links()
+	if len(links) != 1 {
+		t.Fatal("Wrong number of links")
+	}
+
+	// The link to the log trie
+	lnk, _, err := rct.ResolveLink([]string{"logs"})
+	checkError(err, t)
+	if !links[0].Cid.Equals(lnk.Cid) {
+		t.Fatal("Wrong log trie link")
+	}
+}
+
+func TestEthTxReceiptJSONMarshal(t *testing.T) {
+	rct := prepareEthTxReceipt(t)
+
+	jsonOutput, err := rct.MarshalJSON()
+	checkError(err, t)
+
+	var data map[string]interface{}
+	err = json.Unmarshal(jsonOutput, &data)
+	checkError(err, t)
+
+	if parseFloat(data["cumulativeGasUsed"]) != "43000" {
+		t.Fatal("Wrong cumulative gas used")
+	}
+	if parseFloat(data["status"]) != "1" {
+		t.Fatal("Wrong status")
+	}
+	if _, ok := data["postState"]; ok {
+		t.Fatal("Unexpected postState")
+	}
+	if parseFloat(data["type"]) != "0" {
+		t.Fatal("Wrong type")
+	}
+}
+
+/*
+  AUXILIARS
+*/
+
+// prepareEthTxReceipt builds a post-byzantium receipt with a single log.
+func prepareEthTxReceipt(t *testing.T) *EthTxReceipt {
+	return NewReceipt(&types.Receipt{
+		Status:            types.ReceiptStatusSuccessful,
+		CumulativeGasUsed: big.NewInt(43000),
+		Logs: []*types.Log{
+			&types.Log{
+				Address: common.HexToAddress("0x32be343b94f860124dc4fee278fdcbd38c102d88"),
+				Topics: []common.Hash{
+					common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"),
+				},
+				Data: []byte{0x01},
+			},
+		},
+	})
+}
+
+func testTxReceiptFields(rct *EthTxReceipt, t *testing.T) {
+	if rct.Status != types.ReceiptStatusSuccessful {
+		t.Fatal("Wrong Status")
+	}
+	if rct.CumulativeGasUsed.String() != "43000" {
+		t.Fatal("Wrong Cumulative Gas Used")
+	}
+	if len(rct.Logs) != 1 {
+		t.Fatal("Wrong number of logs")
+	}
+	if fmt.Sprintf("%x", rct.Logs[0].Address) != "32be343b94f860124dc4fee278fdcbd38c102d88" {
+		t.Fatal("Wrong log address")
+	}
+}
+
+// prepareRawReceipts returns the receipts held by the given
+// response of debug_getRawReceipts.
+func prepareRawReceipts(filepath string, t *testing.T) []hexutil.Bytes {
+	fi, err := os.Open(filepath)
+	checkError(err, t)
+	defer fi.Close()
+
+	var obj struct {
+		Result []hexutil.Bytes `json:"result"`
+	}
+	checkError(json.NewDecoder(fi).Decode(&obj), t)
+
+	return obj.Result
+}
@@ -0,0 +1,228 @@
+package ipldeth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	cid "github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// EthTxReceiptTrie (eth-tx-receipt-trie codec 0x94) represents
+// a node from the transaction receipt trie in ethereum.
+type EthTxReceiptTrie struct {
+	*TrieNode
+}
+
+// Static (compile time) check that EthTxReceiptTrie satisfies the node.Node interface.
+var _ node.Node = (*EthTxReceiptTrie)(nil)
+
+/*
+ INPUT
+*/
+
+// As with the transaction trie, the receipt trie can only be built
+// with all the receipts of a block at hand, so it is created alongside
+// them, checking its root against the one committed in the block header.
+
+// FromReceipts takes the receipts of a block, in transaction order, along
+// with the transactions of the block, to return them as eth-tx-receipt and
+// eth-tx-receipt-trie IPLD nodes, along with the eth-receipt-log and
+// eth-receipt-log-trie nodes of their logs. Each receipt is encoded with the
+// type of its transaction, as it is committed in the trie. The computed trie
+// root must match the ReceiptHash of the given block header.
+func FromReceipts(b *EthBlock, txs []*EthTx, rcts []*types.Receipt) ([]*EthTxReceipt, []*EthTxReceiptTrie, []*EthLog, []*EthLogTrie, error) {
+	if len(rcts) != len(txs) {
+		return nil, nil, nil, nil, fmt.Errorf("%d receipts given for %d transactions", len(rcts), len(txs))
+	}
+
+	var ethRcts []*EthTxReceipt
+	for idx, rct := range rcts {
+		ethRcts = append(ethRcts, NewTypedReceipt(txs[idx].Type(), rct))
+	}
+
+	return processReceipts(ethRcts, b.ReceiptHash[:])
+}
+
+// FromRawReceiptsJSON takes the output of the JSON API method "debug_getRawReceipts",
+// the consensus encoding of the receipts of a block in hex, legacy or typed, to
+// return them as IPLD nodes, as FromReceipts does. The receipts are kept as the
+// client encoded them, and so is their trie, which root must match the
+// ReceiptHash of the given block header.
+func FromRawReceiptsJSON(b *EthBlock, r io.Reader) ([]*EthTxReceipt, []*EthTxReceiptTrie, []*EthLog, []*EthLogTrie, error) {
+	if b == nil {
+		return nil, nil, nil, nil, fmt.Errorf("no block header to check the receipts against")
+	}
+
+	var raws []hexutil.Bytes
+	if err := decodeRawJSON(r, &raws); err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	var rcts []*EthTxReceipt
+	for idx, raw := range raws {
+		rct, err := DecodeEthTxReceipt(rawdataToCid(MEthTxReceipt, raw), raw)
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("receipt %d: %v", idx, err)
+		}
+		rcts = append(rcts, rct)
+	}
+
+	return processReceipts(rcts, b.ReceiptHash[:])
+}
+
+// FromRawBlockReceiptsJSON takes the outputs of the JSON API methods
+// "debug_getRawBlock", or "debug_getRawHeader", and "debug_getRawReceipts"
+// for the same block, in a single document {"block": ..., "receipts": ...},
+// to return the nodes of the block, as FromRawBlockJSON does, along with its
+// receipts and their logs, checked against its header.
+func FromRawBlockReceiptsJSON(r io.Reader) (*BlockNodes, error) {
+	var obj struct {
+		Block    json.RawMessage `json:"block"`
+		Receipts json.RawMessage `json:"receipts"`
+	}
+	dec := json.NewDecoder(r)
+	err := dec.Decode(&obj)
+	if err != nil {
+		return nil, err
+	}
+	if len(obj.Block) == 0 || len(obj.Receipts) == 0 {
+		return nil, fmt.Errorf("expected the responses of both a block and its receipts")
+	}
+
+	bn, err := FromRawBlockJSON(bytes.NewReader(obj.Block))
+	if err != nil {
+		return nil, err
+	}
+
+	bn.Receipts, bn.ReceiptTrieNodes, bn.Logs, bn.LogTrieNodes, err = FromRawReceiptsJSON(bn.Block, bytes.NewReader(obj.Receipts))
+	if err != nil {
+		return nil, err
+	}
+
+	return bn, nil
+}
+
+// processReceipts will take the receipt nodes of a block to return IPLD
+// node slices for eth-tx-receipt, eth-tx-receipt-trie, eth-receipt-log
+// and eth-receipt-log-trie. The trie leaves are the consensus encoding
+// of the receipts, this is, their raw data.
+func processReceipts(rcts []*EthTxReceipt, expectedRctRoot []byte) ([]*EthTxReceipt, []*EthTxReceiptTrie, []*EthLog, []*EthLogTrie, error) {
+	var (
+		ethLogNodes     []*EthLog
+		ethLogTrieNodes []*EthLogTrie
+	)
+	receiptTrie := newRctTrie()
+
+	for idx, rct := range rcts {
+		receiptTrie.add(idx, rct.RawData())
+
+		logNodes, logTrieNodes, _ := processLogs(rct.Logs)
+		ethLogNodes = append(ethLogNodes, logNodes...)
+		ethLogTrieNodes = append(ethLogTrieNodes, logTrieNodes...)
+	}
+
+	if !bytes.Equal(receiptTrie.rootHash(), expectedRctRoot) {
+		return nil, nil, nil, nil, fmt.Errorf("wrong receipt hash computed")
+	}
+
+	ethRctTrieNodes := receiptTrie.getNodes()
+
+	return rcts, ethRctTrieNodes, ethLogNodes, ethLogTrieNodes, nil
+}
+
+/*
+  OUTPUT
+*/
+
+// DecodeEthTxReceiptTrie returns an EthTxReceiptTrie object from its cid and rawdata.
+func DecodeEthTxReceiptTrie(c *cid.Cid, b []byte) (*EthTxReceiptTrie, error) {
+	tn, err := decodeTrieNode(c, b, decodeEthTxReceiptTrieLeaf)
+	if err != nil {
+		return nil, err
+	}
+	return &EthTxReceiptTrie{TrieNode: tn}, nil
+}
+
+// decodeEthTxReceiptTrieLeaf parses a eth-tx-receipt-trie leaf
+// from decoded RLP elements. Typed receipts are kept with their type.
+func decodeEthTxReceiptTrieLeaf(i []interface{}) ([]interface{}, error) {
+	r, err := DecodeEthTxReceipt(rawdataToCid(MEthTxReceipt, i[1].([]byte)), i[1].([]byte))
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{
+		i[0].([]byte),
+		r,
+	}, nil
+}
+
+/*
+  Block INTERFACE
+*/
+
+// RawData returns the binary of the RLP encode of the receipt trie node.
+func (t *EthTxReceiptTrie) RawData() []byte {
+	return t.rawdata
+}
+
+// Cid returns the cid of the receipt trie node.
+func (t *EthTxReceiptTrie) Cid() *cid.Cid {
+	return t.cid
+}
+
+// String is a helper for output
+func (t *EthTxReceiptTrie) String() string {
+	return fmt.Sprintf("<EthereumTxReceiptTrie %s>", t.cid)
+}
+
+// Loggable returns in a map the type of IPLD Link.
+func (t *EthTxReceiptTrie) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "eth-tx-receipt-trie",
+	}
+}
+
+/*
+  EthTxReceiptTrie functions
+*/
+
+// rctTrie wraps a localTrie for use on the receipt trie.
+type rctTrie struct {
+	*localTrie
+}
+
+// newRctTrie initializes and returns a rctTrie.
+func newRctTrie() *rctTrie {
+	return &rctTrie{
+		localTrie: newLocalTrie(),
+	}
+}
+
+// getNodes invokes the localTrie, which computes the root hash of the
+// receipt trie and returns its database keys, to return a slice
+// of EthTxReceiptTrie nodes.
+func (rt *rctTrie) getNodes() []*EthTxReceiptTrie {
+	keys := rt.getKeys()
+	var out []*EthTxReceiptTrie
+
+	for _, k := range keys {
+		rawdata, err := rt.db.Get(k)
+		if err != nil {
+			panic(err)
+		}
+
+		tn := &TrieNode{
+			cid:     rawdataToCid(MEthTxReceiptTrie, rawdata),
+			rawdata: rawdata,
+		}
+		out = append(out, &EthTxReceiptTrie{TrieNode: tn})
+	}
+
+	return out
+}
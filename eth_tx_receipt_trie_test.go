@@ -0,0 +1,382 @@
+package ipldeth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+/*
+  INPUT
+*/
+
+func TestFromReceipts(t *testing.T) {
+	rcts := prepareReceipts()
+	ethBlock := &EthBlock{
+		Header: &types.Header{ReceiptHash: types.DeriveSha(types.Receipts(rcts))},
+	}
+
+	rctNodes, rctTrieNodes, _, _, err := FromReceipts(ethBlock, prepareReceiptTxs(len(rcts)), rcts)
+	checkError(err, t)
+
+	if len(rctNodes) != len(rcts) {
+		t.Fatal("Wrong number of parsed receipts")
+	}
+	if len(rctTrieNodes) == 0 {
+		t.Fatal("Expected receipt trie nodes")
+	}
+
+	// The receipts link of the block header must land on one of our nodes
+	lnk, _, err := ethBlock.ResolveLink([]string{"receipts"})
+	checkError(err, t)
+
+	var found bool
+	for _, rtn := range rctTrieNodes {
+		if rtn.Cid().Equals(lnk.Cid) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("Receipt trie root not found among the returned nodes")
+	}
+}
+
+func TestFromReceiptsWrongRoot(t *testing.T) {
+	ethBlock := &EthBlock{
+		Header: &types.Header{ReceiptHash: common.HexToHash("0x01")},
+	}
+
+	_, _, _, _, err := FromReceipts(ethBlock, prepareReceiptTxs(3), prepareReceipts())
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if err.Error() != "wrong receipt hash computed" {
+		t.Fatal("Wrong error")
+	}
+}
+
+func TestFromReceiptsWrongTxCount(t *testing.T) {
+	ethBlock := &EthBlock{
+		Header: &types.Header{ReceiptHash: common.HexToHash("0x01")},
+	}
+
+	_, _, _, _, err := FromReceipts(ethBlock, prepareReceiptTxs(2), prepareReceipts())
+	if err == nil || err.Error() != "3 receipts given for 2 transactions" {
+		t.Fatalf("Expected error '3 receipts given for 2 transactions'\r\ngot %v", err)
+	}
+}
+
+func TestFromReceiptsTyped(t *testing.T) {
+	// Every transaction type, the receipts must be encoded with it
+	// to get to the receipts root of the header.
+	fi, err := os.Open("test_data/eth-block-body-rlp-cancun")
+	checkError(err, t)
+	defer fi.Close()
+
+	bn, err := FromBlockRLP(fi)
+	checkError(err, t)
+
+	raws := prepareRawReceipts("test_data/eth-block-receipts-raw-json-cancun", t)
+	var rcts []*types.Receipt
+	for _, raw := range raws {
+		rct, err := DecodeEthTxReceipt(rawdataToCid(MEthTxReceipt, raw), raw)
+		checkError(err, t)
+		rcts = append(rcts, rct.Receipt)
+	}
+
+	rctNodes, rctTrieNodes, logNodes, _, err := FromReceipts(bn.Block, bn.Txs, rcts)
+	checkError(err, t)
+
+	for i, rn := range rctNodes {
+		if !bytes.Equal(rn.RawData(), raws[i]) {
+			t.Fatalf("Receipt %d was not encoded with its type", i)
+		}
+		if rn.Type() != bn.Txs[i].Type() {
+			t.Fatalf("Wrong type of receipt %d", i)
+		}
+	}
+	if len(logNodes) != 2 {
+		t.Fatal("Wrong number of logs")
+	}
+
+	lnk, _, err := bn.Block.ResolveLink([]string{"receipts"})
+	checkError(err, t)
+
+	var found bool
+	for _, rtn := range rctTrieNodes {
+		if rtn.Cid().Equals(lnk.Cid) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("Receipt trie root not found among the returned nodes")
+	}
+	if lnk.Cid.String() != commonHashToCid(MEthTxReceiptTrie,
+		common.HexToHash("1d33a16fc5b9027f23c0ef5828c9e777c8fdd683231b46848be08eb368f47d21")).String() {
+		t.Fatal("Wrong receipts root in the header")
+	}
+}
+
+func TestFromRawReceiptsJSON(t *testing.T) {
+	rcts := prepareReceipts()
+	rcts[0].Logs = prepareLogs(2)
+	ethBlock := &EthBlock{
+		Header: &types.Header{ReceiptHash: types.DeriveSha(types.Receipts(rcts))},
+	}
+
+	var raws []hexutil.Bytes
+	for _, rct := range rcts {
+		raws = append(raws, NewReceipt(rct).RawData())
+	}
+
+	rctNodes, rctTrieNodes, logNodes, logTrieNodes, err := FromRawReceiptsJSON(ethBlock, prepareRawJSON(raws, t))
+	checkError(err, t)
+
+	if len(rctNodes) != len(rcts) {
+		t.Fatal("Wrong number of parsed receipts")
+	}
+	for i, rn := range rctNodes {
+		if !bytes.Equal(rn.RawData(), raws[i]) {
+			t.Fatalf("Receipt %d was not kept as given", i)
+		}
+	}
+	if len(rctTrieNodes) == 0 {
+		t.Fatal("Expected receipt trie nodes")
+	}
+	if len(logNodes) != 2 || len(logTrieNodes) == 0 {
+		t.Fatal("Wrong logs")
+	}
+
+	// Not without the block header
+	_, _, _, _, err = FromRawReceiptsJSON(nil, prepareRawJSON(raws, t))
+	if err == nil {
+		t.Fatal("Expected an error without a block header")
+	}
+
+	// Nor with another one
+	ethBlock.ReceiptHash = common.HexToHash("0x01")
+	_, _, _, _, err = FromRawReceiptsJSON(ethBlock, prepareRawJSON(raws, t))
+	if err == nil || err.Error() != "wrong receipt hash computed" {
+		t.Fatalf("Expected error 'wrong receipt hash computed'\r\ngot %v", err)
+	}
+}
+
+func TestFromRawReceiptsJSONInvalidReceipt(t *testing.T) {
+	raws := []hexutil.Bytes{NewReceipt(prepareReceipts()[0]).RawData(), {0x01, 0x02}}
+
+	ethBlock := &EthBlock{Header: &types.Header{}}
+
+	_, _, _, _, err := FromRawReceiptsJSON(ethBlock, prepareRawJSON(raws, t))
+	if err == nil || !strings.HasPrefix(err.Error(), "receipt 1: ") {
+		t.Fatalf("Expected an error on receipt 1\r\ngot %v", err)
+	}
+}
+
+func TestFromRawBlockReceiptsJSON(t *testing.T) {
+	rawBlock, err := ioutil.ReadFile("test_data/eth-block-body-rlp-london")
+	checkError(err, t)
+	receipts, err := ioutil.ReadFile("test_data/eth-block-receipts-raw-json-london")
+	checkError(err, t)
+
+	block, err := ioutil.ReadAll(prepareRawJSON(hexutil.Bytes(rawBlock), t))
+	checkError(err, t)
+	doc, err := json.Marshal(map[string]json.RawMessage{
+		"block":    block,
+		"receipts": receipts,
+	})
+	checkError(err, t)
+
+	bn, err := FromRawBlockReceiptsJSON(bytes.NewReader(doc))
+	checkError(err, t)
+
+	if len(bn.Txs) != 4 || len(bn.Receipts) != 4 || len(bn.ReceiptTrieNodes) == 0 || len(bn.Logs) != 2 {
+		t.Fatal("Wrong block or receipts")
+	}
+
+	// Both are needed
+	doc, err = json.Marshal(map[string]json.RawMessage{"receipts": receipts})
+	checkError(err, t)
+	_, err = FromRawBlockReceiptsJSON(bytes.NewReader(doc))
+	if err == nil || err.Error() != "expected the responses of both a block and its receipts" {
+		t.Fatalf("Expected error 'expected the responses of both a block and its receipts'\r\ngot %v", err)
+	}
+}
+
+func TestFromRawReceiptsJSONTyped(t *testing.T) {
+	blocks := []struct {
+		block    string
+		receipts string
+		logs     int
+	}{
+		{"test_data/eth-block-body-rlp-london", "test_data/eth-block-receipts-raw-json-london", 2},
+		{"test_data/eth-block-body-rlp-cancun", "test_data/eth-block-receipts-raw-json-cancun", 2},
+	}
+
+	for _, tc := range blocks {
+		fi, err := os.Open(tc.block)
+		checkError(err, t)
+		bn, err := FromBlockRLP(fi)
+		fi.Close()
+		checkError(err, t)
+
+		fr, err := os.Open(tc.receipts)
+		checkError(err, t)
+		rctNodes, rctTrieNodes, logNodes, _, err := FromRawReceiptsJSON(bn.Block, fr)
+		fr.Close()
+		checkError(err, t)
+
+		if len(rctNodes) != len(bn.Txs) {
+			t.Fatalf("%s: wrong number of receipts\r\nexpected %d\r\ngot %d", tc.receipts, len(bn.Txs), len(rctNodes))
+		}
+		for i, rn := range rctNodes {
+			if rn.Type() != bn.Txs[i].Type() {
+				t.Fatalf("%s: wrong type of receipt %d", tc.receipts, i)
+			}
+		}
+		if len(logNodes) != tc.logs {
+			t.Fatalf("%s: wrong number of logs\r\nexpected %d\r\ngot %d", tc.receipts, tc.logs, len(logNodes))
+		}
+
+		// The root of the trie is the one of the header
+		root := commonHashToCid(MEthTxReceiptTrie, bn.Block.ReceiptHash)
+		var found bool
+		for _, rtn := range rctTrieNodes {
+			if rtn.Cid().Equals(root) {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("%s: receipt trie root not found among the returned nodes", tc.receipts)
+		}
+	}
+}
+
+/*
+  OUTPUT
+*/
+
+func TestRctTrieDecodeLeaf(t *testing.T) {
+	// A single receipt makes the root of the trie a leaf
+	rcts := prepareReceipts()[:1]
+	root := types.DeriveSha(types.Receipts(rcts))
+
+	_, rctTrieNodes, _, _, err := processReceipts(prepareReceiptNodes(rcts), root[:])
+	checkError(err, t)
+
+	if len(rctTrieNodes) != 1 {
+		t.Fatal("Expected a single receipt trie node")
+	}
+
+	ethRctTrie, err := DecodeEthTxReceiptTrie(rctTrieNodes[0].Cid(), rctTrieNodes[0].RawData())
+	checkError(err, t)
+
+	if ethRctTrie.nodeKind != "leaf" {
+		t.Fatal("Wrong nodeKind")
+	}
+	if _, ok := ethRctTrie.elements[1].(*EthTxReceipt); !ok {
+		t.Fatal("Wrong Type. Element should be a receipt")
+	}
+
+	// Key of the first receipt is rlp(0) = 0x80
+	obj, rest, err := ethRctTrie.Resolve([]string{"8", "0", "cumulativeGasUsed"})
+	checkError(err, t)
+	if rest != nil {
+		t.Fatal("rest should be nil")
+	}
+	if fmt.Sprintf("%v", obj) != "21000" {
+		t.Fatal("Wrong cumulative gas used")
+	}
+}
+
+func TestRctTrieDecodeTypedLeaf(t *testing.T) {
+	raw := prepareRawReceipts("test_data/eth-block-receipts-raw-json-cancun", t)[1]
+
+	// A single typed receipt makes the root of the trie a leaf,
+	// holding the receipt along with its type.
+	leaf := getRLP([]interface{}{[]byte{0x20, 0x80}, []byte(raw)})
+	ethRctTrie, err := DecodeEthTxReceiptTrie(rawdataToCid(MEthTxReceiptTrie, leaf), leaf)
+	checkError(err, t)
+
+	rct, ok := ethRctTrie.elements[1].(*EthTxReceipt)
+	if !ok {
+		t.Fatal("Wrong Type. Element should be a receipt")
+	}
+	if rct.Type() != AccessListTxType {
+		t.Fatal("Wrong receipt type")
+	}
+	if !bytes.Equal(rct.RawData(), raw) {
+		t.Fatal("The receipt was not kept as given")
+	}
+
+	obj, _, err := ethRctTrie.Resolve([]string{"8", "0", "cumulativeGasUsed"})
+	checkError(err, t)
+	if fmt.Sprintf("%v", obj) != "67109" {
+		t.Fatal("Wrong cumulative gas used")
+	}
+}
+
+/*
+  Block INTERFACE
+*/
+
+func TestEthTxReceiptTrieLoggable(t *testing.T) {
+	rcts := prepareReceipts()
+	root := types.DeriveSha(types.Receipts(rcts))
+
+	_, rctTrieNodes, _, _, err := processReceipts(prepareReceiptNodes(rcts), root[:])
+	checkError(err, t)
+
+	l := rctTrieNodes[0].Loggable()
+	if _, ok := l["type"]; !ok {
+		t.Fatal("Loggable map expected the field 'type'")
+	}
+
+	if l["type"] != "eth-tx-receipt-trie" {
+		t.Fatal("Wrong Loggable 'type' value")
+	}
+}
+
+/*
+  AUXILIARS
+*/
+
+// prepareReceiptNodes returns the legacy receipt nodes of the given receipts.
+func prepareReceiptNodes(rcts []*types.Receipt) []*EthTxReceipt {
+	var nodes []*EthTxReceipt
+	for _, rct := range rcts {
+		nodes = append(nodes, NewReceipt(rct))
+	}
+	return nodes
+}
+
+// prepareReceiptTxs returns n legacy value transfers.
+func prepareReceiptTxs(n int) []*EthTx {
+	var txs []*EthTx
+	for i := 0; i < n; i++ {
+		txs = append(txs, NewTx(types.NewTransaction(uint64(i),
+			common.HexToAddress("0x5abfec25f74cd88437631a7731906932776356f9"),
+			big.NewInt(1), big.NewInt(21000), big.NewInt(1), nil)))
+	}
+	return txs
+}
+
+// prepareReceipts returns the receipts of three simple value transfers.
+func prepareReceipts() []*types.Receipt {
+	var rcts []*types.Receipt
+	for i := int64(1); i <= 3; i++ {
+		rcts = append(rcts, &types.Receipt{
+			Status:            types.ReceiptStatusSuccessful,
+			CumulativeGasUsed: big.NewInt(21000 * i),
+		})
+	}
+	return rcts
+}
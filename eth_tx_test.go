@@ -1,12 +1,18 @@
 package ipldeth
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
+	"math/big"
 	"os"
 	"testing"
 
 	block "github.com/ipfs/go-block-format"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 /*
@@ -18,26 +24,26 @@ func TestTxInBlockBodyRlpParsing(t *testing.T) {
 	fi, err := os.Open("test_data/eth-block-body-rlp-999999")
 	checkError(err, t)
 
-	_, output, _, err := FromBlockRLP(fi)
+	bn, err := FromBlockRLP(fi)
 	checkError(err, t)
 
-	if len(output) != 11 {
+	if len(bn.Txs) != 11 {
 		t.Fatal("Wrong number of parsed txs")
 	}
 
 	// Oh, let's just grab the last element and one from the middle
-	testTx05Fields(output[5], t)
-	testTx10Fields(output[10], t)
+	testTx05Fields(bn.Txs[5], t)
+	testTx10Fields(bn.Txs[10], t)
 }
 
 func TestTxInBlockHeaderRlpParsing(t *testing.T) {
 	fi, err := os.Open("test_data/eth-block-header-rlp-999999")
 	checkError(err, t)
 
-	_, output, _, err := FromBlockRLP(fi)
+	bn, err := FromBlockRLP(fi)
 	checkError(err, t)
 
-	if len(output) != 0 {
+	if len(bn.Txs) != 0 {
 		t.Fatal("No transactions should have been gotten from here")
 	}
 }
@@ -46,15 +52,15 @@ func TestTxInBlockBodyJsonParsing(t *testing.T) {
 	fi, err := os.Open("test_data/eth-block-body-json-999999")
 	checkError(err, t)
 
-	_, output, _, err := FromBlockJSON(fi)
+	bn, err := FromBlockJSON(fi)
 	checkError(err, t)
 
-	if len(output) != 11 {
+	if len(bn.Txs) != 11 {
 		t.Fatal("Wrong number of parsed txs")
 	}
 
-	testTx05Fields(output[5], t)
-	testTx10Fields(output[10], t)
+	testTx05Fields(bn.Txs[5], t)
+	testTx10Fields(bn.Txs[10], t)
 }
 
 /*
@@ -173,6 +179,7 @@ func TestEthTxResolve(t *testing.T) {
 		"r",
 		"s",
 		"toAddress",
+		"type",
 		"v",
 		"value",
 	}
@@ -215,6 +222,7 @@ func TestEthTxTree(t *testing.T) {
 		"r":         nil,
 		"s":         nil,
 		"toAddress": nil,
+		"type":      nil,
 		"v":         nil,
 		"value":     nil,
 	}
@@ -308,6 +316,381 @@ func TestEthTxSize(t *testing.T) {
 	}
 }
 
+/*
+  Typed transactions
+*/
+
+func TestTypedTxDecoding(t *testing.T) {
+	for _, tt := range prepareTypedTxs() {
+		rawdata, err := tt.encode()
+		checkError(err, t)
+
+		if rawdata[0] != tt.Type {
+			t.Fatalf("Wrong envelope type %x", rawdata[0])
+		}
+
+		ethTx, err := DecodeEthTx(rawdataToCid(MEthTx, rawdata), rawdata)
+		checkError(err, t)
+
+		if ethTx.Type() != tt.Type {
+			t.Fatal("Wrong transaction type")
+		}
+		if !bytes.Equal(ethTx.RawData(), rawdata) {
+			t.Fatal("Wrong rawdata")
+		}
+		if fmt.Sprintf("%x", ethTx.To()) != "32be343b94f860124dc4fee278fdcbd38c102d88" {
+			t.Fatal("Wrong Recipient")
+		}
+		if fmt.Sprintf("%v", ethTx.Gas()) != "21000" {
+			t.Fatal("Wrong Gas")
+		}
+		if fmt.Sprintf("%v", ethTx.Nonce()) != "7" {
+			t.Fatal("Wrong Nonce")
+		}
+
+		size, err := ethTx.Size()
+		checkError(err, t)
+		if size != uint64(len(rawdata)) {
+			t.Fatal("Wrong size")
+		}
+
+		// The envelope must survive a roundtrip
+		reencoded, err := ethTx.typed.encode()
+		checkError(err, t)
+		if !bytes.Equal(reencoded, rawdata) {
+			t.Fatal("Wrong re-encoded envelope")
+		}
+	}
+}
+
+func TestTypedTxDecodingUnsupportedType(t *testing.T) {
+	_, err := DecodeEthTx(rawdataToCid(MEthTx, []byte{0x7f, 0xc0}), []byte{0x7f, 0xc0})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if err.Error() != "unsupported transaction type 0x7f" {
+		t.Fatal("Wrong error")
+	}
+}
+
+func TestTypedTxResolve(t *testing.T) {
+	typedTxs := prepareTypedTxs()
+
+	typeFields := map[uint8][]string{
+		AccessListTxType: {"accessList", "chainId", "gasPrice"},
+		DynamicFeeTxType: {"accessList", "chainId", "maxFeePerGas", "maxPriorityFeePerGas"},
+		BlobTxType: {"accessList", "blobVersionedHashes", "chainId",
+			"maxFeePerBlobGas", "maxFeePerGas", "maxPriorityFeePerGas"},
+		SetCodeTxType: {"accessList", "authorizationList", "chainId",
+			"maxFeePerGas", "maxPriorityFeePerGas"},
+	}
+
+	for _, tt := range typedTxs {
+		rawdata, err := tt.encode()
+		checkError(err, t)
+		ethTx, err := DecodeEthTx(rawdataToCid(MEthTx, rawdata), rawdata)
+		checkError(err, t)
+
+		obj, _, err := ethTx.Resolve([]string{"type"})
+		checkError(err, t)
+		if fmt.Sprintf("%v", obj) != fmt.Sprintf("%v", tt.Type) {
+			t.Fatalf("Wrong type %v", obj)
+		}
+
+		for _, f := range typeFields[tt.Type] {
+			_, _, err = ethTx.Resolve([]string{f})
+			if err != nil {
+				t.Fatalf("error should be nil %v", f)
+			}
+		}
+
+		// The tree has the 9 common fields plus the ones of the type
+		if len(ethTx.Tree("", 1)) != 9+len(typeFields[tt.Type]) {
+			t.Fatalf("Wrong number of elements for type %d", tt.Type)
+		}
+
+		// Only legacy and access list transactions have a gas price
+		if tt.Type != AccessListTxType {
+			_, _, err = ethTx.Resolve([]string{"gasPrice"})
+			if err == nil || err.Error() != "no such link" {
+				t.Fatal("Expected 'no such link' error for gasPrice")
+			}
+		}
+	}
+
+	// And a few values
+	rawdata, err := typedTxs[2].encode()
+	checkError(err, t)
+	ethTx, err := DecodeEthTx(rawdataToCid(MEthTx, rawdata), rawdata)
+	checkError(err, t)
+
+	obj, _, err := ethTx.Resolve([]string{"chainId"})
+	checkError(err, t)
+	if obj.(string) != "0x1" {
+		t.Fatal("Wrong chainId")
+	}
+	obj, _, err = ethTx.Resolve([]string{"maxFeePerGas"})
+	checkError(err, t)
+	if fmt.Sprintf("%v", obj) != "30000000000" {
+		t.Fatal("Wrong maxFeePerGas")
+	}
+	obj, _, err = ethTx.Resolve([]string{"blobVersionedHashes"})
+	checkError(err, t)
+	if len(obj.([]common.Hash)) != 1 {
+		t.Fatal("Wrong blobVersionedHashes")
+	}
+
+	v, r, s := ethTx.RawSignatureValues()
+	if v.String() != "1" || r.String() != "2" || s.String() != "3" {
+		t.Fatal("Wrong signature values")
+	}
+}
+
+func TestTypedTxFromJSON(t *testing.T) {
+	input := []byte(`{
+		"type": "0x2",
+		"chainId": "0x1",
+		"nonce": "0x7",
+		"maxPriorityFeePerGas": "0x3b9aca00",
+		"maxFeePerGas": "0x6fc23ac00",
+		"gas": "0x5208",
+		"to": "0x32be343b94f860124dc4fee278fdcbd38c102d88",
+		"value": "0x1",
+		"input": "0x",
+		"accessList": [],
+		"v": "0x1",
+		"r": "0x2",
+		"s": "0x3"
+	}`)
+
+	ethTx, err := txFromJSON(input)
+	checkError(err, t)
+
+	// Same transaction as the one prepared by hand
+	expected, err := prepareTypedTxs()[1].encode()
+	checkError(err, t)
+	if !bytes.Equal(ethTx.RawData(), expected) {
+		t.Fatal("Wrong rawdata")
+	}
+	if !ethTx.Cid().Equals(rawdataToCid(MEthTx, expected)) {
+		t.Fatal("Wrong cid")
+	}
+}
+
+func TestTypedTxFromJSONMissingFields(t *testing.T) {
+	input := []byte(`{"type": "0x2", "chainId": "0x1", "nonce": "0x7",
+		"gas": "0x5208", "value": "0x1", "v": "0x1", "r": "0x2", "s": "0x3"}`)
+
+	_, err := txFromJSON(input)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if err.Error() != "missing required fields for typed transaction: [maxFeePerGas maxPriorityFeePerGas]" {
+		t.Fatalf("Wrong error %v", err)
+	}
+}
+
+func TestSetCodeTx(t *testing.T) {
+	// Signed by the first of the well known development keys, delegating
+	// the account of the second one, which signed the authorization
+	envelope := "04f8ca0105843b9aca008504a817c800830186a09470997970c51812dc3a010c7d01b50e0d17dc79c88080c0f85cf85a019463c0c19a282a1b52b07dd5a65b58948a07dae32b8080a0014310d20a056f480c68c15ab8731ed4cf0fe83e4c3b659cb628298312ecc9d6a045b4699650069fd2c71730066d11d75cbaec281d96266fbadce3c5649c06038c80a0857776e61ff97698f4c5825fb608f8762270037bd86c92c111099e4c5b1abb9ba038af757247f3ff1ad3821ceb3ac8d773ec3710af9be9dd43ea7a2317420f0d20"
+	hash := common.HexToHash("0x45868b294fa7f4fc061bc511150cc31226ba19006810d959ae7c3fbc3ee69387")
+
+	rawdata, err := hex.DecodeString(envelope)
+	checkError(err, t)
+	ethTx, err := DecodeEthTx(rawdataToCid(MEthTx, rawdata), rawdata)
+	checkError(err, t)
+
+	if ethTx.Type() != SetCodeTxType || ethTx.Hash() != hash {
+		t.Fatal("Wrong set code transaction")
+	}
+	if *ethTx.To() != common.HexToAddress("0x70997970c51812dc3a010c7d01b50e0d17dc79c8") {
+		t.Fatal("Wrong Recipient")
+	}
+
+	obj, _, err := ethTx.Resolve([]string{"authorizationList"})
+	checkError(err, t)
+	auths := obj.([]SetCodeAuthorization)
+	if len(auths) != 1 || auths[0].Address != common.HexToAddress("0x63c0c19a282a1b52b07dd5a65b58948a07dae32b") {
+		t.Fatal("Wrong authorization list")
+	}
+
+	reencoded, err := ethTx.typed.encode()
+	checkError(err, t)
+	if !bytes.Equal(reencoded, rawdata) {
+		t.Fatal("Wrong re-encoded envelope")
+	}
+
+	// The same transaction, as the JSON API gives it
+	input := []byte(`{
+		"type": "0x4",
+		"chainId": "0x1",
+		"nonce": "0x5",
+		"maxPriorityFeePerGas": "0x3b9aca00",
+		"maxFeePerGas": "0x4a817c800",
+		"gas": "0x186a0",
+		"to": "0x70997970c51812dc3a010c7d01b50e0d17dc79c8",
+		"value": "0x0",
+		"input": "0x",
+		"accessList": [],
+		"authorizationList": [
+			{
+				"chainId": "0x1",
+				"address": "0x63c0c19a282a1b52b07dd5a65b58948a07dae32b",
+				"nonce": "0x0",
+				"yParity": "0x0",
+				"r": "0x14310d20a056f480c68c15ab8731ed4cf0fe83e4c3b659cb628298312ecc9d6",
+				"s": "0x45b4699650069fd2c71730066d11d75cbaec281d96266fbadce3c5649c06038c"
+			}
+		],
+		"v": "0x0",
+		"yParity": "0x0",
+		"r": "0x857776e61ff97698f4c5825fb608f8762270037bd86c92c111099e4c5b1abb9b",
+		"s": "0x38af757247f3ff1ad3821ceb3ac8d773ec3710af9be9dd43ea7a2317420f0d20",
+		"hash": "0x45868b294fa7f4fc061bc511150cc31226ba19006810d959ae7c3fbc3ee69387"
+	}`)
+	ethTx, err = txFromJSON(input)
+	checkError(err, t)
+	if !bytes.Equal(ethTx.RawData(), rawdata) {
+		t.Fatal("Wrong rawdata from JSON")
+	}
+
+	// An authorization list is required
+	input = bytes.Replace(input, []byte(`"authorizationList"`), []byte(`"authorizations"`), 1)
+	_, err = txFromJSON(input)
+	if err == nil || err.Error() != "missing required fields for typed transaction: [authorizationList]" {
+		t.Fatalf("Expected a missing authorizationList error\r\ngot %v", err)
+	}
+}
+
+func TestTypedTxInBlockBodyRlpParsing(t *testing.T) {
+	typedTxs := prepareTypedTxs()
+
+	var envelopes []interface{}
+	txTrie := newTxTrie()
+	for idx, tt := range typedTxs {
+		rawdata, err := tt.encode()
+		checkError(err, t)
+		// Typed transactions are RLP strings within the body
+		envelopes = append(envelopes, rawdata)
+		txTrie.add(idx, rawdata)
+	}
+
+	header := &types.Header{
+		Difficulty: big.NewInt(1),
+		Number:     big.NewInt(1),
+		GasLimit:   big.NewInt(0),
+		GasUsed:    big.NewInt(0),
+		Time:       big.NewInt(0),
+		UncleHash:  common.HexToHash("0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"),
+		TxHash:     common.BytesToHash(txTrie.rootHash()),
+	}
+	body, err := rlp.EncodeToBytes([]interface{}{header, envelopes, []interface{}{}})
+	checkError(err, t)
+
+	bn, err := FromBlockRLP(bytes.NewReader(body))
+	checkError(err, t)
+
+	if len(bn.Txs) != len(typedTxs) {
+		t.Fatal("Wrong number of parsed txs")
+	}
+	for i, ethTx := range bn.Txs {
+		if ethTx.Type() != typedTxs[i].Type {
+			t.Fatal("Wrong transaction type")
+		}
+	}
+}
+
+func TestTypedTxKnownHashes(t *testing.T) {
+	blocks := []struct {
+		filepath string
+		txRoot   string
+		txs      []struct {
+			typ  uint8
+			hash string
+		}
+	}{
+		{
+			"test_data/eth-block-body-rlp-london",
+			"f7e87be9abfecf479385ad134083e795e79977af1b173fbdc3aba5c526f95075",
+			[]struct {
+				typ  uint8
+				hash string
+			}{
+				{LegacyTxType, "64761dc8d093d1a971d408462aca7cecdc5897d9a34f9fc35470205d2783ed88"},
+				{AccessListTxType, "cdba497aaadc0e9e39a9054abae70e395dacb041d4b8d9f8d1fd3971d3cdb44a"},
+				{DynamicFeeTxType, "bbb0c1e6c4eeaa973445e42798db0fd53d1d23964062e0e8da85e1b602b6a2fb"},
+				{DynamicFeeTxType, "73b541aa3c2635c900f772255385ca9348c681ca7912c80d79b327d65536db3e"},
+			},
+		},
+		{
+			"test_data/eth-block-body-rlp-cancun",
+			"c7bc57760a64ce84e07d0c4067e13a5baba8c932c67b092455649ebd7e643c14",
+			[]struct {
+				typ  uint8
+				hash string
+			}{
+				{LegacyTxType, "785085a096731c1f3585c672adbea1f2c40fbbd58ae6e3778c6c92f40053962f"},
+				{AccessListTxType, "592f25f144103c8d629f0e4f6b97c821597dcf38bac7a77842736c1786dde8a7"},
+				{DynamicFeeTxType, "2d0046dec7c9161df82439048ad0eb4eaf0254b117b8056004f0619c663e25e3"},
+				{DynamicFeeTxType, "d4e64790ade3f67d2228edfd717a8960284d55d02b63109041b5ab99b24b129a"},
+				{BlobTxType, "77fc677324f5e9c5efcc515edbfa5fe8222ba6972273b8e186f93749178ff916"},
+			},
+		},
+	}
+
+	for _, b := range blocks {
+		fi, err := os.Open(b.filepath)
+		checkError(err, t)
+
+		bn, err := FromBlockRLP(fi)
+		fi.Close()
+		checkError(err, t)
+
+		lnk, _, err := bn.Block.ResolveLink([]string{"tx"})
+		checkError(err, t)
+		if !lnk.Cid.Equals(commonHashToCid(MEthTxTrie, common.HexToHash(b.txRoot))) {
+			t.Fatalf("Wrong transactions root for %s", b.filepath)
+		}
+
+		if len(bn.Txs) != len(b.txs) {
+			t.Fatalf("Wrong number of parsed txs for %s", b.filepath)
+		}
+		for i, ethTx := range bn.Txs {
+			if ethTx.Type() != b.txs[i].typ {
+				t.Fatalf("Wrong type of tx %d in %s", i, b.filepath)
+			}
+			if ethTx.Hash() != common.HexToHash(b.txs[i].hash) {
+				t.Fatalf("Wrong hash of tx %d in %s", i, b.filepath)
+			}
+			if !ethTx.Cid().Equals(commonHashToCid(MEthTx, common.HexToHash(b.txs[i].hash))) {
+				t.Fatalf("Wrong cid of tx %d in %s", i, b.filepath)
+			}
+			if !ethTx.Protected() || ethTx.ChainId().Int64() != 1 {
+				t.Fatalf("Tx %d in %s should be protected for chain 1", i, b.filepath)
+			}
+
+			// Only the legacy transaction embeds a types.Transaction
+			if (ethTx.Transaction == nil) != (ethTx.Type() != LegacyTxType) {
+				t.Fatalf("Wrong embedded transaction for tx %d in %s", i, b.filepath)
+			}
+		}
+
+		// The fourth transaction creates a contract
+		if bn.Txs[3].To() != nil {
+			t.Fatalf("Expected a contract creation in %s", b.filepath)
+		}
+		obj, _, err := bn.Txs[3].Resolve([]string{"toAddress"})
+		checkError(err, t)
+		if obj.(*common.Address) != nil {
+			t.Fatal("Expected no recipient")
+		}
+		if len(bn.Txs[3].Data()) == 0 {
+			t.Fatal("Expected the init code as input")
+		}
+	}
+}
+
 /*
   AUXILIARS
 */
@@ -317,10 +700,10 @@ func prepareParsedTxs(t *testing.T) []*EthTx {
 	fi, err := os.Open("test_data/eth-block-body-rlp-999999")
 	checkError(err, t)
 
-	_, output, _, err := FromBlockRLP(fi)
+	bn, err := FromBlockRLP(fi)
 	checkError(err, t)
 
-	return output
+	return bn.Txs
 }
 
 func testTx05Fields(ethTx *EthTx, t *testing.T) {
@@ -386,3 +769,61 @@ func testTx10Fields(ethTx *EthTx, t *testing.T) {
 		t.Fatal("Wrong Gas Price")
 	}
 }
+
+// prepareTypedTxs returns an access list, a dynamic fee, a blob and
+// a set code transaction, sharing their common fields.
+func prepareTypedTxs() []*typedTx {
+	to := common.HexToAddress("0x32be343b94f860124dc4fee278fdcbd38c102d88")
+	accessList := []AccessTuple{
+		{
+			Address:     to,
+			StorageKeys: []common.Hash{common.HexToHash("0x01")},
+		},
+	}
+
+	var out []*typedTx
+	for _, typ := range []uint8{AccessListTxType, DynamicFeeTxType, BlobTxType, SetCodeTxType} {
+		tt := &typedTx{
+			Type:       typ,
+			ChainID:    big.NewInt(1),
+			Nonce:      7,
+			Gas:        big.NewInt(21000),
+			To:         &to,
+			Value:      big.NewInt(1),
+			Data:       []byte{},
+			AccessList: []AccessTuple{},
+			V:          big.NewInt(1),
+			R:          big.NewInt(2),
+			S:          big.NewInt(3),
+		}
+		switch typ {
+		case AccessListTxType:
+			tt.GasPrice = big.NewInt(20000000000)
+			tt.AccessList = accessList
+		case SetCodeTxType:
+			tt.AuthList = []SetCodeAuthorization{
+				{
+					ChainID: big.NewInt(1),
+					Address: common.HexToAddress("0x63c0c19a282a1b52b07dd5a65b58948a07dae32b"),
+					Nonce:   0,
+					V:       1,
+					R:       big.NewInt(4),
+					S:       big.NewInt(5),
+				},
+			}
+			tt.GasTipCap = big.NewInt(1000000000)
+			tt.GasFeeCap = big.NewInt(30000000000)
+		case BlobTxType:
+			tt.BlobFeeCap = big.NewInt(1)
+			tt.BlobHashes = []common.Hash{
+				common.HexToHash("0x01a915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8"),
+			}
+			fallthrough
+		case DynamicFeeTxType:
+			tt.GasTipCap = big.NewInt(1000000000)
+			tt.GasFeeCap = big.NewInt(30000000000)
+		}
+		out = append(out, tt)
+	}
+	return out
+}
@@ -5,9 +5,6 @@ import (
 
 	cid "github.com/ipfs/go-cid"
 	node "github.com/ipfs/go-ipld-format"
-
-	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/rlp"
 )
 
 // EthTxTrie (eth-tx-trie codec 0x92) represents
@@ -44,15 +41,15 @@ func DecodeEthTxTrie(c *cid.Cid, b []byte) (*EthTxTrie, error) {
 // decodeEthTxTrieLeaf parses a eth-tx-trie leaf
 //from decoded RLP elements
 func decodeEthTxTrieLeaf(i []interface{}) ([]interface{}, error) {
-	var t types.Transaction
-	err := rlp.DecodeBytes(i[1].([]byte), &t)
+	t, typed, err := decodeTx(i[1].([]byte))
 	if err != nil {
 		return nil, err
 	}
 	return []interface{}{
 		i[0].([]byte),
 		&EthTx{
-			Transaction: &t,
+			Transaction: t,
+			typed:       typed,
 			cid:         rawdataToCid(MEthTx, i[1].([]byte)),
 			rawdata:     i[1].([]byte),
 		},
@@ -23,10 +23,10 @@ func TestTxTriesInBlockBodyJSONParsing(t *testing.T) {
 	fi, err := os.Open("test_data/eth-block-body-json-4139497")
 	checkError(err, t)
 
-	_, _, output, err := FromBlockJSON(fi)
+	bn, err := FromBlockJSON(fi)
 	checkError(err, t)
 
-	if len(output) != 331 {
+	if len(bn.TxTrieNodes) != 331 {
 		t.Fatal("Wrong number of obtained tx trie nodes")
 	}
 }
@@ -489,12 +489,12 @@ func prepareTxTrieMap(t *testing.T) map[string]*EthTxTrie {
 	fi, err := os.Open("test_data/eth-block-body-json-4139497")
 	checkError(err, t)
 
-	_, _, txTrieNodes, err := FromBlockJSON(fi)
+	bn, err := FromBlockJSON(fi)
 	checkError(err, t)
 
 	out := make(map[string]*EthTxTrie)
 
-	for _, txTrieNode := range txTrieNodes {
+	for _, txTrieNode := range bn.TxTrieNodes {
 		decodedNode, err := DecodeEthTxTrie(txTrieNode.Cid(), txTrieNode.RawData())
 		checkError(err, t)
 
@@ -0,0 +1,214 @@
+package ipldeth
+
+import (
+	"encoding/json"
+	"fmt"
+
+	cid "github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+
+	common "github.com/ethereum/go-ethereum/common"
+	hexutil "github.com/ethereum/go-ethereum/common/hexutil"
+	rlp "github.com/ethereum/go-ethereum/rlp"
+)
+
+// EthWithdrawal (eth-withdrawal, codec 0x9c), represents a validator
+// withdrawal from the beacon chain, as carried by block bodies since Shanghai.
+type EthWithdrawal struct {
+	*Withdrawal
+
+	cid     *cid.Cid
+	rawdata []byte
+}
+
+// Withdrawal holds the consensus fields of a validator withdrawal.
+// Amount is given in gwei.
+type Withdrawal struct {
+	Index     uint64
+	Validator uint64
+	Address   common.Address
+	Amount    uint64
+}
+
+// Static (compile time) check that EthWithdrawal satisfies the node.Node interface.
+var _ node.Node = (*EthWithdrawal)(nil)
+
+/*
+  INPUT
+*/
+
+// Withdrawals are parsed along with the block body holding them,
+// as their trie is built there. See FromBlockRLP and FromBlockJSON.
+
+// NewWithdrawal computes the cid and rlp-encodes a Withdrawal
+// returning a proper EthWithdrawal node
+func NewWithdrawal(w *Withdrawal) *EthWithdrawal {
+	rawdata := getRLP(w)
+
+	return &EthWithdrawal{
+		Withdrawal: w,
+		cid:        rawdataToCid(MEthWithdrawal, rawdata),
+		rawdata:    rawdata,
+	}
+}
+
+// withdrawalFromJSON takes a withdrawal as given by the JSON API
+// of an ethereum client and returns it as an EthWithdrawal node.
+func withdrawalFromJSON(input []byte) (*EthWithdrawal, error) {
+	var dec struct {
+		Index     *hexutil.Uint64 `json:"index"`
+		Validator *hexutil.Uint64 `json:"validatorIndex"`
+		Address   *common.Address `json:"address"`
+		Amount    *hexutil.Uint64 `json:"amount"`
+	}
+	err := json.Unmarshal(input, &dec)
+	if err != nil {
+		return nil, err
+	}
+
+	if dec.Index == nil || dec.Validator == nil || dec.Address == nil || dec.Amount == nil {
+		return nil, fmt.Errorf("missing required fields for withdrawal")
+	}
+
+	return NewWithdrawal(&Withdrawal{
+		Index:     uint64(*dec.Index),
+		Validator: uint64(*dec.Validator),
+		Address:   *dec.Address,
+		Amount:    uint64(*dec.Amount),
+	}), nil
+}
+
+/*
+  OUTPUT
+*/
+
+// DecodeEthWithdrawal takes a cid and its raw binary data
+// from IPFS and returns an EthWithdrawal object for further processing.
+func DecodeEthWithdrawal(c *cid.Cid, b []byte) (*EthWithdrawal, error) {
+	var w Withdrawal
+	err := rlp.DecodeBytes(b, &w)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EthWithdrawal{
+		Withdrawal: &w,
+		cid:        c,
+		rawdata:    b,
+	}, nil
+}
+
+/*
+  Block INTERFACE
+*/
+
+// RawData returns the binary of the RLP encode of the withdrawal.
+func (w *EthWithdrawal) RawData() []byte {
+	return w.rawdata
+}
+
+// Cid returns the cid of the withdrawal.
+func (w *EthWithdrawal) Cid() *cid.Cid {
+	return w.cid
+}
+
+// String is a helper for output
+func (w *EthWithdrawal) String() string {
+	return fmt.Sprintf("<EthWithdrawal %s>", w.cid)
+}
+
+// Loggable returns in a map the type of IPLD Link.
+func (w *EthWithdrawal) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "eth-withdrawal",
+	}
+}
+
+/*
+  Node INTERFACE
+*/
+
+// Resolve resolves a path through this node, stopping at any link boundary
+// and returning the object found as well as the remaining path to traverse
+func (w *EthWithdrawal) Resolve(p []string) (interface{}, []string, error) {
+	if len(p) == 0 {
+		return w, nil, nil
+	}
+
+	if len(p) > 1 {
+		return nil, nil, fmt.Errorf("unexpected path elements past %s", p[0])
+	}
+
+	switch p[0] {
+	case "address":
+		return w.Address, nil, nil
+	case "amount":
+		return w.Amount, nil, nil
+	case "index":
+		return w.Index, nil, nil
+	case "validatorIndex":
+		return w.Validator, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("no such link")
+	}
+}
+
+// Tree lists all paths within the object under 'path', and up to the given depth.
+// To list the entire object (similar to `find .`) pass "" and -1
+func (w *EthWithdrawal) Tree(p string, depth int) []string {
+	if p != "" || depth == 0 {
+		return nil
+	}
+
+	return []string{"address", "amount", "index", "validatorIndex"}
+}
+
+// ResolveLink is a helper function that calls resolve and asserts the
+// output is a link
+func (w *EthWithdrawal) ResolveLink(p []string) (*node.Link, []string, error) {
+	obj, rest, err := w.Resolve(p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if lnk, ok := obj.(*node.Link); ok {
+		return lnk, rest, nil
+	}
+
+	return nil, nil, fmt.Errorf("resolved item was not a link")
+}
+
+// Copy will go away. It is here to comply with the interface.
+func (w *EthWithdrawal) Copy() node.Node {
+	panic("dont use this yet")
+}
+
+// Links is a helper function that returns all links within this object
+func (w *EthWithdrawal) Links() []*node.Link {
+	return nil
+}
+
+// Stat will go away. It is here to comply with the interface.
+func (w *EthWithdrawal) Stat() (*node.NodeStat, error) {
+	return &node.NodeStat{}, nil
+}
+
+// Size will go away. It is here to comply with the interface.
+func (w *EthWithdrawal) Size() (uint64, error) {
+	return uint64(len(w.rawdata)), nil
+}
+
+/*
+  EthWithdrawal functions
+*/
+
+// MarshalJSON processes the withdrawal into readable JSON format.
+func (w *EthWithdrawal) MarshalJSON() ([]byte, error) {
+	out := map[string]interface{}{
+		"address":        w.Address,
+		"amount":         w.Amount,
+		"index":          w.Index,
+		"validatorIndex": w.Validator,
+	}
+	return json.Marshal(out)
+}
@@ -0,0 +1,183 @@
+package ipldeth
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	block "github.com/ipfs/go-block-format"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+  INPUT
+*/
+
+func TestWithdrawalFromJSON(t *testing.T) {
+	w, err := withdrawalFromJSON([]byte(`{
+		"index": "0x2a",
+		"validatorIndex": "0x3e8",
+		"address": "0x32be343b94f860124dc4fee278fdcbd38c102d88",
+		"amount": "0x3b9aca00"
+	}`))
+	checkError(err, t)
+
+	if !w.Cid().Equals(prepareEthWithdrawal().Cid()) {
+		t.Fatal("Wrong cid")
+	}
+	testWithdrawalFields(w, t)
+}
+
+func TestWithdrawalFromJSONMissingFields(t *testing.T) {
+	_, err := withdrawalFromJSON([]byte(`{"index": "0x2a", "validatorIndex": "0x3e8"}`))
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if err.Error() != "missing required fields for withdrawal" {
+		t.Fatal("Wrong error")
+	}
+}
+
+/*
+  OUTPUT
+*/
+
+func TestDecodeWithdrawal(t *testing.T) {
+	w := prepareEthWithdrawal()
+
+	// Just to clarify: This `block` is an IPFS block
+	storedWithdrawal, err := block.NewBlockWithCid(w.RawData(), w.Cid())
+	checkError(err, t)
+
+	ethWithdrawal, err := DecodeEthWithdrawal(storedWithdrawal.Cid(), storedWithdrawal.RawData())
+	checkError(err, t)
+
+	testWithdrawalFields(ethWithdrawal, t)
+}
+
+/*
+  Block INTERFACE
+*/
+
+func TestEthWithdrawalLoggable(t *testing.T) {
+	w := prepareEthWithdrawal()
+
+	l := w.Loggable()
+	if _, ok := l["type"]; !ok {
+		t.Fatal("Loggable map expected the field 'type'")
+	}
+
+	if l["type"] != "eth-withdrawal" {
+		t.Fatal("Wrong Loggable 'type' value")
+	}
+}
+
+/*
+  Node INTERFACE
+*/
+
+func TestEthWithdrawalResolve(t *testing.T) {
+	w := prepareEthWithdrawal()
+
+	// Empty path
+	obj, rest, err := w.Resolve([]string{})
+	checkError(err, t)
+	if obj.(*EthWithdrawal) != w {
+		t.Fatal("Should have returned the same eth-withdrawal object")
+	}
+	if rest != nil {
+		t.Fatal("rest should be nil")
+	}
+
+	// len(p) > 1
+	_, _, err = w.Resolve([]string{"amount", "extra"})
+	if err == nil || err.Error() != "unexpected path elements past amount" {
+		t.Fatal("wrong error")
+	}
+
+	_, _, err = w.Resolve([]string{"validator"})
+	if err == nil || err.Error() != "no such link" {
+		t.Fatal("wrong error")
+	}
+
+	testCases := map[string][]string{
+		"address":        []string{"%x", "32be343b94f860124dc4fee278fdcbd38c102d88"},
+		"amount":         []string{"%d", "1000000000"},
+		"index":          []string{"%d", "42"},
+		"validatorIndex": []string{"%d", "1000"},
+	}
+	for field, value := range testCases {
+		obj, _, err := w.Resolve([]string{field})
+		checkError(err, t)
+
+		if fmt.Sprintf(value[0], obj) != value[1] {
+			t.Fatalf("Wrong %v", field)
+		}
+	}
+}
+
+func TestEthWithdrawalTree(t *testing.T) {
+	w := prepareEthWithdrawal()
+
+	if w.Tree("non-empty-string", 1) != nil {
+		t.Fatal("Expected nil to be returned")
+	}
+	if w.Tree("", 0) != nil {
+		t.Fatal("Expected nil to be returned")
+	}
+
+	if len(w.Tree("", 1)) != 4 {
+		t.Fatal("Wrong number of elements")
+	}
+}
+
+func TestEthWithdrawalJSONMarshal(t *testing.T) {
+	w := prepareEthWithdrawal()
+
+	jsonOutput, err := w.MarshalJSON()
+	checkError(err, t)
+
+	var data map[string]interface{}
+	err = json.Unmarshal(jsonOutput, &data)
+	checkError(err, t)
+
+	if data["address"] != "0x32be343b94f860124dc4fee278fdcbd38c102d88" {
+		t.Fatal("Wrong address")
+	}
+	if parseFloat(data["amount"]) != "1000000000" {
+		t.Fatal("Wrong amount")
+	}
+	if parseFloat(data["validatorIndex"]) != "1000" {
+		t.Fatal("Wrong validator index")
+	}
+}
+
+/*
+  AUXILIARS
+*/
+
+// prepareEthWithdrawal returns the withdrawal of 1 ETH to a validator.
+func prepareEthWithdrawal() *EthWithdrawal {
+	return NewWithdrawal(&Withdrawal{
+		Index:     42,
+		Validator: 1000,
+		Address:   common.HexToAddress("0x32be343b94f860124dc4fee278fdcbd38c102d88"),
+		Amount:    1000000000,
+	})
+}
+
+func testWithdrawalFields(w *EthWithdrawal, t *testing.T) {
+	if w.Index != 42 {
+		t.Fatal("Wrong Index")
+	}
+	if w.Validator != 1000 {
+		t.Fatal("Wrong Validator")
+	}
+	if fmt.Sprintf("%x", w.Address) != "32be343b94f860124dc4fee278fdcbd38c102d88" {
+		t.Fatal("Wrong Address")
+	}
+	if w.Amount != 1000000000 {
+		t.Fatal("Wrong Amount")
+	}
+}
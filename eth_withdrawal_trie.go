@@ -0,0 +1,134 @@
+package ipldeth
+
+import (
+	"bytes"
+	"fmt"
+
+	cid "github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+)
+
+// EthWithdrawalTrie (eth-withdrawal-trie codec 0x9b) represents
+// a node from the withdrawal trie in ethereum.
+type EthWithdrawalTrie struct {
+	*TrieNode
+}
+
+// Static (compile time) check that EthWithdrawalTrie satisfies the node.Node interface.
+var _ node.Node = (*EthWithdrawalTrie)(nil)
+
+/*
+ INPUT
+*/
+
+// As with the transaction trie, the withdrawal trie is
+// created on block body parsing time.
+
+// processWithdrawals will take the found withdrawals in a parsed block body
+// to return IPLD node slices for eth-withdrawal and eth-withdrawal-trie
+func processWithdrawals(ws []*EthWithdrawal, expectedWithdrawalRoot []byte) ([]*EthWithdrawal, []*EthWithdrawalTrie, error) {
+	withdrawalTrie := newWdTrie()
+
+	for idx, w := range ws {
+		withdrawalTrie.add(idx, w.RawData())
+	}
+
+	if !bytes.Equal(withdrawalTrie.rootHash(), expectedWithdrawalRoot) {
+		return nil, nil, fmt.Errorf("wrong withdrawal hash computed")
+	}
+
+	ethWithdrawalTrieNodes := withdrawalTrie.getNodes()
+
+	return ws, ethWithdrawalTrieNodes, nil
+}
+
+/*
+  OUTPUT
+*/
+
+// DecodeEthWithdrawalTrie returns an EthWithdrawalTrie object from its cid and rawdata.
+func DecodeEthWithdrawalTrie(c *cid.Cid, b []byte) (*EthWithdrawalTrie, error) {
+	tn, err := decodeTrieNode(c, b, decodeEthWithdrawalTrieLeaf)
+	if err != nil {
+		return nil, err
+	}
+	return &EthWithdrawalTrie{TrieNode: tn}, nil
+}
+
+// decodeEthWithdrawalTrieLeaf parses a eth-withdrawal-trie leaf
+// from decoded RLP elements
+func decodeEthWithdrawalTrieLeaf(i []interface{}) ([]interface{}, error) {
+	w, err := DecodeEthWithdrawal(rawdataToCid(MEthWithdrawal, i[1].([]byte)), i[1].([]byte))
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{
+		i[0].([]byte),
+		w,
+	}, nil
+}
+
+/*
+  Block INTERFACE
+*/
+
+// RawData returns the binary of the RLP encode of the withdrawal trie node.
+func (t *EthWithdrawalTrie) RawData() []byte {
+	return t.rawdata
+}
+
+// Cid returns the cid of the withdrawal trie node.
+func (t *EthWithdrawalTrie) Cid() *cid.Cid {
+	return t.cid
+}
+
+// String is a helper for output
+func (t *EthWithdrawalTrie) String() string {
+	return fmt.Sprintf("<EthereumWithdrawalTrie %s>", t.cid)
+}
+
+// Loggable returns in a map the type of IPLD Link.
+func (t *EthWithdrawalTrie) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "eth-withdrawal-trie",
+	}
+}
+
+/*
+  EthWithdrawalTrie functions
+*/
+
+// wdTrie wraps a localTrie for use on the withdrawal trie.
+type wdTrie struct {
+	*localTrie
+}
+
+// newWdTrie initializes and returns a wdTrie.
+func newWdTrie() *wdTrie {
+	return &wdTrie{
+		localTrie: newLocalTrie(),
+	}
+}
+
+// getNodes invokes the localTrie, which computes the root hash of the
+// withdrawal trie and returns its database keys, to return a slice
+// of EthWithdrawalTrie nodes.
+func (wt *wdTrie) getNodes() []*EthWithdrawalTrie {
+	keys := wt.getKeys()
+	var out []*EthWithdrawalTrie
+
+	for _, k := range keys {
+		rawdata, err := wt.db.Get(k)
+		if err != nil {
+			panic(err)
+		}
+
+		tn := &TrieNode{
+			cid:     rawdataToCid(MEthWithdrawalTrie, rawdata),
+			rawdata: rawdata,
+		}
+		out = append(out, &EthWithdrawalTrie{TrieNode: tn})
+	}
+
+	return out
+}
@@ -0,0 +1,182 @@
+package ipldeth
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+/*
+  INPUT
+*/
+
+func TestWithdrawalsInBlockBodyRlpParsing(t *testing.T) {
+	ws := prepareWithdrawals()
+	body := prepareWithdrawalsBlockBody(ws, t)
+
+	bn, err := FromBlockRLP(bytes.NewReader(body))
+	checkError(err, t)
+
+	if len(bn.Withdrawals) != len(ws) {
+		t.Fatal("Wrong number of parsed withdrawals")
+	}
+	for i, w := range bn.Withdrawals {
+		if !w.Cid().Equals(ws[i].Cid()) {
+			t.Fatal("Wrong withdrawal cid")
+		}
+	}
+
+	// The withdrawalsRoot link of the block header must land on one of our nodes
+	lnk, _, err := bn.Block.ResolveLink([]string{"withdrawalsRoot"})
+	checkError(err, t)
+
+	var found bool
+	for _, wtn := range bn.WithdrawalTrieNodes {
+		if wtn.Cid().Equals(lnk.Cid) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("Withdrawal trie root not found among the returned nodes")
+	}
+}
+
+func TestWithdrawalsInPreShanghaiBlockBody(t *testing.T) {
+	fi, err := os.Open("test_data/eth-block-body-rlp-999999")
+	checkError(err, t)
+
+	bn, err := FromBlockRLP(fi)
+	checkError(err, t)
+
+	if bn.Withdrawals != nil || bn.WithdrawalTrieNodes != nil {
+		t.Fatal("No withdrawals should have been gotten from here")
+	}
+}
+
+func TestProcessWithdrawalsWrongRoot(t *testing.T) {
+	_, _, err := processWithdrawals(prepareWithdrawals(), common.HexToHash("0x01").Bytes())
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if err.Error() != "wrong withdrawal hash computed" {
+		t.Fatal("Wrong error")
+	}
+}
+
+/*
+  OUTPUT
+*/
+
+func TestWithdrawalTrieDecodeLeaf(t *testing.T) {
+	// A single withdrawal makes the root of the trie a leaf
+	ws := prepareWithdrawals()[:1]
+	wt := newWdTrie()
+	wt.add(0, ws[0].RawData())
+
+	_, withdrawalTrieNodes, err := processWithdrawals(ws, wt.rootHash())
+	checkError(err, t)
+
+	if len(withdrawalTrieNodes) != 1 {
+		t.Fatal("Expected a single withdrawal trie node")
+	}
+
+	ethWithdrawalTrie, err := DecodeEthWithdrawalTrie(withdrawalTrieNodes[0].Cid(), withdrawalTrieNodes[0].RawData())
+	checkError(err, t)
+
+	if ethWithdrawalTrie.nodeKind != "leaf" {
+		t.Fatal("Wrong nodeKind")
+	}
+	if _, ok := ethWithdrawalTrie.elements[1].(*EthWithdrawal); !ok {
+		t.Fatal("Wrong Type. Element should be a withdrawal")
+	}
+
+	// Key of the first withdrawal is rlp(0) = 0x80
+	obj, rest, err := ethWithdrawalTrie.Resolve([]string{"8", "0", "validatorIndex"})
+	checkError(err, t)
+	if rest != nil {
+		t.Fatal("rest should be nil")
+	}
+	if fmt.Sprintf("%v", obj) != "1000" {
+		t.Fatal("Wrong validator index")
+	}
+}
+
+/*
+  Block INTERFACE
+*/
+
+func TestEthWithdrawalTrieLoggable(t *testing.T) {
+	ws := prepareWithdrawals()
+	body := prepareWithdrawalsBlockBody(ws, t)
+
+	bn, err := FromBlockRLP(bytes.NewReader(body))
+	checkError(err, t)
+
+	l := bn.WithdrawalTrieNodes[0].Loggable()
+	if _, ok := l["type"]; !ok {
+		t.Fatal("Loggable map expected the field 'type'")
+	}
+
+	if l["type"] != "eth-withdrawal-trie" {
+		t.Fatal("Wrong Loggable 'type' value")
+	}
+}
+
+/*
+  AUXILIARS
+*/
+
+// prepareWithdrawals returns three withdrawals of sequential validators.
+func prepareWithdrawals() []*EthWithdrawal {
+	var ws []*EthWithdrawal
+	for i := uint64(0); i < 3; i++ {
+		ws = append(ws, NewWithdrawal(&Withdrawal{
+			Index:     42 + i,
+			Validator: 1000 + i,
+			Address:   common.HexToAddress("0x32be343b94f860124dc4fee278fdcbd38c102d88"),
+			Amount:    1000000000,
+		}))
+	}
+	return ws
+}
+
+// prepareWithdrawalsBlockBody returns the RLP of a Shanghai block body
+// without transactions, carrying the given withdrawals.
+func prepareWithdrawalsBlockBody(ws []*EthWithdrawal, t *testing.T) []byte {
+	wt := newWdTrie()
+	var raws []rlp.RawValue
+	for idx, w := range ws {
+		wt.add(idx, w.RawData())
+		raws = append(raws, w.RawData())
+	}
+	withdrawalsHash := common.BytesToHash(wt.rootHash())
+
+	ethBlock := newEthBlock(&types.Header{
+		Difficulty: big.NewInt(0),
+		Number:     big.NewInt(17034870),
+		GasLimit:   big.NewInt(30000000),
+		GasUsed:    big.NewInt(0),
+		Time:       big.NewInt(1681338455),
+		UncleHash:  common.HexToHash("0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"),
+		TxHash:     common.HexToHash("0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421"),
+	}, &EthHeaderExt{
+		BaseFee:         big.NewInt(1000000000),
+		WithdrawalsHash: &withdrawalsHash,
+	})
+
+	body, err := rlp.EncodeToBytes([]interface{}{
+		rlp.RawValue(ethBlock.RawData()),
+		[]interface{}{},
+		[]interface{}{},
+		raws,
+	})
+	checkError(err, t)
+
+	return body
+}
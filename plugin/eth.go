@@ -46,49 +46,74 @@ func (ep *EthereumPlugin) Version() string {
 func (ep *EthereumPlugin) RegisterInputEncParsers(iec coredag.InputEncParsers) error {
 	iec.AddParser("raw", "eth-block", EthBlockRawInputParser)
 	iec.AddParser("json", "eth-block", EthBlockJSONInputParser)
+	iec.AddParser("raw-json", "eth-block", EthBlockRawJSONInputParser)
 	iec.AddParser("raw", "eth-state-trie", EthStateTrieRawInputParser)
+	iec.AddParser("json", "eth-state-trie", EthStateTrieJSONInputParser)
 	iec.AddParser("raw", "eth-storage-trie", EthStorageTrieRawInputParser)
+	iec.AddParser("raw", "eth-tx-receipt", EthTxReceiptRawInputParser)
+	iec.AddParser("raw-json", "eth-tx-receipt", EthTxReceiptRawJSONInputParser)
+	iec.AddParser("raw", "eth-code", EthCodeRawInputParser)
 	return nil
 }
 
 // EthBlockRawInputParser will take the piped input, which could an RLP binary
 // of either an RLP block header, or an RLP body (header + uncles + txs)
-// to return an IPLD Node slice.
+// to return an IPLD Node slice. Withdrawals, if any, are added as well.
 func EthBlockRawInputParser(r io.Reader, mhtype uint64, mhLen int) ([]node.Node, error) {
-	blockHeader, txs, txTrieNodes, err := eth.FromBlockRLP(r)
+	bn, err := eth.FromBlockRLP(r)
 	if err != nil {
 		return nil, err
 	}
 
-	var out []node.Node
-	out = append(out, blockHeader)
-	for _, tx := range txs {
-		out = append(out, tx)
-	}
-	for _, ttn := range txTrieNodes {
-		out = append(out, ttn)
-	}
-	return out, nil
+	return blockNodes(bn), nil
 }
 
 // EthBlockJSONInputParser will take the piped input, a JSON representation of
 // a block header or body (header + uncles + txs), to return an IPLD Node slice.
 func EthBlockJSONInputParser(r io.Reader, mhtype uint64, mhLen int) ([]node.Node, error) {
-	blockHeader, txs, txTrieNodes, err := eth.FromBlockJSON(r)
+	bn, err := eth.FromBlockJSON(r)
 	if err != nil {
+		return nil, err
+	}
+
+	return blockNodes(bn), nil
+}
 
+// EthBlockRawJSONInputParser will take the piped input, a JSON response of
+// debug_getRawHeader or debug_getRawBlock, holding the RLP of a block header
+// or body in hex, to return an IPLD Node slice, as EthBlockRawInputParser.
+func EthBlockRawJSONInputParser(r io.Reader, mhtype uint64, mhLen int) ([]node.Node, error) {
+	bn, err := eth.FromRawBlockJSON(r)
+	if err != nil {
 		return nil, err
 	}
 
+	return blockNodes(bn), nil
+}
+
+// blockNodes lists the nodes of a block, as returned by the eth-block parsers.
+func blockNodes(bn *eth.BlockNodes) []node.Node {
 	var out []node.Node
-	out = append(out, blockHeader)
-	for _, tx := range txs {
+	out = append(out, bn.Block)
+	for _, tx := range bn.Txs {
 		out = append(out, tx)
 	}
-	for _, ttn := range txTrieNodes {
+	for _, ttn := range bn.TxTrieNodes {
 		out = append(out, ttn)
 	}
-	return out, nil
+	for _, u := range bn.Uncles {
+		out = append(out, u)
+	}
+	if bn.UncleList != nil {
+		out = append(out, bn.UncleList)
+	}
+	for _, w := range bn.Withdrawals {
+		out = append(out, w)
+	}
+	for _, wtn := range bn.WithdrawalTrieNodes {
+		out = append(out, wtn)
+	}
+	return out
 }
 
 // EthStateTrieRawInputParser will take the piped input, which is an RLP binary
@@ -102,6 +127,29 @@ func EthStateTrieRawInputParser(r io.Reader, mhtype uint64, mhLen int) ([]node.N
 	return []node.Node{stateTrieNode}, nil
 }
 
+// EthStateTrieJSONInputParser will take the piped input, a JSON response
+// of eth_getProof, to return an IPLD Node slice with the nodes of the
+// account proof, the account itself and the nodes of its storage proofs.
+// The proof is checked against its own root, see eth.FromProofJSON.
+func EthStateTrieJSONInputParser(r io.Reader, mhtype uint64, mhLen int) ([]node.Node, error) {
+	account, stateTrieNodes, storageTrieNodes, err := eth.FromProofJSON(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []node.Node
+	for _, stn := range stateTrieNodes {
+		out = append(out, stn)
+	}
+	if account != nil {
+		out = append(out, account)
+	}
+	for _, stn := range storageTrieNodes {
+		out = append(out, stn)
+	}
+	return out, nil
+}
+
 // EthStorageTrieRawInputParser will take the piped input, which is an RLP binary
 // representation of a storage trie node, to return an IPLD Node.
 func EthStorageTrieRawInputParser(r io.Reader, mhtype uint64, mhLen int) ([]node.Node, error) {
@@ -113,17 +161,82 @@ func EthStorageTrieRawInputParser(r io.Reader, mhtype uint64, mhLen int) ([]node
 	return []node.Node{storageTrieNode}, nil
 }
 
+// EthTxReceiptRawInputParser will take the piped input, which is an RLP binary
+// representation of a transaction receipt, to return an IPLD Node slice
+// with the receipt, its logs and their trie.
+func EthTxReceiptRawInputParser(r io.Reader, mhtype uint64, mhLen int) ([]node.Node, error) {
+	receipt, logs, logTrieNodes, err := eth.FromTxReceiptRLP(r)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []node.Node{receipt}
+	for _, l := range logs {
+		out = append(out, l)
+	}
+	for _, ltn := range logTrieNodes {
+		out = append(out, ltn)
+	}
+	return out, nil
+}
+
+// EthTxReceiptRawJSONInputParser will take the piped input, a JSON document
+// {"block": ..., "receipts": ...} holding the responses of debug_getRawBlock,
+// or debug_getRawHeader, and debug_getRawReceipts for the same block, to return
+// an IPLD Node slice with the receipts, checked against the header, their trie,
+// their logs and log tries. The block itself is left out, see
+// EthBlockRawJSONInputParser.
+func EthTxReceiptRawJSONInputParser(r io.Reader, mhtype uint64, mhLen int) ([]node.Node, error) {
+	bn, err := eth.FromRawBlockReceiptsJSON(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []node.Node
+	for _, rct := range bn.Receipts {
+		out = append(out, rct)
+	}
+	for _, rtn := range bn.ReceiptTrieNodes {
+		out = append(out, rtn)
+	}
+	for _, l := range bn.Logs {
+		out = append(out, l)
+	}
+	for _, ltn := range bn.LogTrieNodes {
+		out = append(out, ltn)
+	}
+	return out, nil
+}
+
+// EthCodeRawInputParser will take the piped input, which is the EVM
+// bytecode of a contract, to return an IPLD Node.
+func EthCodeRawInputParser(r io.Reader, mhtype uint64, mhLen int) ([]node.Node, error) {
+	code, err := eth.FromCode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return []node.Node{code}, nil
+}
+
 /*
   OUTPUT BLOCK DECODERS
 */
 
 // RegisterBlockDecoders enters which functions will help us to decode the requested IPLD blocks.
 func (ep *EthereumPlugin) RegisterBlockDecoders(dec node.BlockDecoder) error {
-	dec.Register(eth.MEthBlock, EthBlockParser)             // eth-block
-	dec.Register(eth.MEthTx, EthTxParser)                   // eth-tx
-	dec.Register(eth.MEthTxTrie, EthTxTrieParser)           // eth-tx-trie
-	dec.Register(eth.MEthStateTrie, EthStateTrieParser)     // eth-state-trie
-	dec.Register(eth.MEthStorageTrie, EthStorageTrieParser) // eth-storage-trie
+	dec.Register(eth.MEthBlock, EthBlockParser)                   // eth-block
+	dec.Register(eth.MEthBlockList, EthBlockListParser)           // eth-block-list
+	dec.Register(eth.MEthTx, EthTxParser)                         // eth-tx
+	dec.Register(eth.MEthTxTrie, EthTxTrieParser)                 // eth-tx-trie
+	dec.Register(eth.MEthTxReceiptTrie, EthTxReceiptTrieParser)   // eth-tx-receipt-trie
+	dec.Register(eth.MEthTxReceipt, EthTxReceiptParser)           // eth-tx-receipt
+	dec.Register(eth.MEthStateTrie, EthStateTrieParser)           // eth-state-trie
+	dec.Register(eth.MEthStorageTrie, EthStorageTrieParser)       // eth-storage-trie
+	dec.Register(eth.MEthWithdrawalTrie, EthWithdrawalTrieParser) // eth-withdrawal-trie
+	dec.Register(eth.MEthWithdrawal, EthWithdrawalParser)         // eth-withdrawal
+	dec.Register(eth.MEthLogTrie, EthLogTrieParser)               // eth-receipt-log-trie
+	dec.Register(eth.MEthLog, EthLogParser)                       // eth-receipt-log
 	return nil
 }
 
@@ -132,6 +245,12 @@ func EthBlockParser(b block.Block) (node.Node, error) {
 	return eth.DecodeEthBlock(b.Cid(), b.RawData())
 }
 
+// EthBlockListParser takes care of the eth-block-list IPLD objects
+// (lists of ethereum block headers, i.e. ommers)
+func EthBlockListParser(b block.Block) (node.Node, error) {
+	return eth.DecodeEthBlockList(b.Cid(), b.RawData())
+}
+
 // EthTxParser takes care of the eth-tx IPLD objects (ethereum transactions)
 func EthTxParser(b block.Block) (node.Node, error) {
 	return eth.DecodeEthTx(b.Cid(), b.RawData())
@@ -143,6 +262,18 @@ func EthTxTrieParser(b block.Block) (node.Node, error) {
 	return eth.DecodeEthTxTrie(b.Cid(), b.RawData())
 }
 
+// EthTxReceiptTrieParser takes care of the eth-tx-receipt-trie IPLD objects
+// (ethereum transaction receipts as patricia merkle tree leaves)
+func EthTxReceiptTrieParser(b block.Block) (node.Node, error) {
+	return eth.DecodeEthTxReceiptTrie(b.Cid(), b.RawData())
+}
+
+// EthTxReceiptParser takes care of the eth-tx-receipt IPLD objects
+// (ethereum transaction receipts)
+func EthTxReceiptParser(b block.Block) (node.Node, error) {
+	return eth.DecodeEthTxReceipt(b.Cid(), b.RawData())
+}
+
 // EthStateTrieParser takes care of the eth-state-trie IPLD objects
 // (ethereum patricia merkle tree state nodes)
 func EthStateTrieParser(b block.Block) (node.Node, error) {
@@ -154,3 +285,27 @@ func EthStateTrieParser(b block.Block) (node.Node, error) {
 func EthStorageTrieParser(b block.Block) (node.Node, error) {
 	return eth.DecodeEthStorageTrie(b.Cid(), b.RawData())
 }
+
+// EthWithdrawalTrieParser takes care of the eth-withdrawal-trie IPLD objects
+// (ethereum withdrawals as patricia merkle tree leaves)
+func EthWithdrawalTrieParser(b block.Block) (node.Node, error) {
+	return eth.DecodeEthWithdrawalTrie(b.Cid(), b.RawData())
+}
+
+// EthWithdrawalParser takes care of the eth-withdrawal IPLD objects
+// (beacon chain validator withdrawals)
+func EthWithdrawalParser(b block.Block) (node.Node, error) {
+	return eth.DecodeEthWithdrawal(b.Cid(), b.RawData())
+}
+
+// EthLogTrieParser takes care of the eth-receipt-log-trie IPLD objects
+// (logs of a receipt as patricia merkle tree leaves)
+func EthLogTrieParser(b block.Block) (node.Node, error) {
+	return eth.DecodeEthLogTrie(b.Cid(), b.RawData())
+}
+
+// EthLogParser takes care of the eth-receipt-log IPLD objects
+// (logs emitted by transactions)
+func EthLogParser(b block.Block) (node.Node, error) {
+	return eth.DecodeEthLog(b.Cid(), b.RawData())
+}
@@ -0,0 +1,239 @@
+package ipldeth
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	cid "github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TrieIterator walks the leaves of a state or storage trie in key order,
+// fetching its nodes from a NodeGetter as it goes.
+//
+//	it, err := NewTrieIterator(ctx, ng, root, nil, nil)
+//	for it.Next() {
+//		account := it.Value().(*EthAccountSnapshot)
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// A node missing from the NodeGetter stops the iteration with a
+// *MissingNodeError. The iterator keeps its position, and the next call
+// to Next tries to fetch the missing node again.
+type TrieIterator struct {
+	ctx         context.Context
+	ng          node.NodeGetter
+	leafDecoder trieNodeLeafDecoder
+
+	// Range of the keys, in nibbles
+	start, end []byte
+
+	stack []*trieIteratorFrame
+	key   []byte
+	value node.Resolver
+	err   error
+}
+
+// trieIteratorFrame is a node on the way to the current leaf.
+// Its trie node is nil until it is fetched.
+type trieIteratorFrame struct {
+	cid  *cid.Cid
+	tn   *TrieNode
+	path []byte // nibbles leading to the node
+	next int    // next child to visit, in branches
+}
+
+// MissingNodeError is returned by TrieIterator when a node of the trie
+// is not found. Path gives the nibbles leading to it.
+type MissingNodeError struct {
+	Cid  *cid.Cid
+	Path []byte
+}
+
+// Error implements the error interface.
+func (e *MissingNodeError) Error() string {
+	var path string
+	for _, n := range e.Path {
+		path += fmt.Sprintf("%x", n)
+	}
+	return fmt.Sprintf("missing trie node %s (path %s)", e.Cid, path)
+}
+
+/*
+  OUTPUT
+*/
+
+// NewTrieIterator returns an iterator over the leaves of the state or
+// storage trie under the given root cid, with their keys in the range
+// [start, end). Keys are the 32 bytes hashes of the trie, and a nil start
+// or end leaves that side of the range open.
+func NewTrieIterator(ctx context.Context, ng node.NodeGetter, root *cid.Cid,
+	start, end []byte) (*TrieIterator, error) {
+	leafDecoder := secureTrieLeafDecoder(root.Type())
+	if leafDecoder == nil {
+		return nil, fmt.Errorf("no trie iterator for codec %x", root.Type())
+	}
+
+	for _, k := range [][]byte{start, end} {
+		if k != nil && len(k) != 32 {
+			return nil, fmt.Errorf("trie keys must be 32 bytes long")
+		}
+	}
+
+	it := &TrieIterator{
+		ctx:         ctx,
+		ng:          ng,
+		leafDecoder: leafDecoder,
+		start:       nibbleToByte(start),
+		end:         nibbleToByte(end),
+	}
+
+	// The empty trie has nothing to walk
+	if !root.Equals(commonHashToCid(root.Type(), types.EmptyRootHash)) {
+		it.stack = []*trieIteratorFrame{{cid: root}}
+	}
+
+	return it, nil
+}
+
+// Next moves the iterator to the next leaf, returning false when there
+// are no more leaves, or when it could not get to the next one.
+// Err tells both cases apart.
+func (it *TrieIterator) Next() bool {
+	if _, ok := it.err.(*MissingNodeError); ok {
+		it.err = nil
+	}
+	if it.err != nil {
+		return false
+	}
+
+	for len(it.stack) != 0 {
+		top := it.stack[len(it.stack)-1]
+
+		if top.tn == nil {
+			tn, err := it.fetch(top)
+			if err != nil {
+				it.err = err
+				return false
+			}
+			top.tn = tn
+		}
+
+		switch top.tn.nodeKind {
+		case "leaf":
+			it.pop()
+			key := append(top.path, top.tn.elements[0].([]byte)...)
+			if it.end != nil && bytes.Compare(key, it.end) >= 0 {
+				it.stack = nil
+				return false
+			}
+			if bytes.Compare(key, it.start) < 0 {
+				continue
+			}
+
+			value, ok := top.tn.elements[1].(node.Resolver)
+			if !ok {
+				it.err = fmt.Errorf("leaf children is not an IPLD node")
+				return false
+			}
+			it.key, it.value = key, value
+			return true
+		case "extension":
+			it.pop()
+			it.push(top.tn.elements[1].(*cid.Cid), top.path, top.tn.elements[0].([]byte)...)
+		case "branch":
+			if top.next == 16 {
+				it.pop()
+				continue
+			}
+			i := top.next
+			top.next++
+
+			if child := top.tn.elements[i]; child != nil {
+				it.push(child.(*cid.Cid), top.path, byte(i))
+			}
+		default:
+			it.err = fmt.Errorf("nodeKind case not implemented")
+			return false
+		}
+	}
+
+	return false
+}
+
+// Key returns the key of the current leaf, a 32 bytes hash.
+func (it *TrieIterator) Key() []byte {
+	return byteFromNibbles(it.key)
+}
+
+// Value returns the value of the current leaf. This is, an
+// *EthAccountSnapshot in the state trie, or an *EthStorageValue
+// in a storage trie.
+func (it *TrieIterator) Value() node.Resolver {
+	return it.value
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *TrieIterator) Err() error {
+	return it.err
+}
+
+/*
+  AUXILIARS
+*/
+
+// fetch gets and decodes the trie node of the given frame.
+func (it *TrieIterator) fetch(f *trieIteratorFrame) (*TrieNode, error) {
+	nd, err := it.ng.Get(it.ctx, f.cid)
+	if err == node.ErrNotFound {
+		return nil, &MissingNodeError{Cid: f.cid, Path: f.path}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeTrieNode(f.cid, nd.RawData(), it.leafDecoder)
+}
+
+// push adds the child under the given path and nibbles to the stack,
+// unless none of its keys are in the range of the iterator.
+func (it *TrieIterator) push(c *cid.Cid, path []byte, nibbles ...byte) {
+	p := make([]byte, 0, len(path)+len(nibbles))
+	p = append(append(p, path...), nibbles...)
+
+	if len(it.start) != 0 && comparePrefix(p, it.start) < 0 {
+		return
+	}
+	if len(it.end) != 0 && comparePrefix(p, it.end) > 0 {
+		return
+	}
+
+	it.stack = append(it.stack, &trieIteratorFrame{cid: c, path: p})
+}
+
+// comparePrefix compares the path with the start of the key as long as it.
+func comparePrefix(path, key []byte) int {
+	if len(path) > len(key) {
+		return bytes.Compare(path[:len(key)], key)
+	}
+	return bytes.Compare(path, key[:len(path)])
+}
+
+// pop removes the last node of the stack.
+func (it *TrieIterator) pop() {
+	it.stack = it.stack[:len(it.stack)-1]
+}
+
+// byteFromNibbles joins every two nibbles into a byte.
+func byteFromNibbles(n []byte) []byte {
+	out := make([]byte, len(n)/2)
+	for i := range out {
+		out[i] = n[2*i]<<4 | n[2*i+1]
+	}
+	return out
+}
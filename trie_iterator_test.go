@@ -0,0 +1,168 @@
+package ipldeth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+/*
+  OUTPUT
+*/
+
+func TestTrieIteratorState(t *testing.T) {
+	ng, root := prepareGenesisNodeGetter(t)
+
+	it, err := NewTrieIterator(context.Background(), ng, root, nil, nil)
+	checkError(err, t)
+
+	// In the order of their hashes: 3ed0..., 4cfa... and cdd3...
+	expected := []string{
+		"0x1000000000000000000000000000000000000001",
+		"0x3282791d6fd713f1e94f4bfd565eaa78b3a0599d",
+		"0x5abfec25f74cd88437631a7731906932776356f9",
+	}
+	var got []*EthAccountSnapshot
+	for it.Next() {
+		got = append(got, it.Value().(*EthAccountSnapshot))
+
+		address := common.HexToAddress(expected[len(got)-1])
+		if fmt.Sprintf("%x", it.Key()) != fmt.Sprintf("%x", crypto.Keccak256(address[:])) {
+			t.Fatalf("Wrong key for account %s", address.Hex())
+		}
+	}
+	checkError(it.Err(), t)
+
+	if len(got) != 3 {
+		t.Fatalf("Wrong number of accounts\r\nexpected %d\r\ngot %d", 3, len(got))
+	}
+	if got[2].Balance.String() != "11901484239480000000000000" {
+		t.Fatal("Wrong account value")
+	}
+}
+
+func TestTrieIteratorStorage(t *testing.T) {
+	ng, _ := prepareGenesisNodeGetter(t)
+
+	root := keccak256ToCid(MEthStorageTrie,
+		common.FromHex("0x9a7bd2b2d3947e4b09f6fdb6042abeee57b0b14fee138b5adef223e08e6553bb"))
+	it, err := NewTrieIterator(context.Background(), ng, root, nil, nil)
+	checkError(err, t)
+
+	// Slot 0 (290d...) before slot 1 (b10e...)
+	var values []string
+	for it.Next() {
+		values = append(values, it.Value().(*EthStorageValue).Value.String())
+	}
+	checkError(it.Err(), t)
+
+	if fmt.Sprintf("%v", values) != "[42 518089183125710698689804214904328352013811406585]" {
+		t.Fatalf("Wrong storage values %v", values)
+	}
+}
+
+func TestTrieIteratorRange(t *testing.T) {
+	ng, root := prepareGenesisNodeGetter(t)
+
+	// From the second account, up to the third one, excluded
+	second := common.HexToAddress("0x3282791d6fd713f1e94f4bfd565eaa78b3a0599d")
+	third := common.HexToAddress("0x5abfec25f74cd88437631a7731906932776356f9")
+	it, err := NewTrieIterator(context.Background(), ng, root,
+		crypto.Keccak256(second[:]), crypto.Keccak256(third[:]))
+	checkError(err, t)
+
+	var count int
+	for it.Next() {
+		count++
+		if it.Value().(*EthAccountSnapshot).Nonce != 3 {
+			t.Fatal("Wrong account in range")
+		}
+	}
+	checkError(it.Err(), t)
+
+	if count != 1 {
+		t.Fatalf("Wrong number of accounts\r\nexpected %d\r\ngot %d", 1, count)
+	}
+
+	_, err = NewTrieIterator(context.Background(), ng, root, []byte{1}, nil)
+	if err == nil || err.Error() != "trie keys must be 32 bytes long" {
+		t.Fatal("Expected error 'trie keys must be 32 bytes long'")
+	}
+}
+
+func TestTrieIteratorMissingNode(t *testing.T) {
+	ng, root := prepareGenesisNodeGetter(t)
+
+	// Take the leaf of the second account out
+	var (
+		key     string
+		missing *EthStateTrie
+	)
+	for k, n := range ng {
+		stn, ok := n.(*EthStateTrie)
+		if ok && stn.nodeKind == "leaf" && stn.elements[1].(*EthAccountSnapshot).Nonce == 3 {
+			key, missing = k, stn
+		}
+	}
+	delete(ng, key)
+
+	it, err := NewTrieIterator(context.Background(), ng, root, nil, nil)
+	checkError(err, t)
+
+	if !it.Next() {
+		t.Fatal("Expected the first account")
+	}
+	if it.Next() {
+		t.Fatal("Expected to stop at the missing node")
+	}
+	mne, ok := it.Err().(*MissingNodeError)
+	if !ok {
+		t.Fatalf("Expected a MissingNodeError, got %v", it.Err())
+	}
+	if mne.Cid.String() != key || fmt.Sprintf("%x", mne.Path) != "04" {
+		t.Fatalf("Wrong missing node %v", mne)
+	}
+
+	// Resume once the node is back
+	ng[key] = missing
+	var count int
+	for it.Next() {
+		count++
+	}
+	checkError(it.Err(), t)
+
+	if count != 2 {
+		t.Fatalf("Wrong number of accounts after resuming\r\nexpected %d\r\ngot %d", 2, count)
+	}
+}
+
+/*
+  AUXILIARS
+*/
+
+// prepareGenesisNodeGetter returns the state and storage trie nodes
+// of the genesis test data as a node.NodeGetter, along with its state root.
+func prepareGenesisNodeGetter(t *testing.T) (mapNodeGetter, *cid.Cid) {
+	fi, err := os.Open("test_data/eth-genesis-json-private")
+	checkError(err, t)
+
+	stateRoot := common.HexToHash("0x7377ff8b5e915adb7687adf17e88697e209d8ef510b921142a1b85d95e7963ec")
+	stateNodes, _, storageNodes, _, err := FromGenesisJSON(fi, stateRoot)
+	checkError(err, t)
+
+	ng := make(mapNodeGetter)
+	for _, stn := range stateNodes {
+		ng[stn.Cid().String()] = stn
+	}
+	for _, stn := range storageNodes {
+		ng[stn.Cid().String()] = stn
+	}
+
+	return ng, keccak256ToCid(MEthStateTrie, stateRoot[:])
+}
@@ -7,6 +7,7 @@ import (
 	cid "github.com/ipfs/go-cid"
 	node "github.com/ipfs/go-ipld-format"
 
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -63,6 +64,9 @@ func decodeTrieNode(c *cid.Cid, b []byte,
 		if nodeKind != "extension" && nodeKind != "leaf" {
 			return nil, fmt.Errorf("unexpected nodeKind returned from decoder")
 		}
+		if err != nil {
+			return nil, err
+		}
 	case 17:
 		nodeKind = "branch"
 		elements, err = parseTrieNodeBranch(i, codec)
@@ -83,8 +87,16 @@ func decodeTrieNode(c *cid.Cid, b []byte,
 
 // decodeCompactKey takes a compact key, and returns its nodeKind and value.
 func decodeCompactKey(i []interface{}) (string, []interface{}, error) {
-	first := i[0].([]byte)
-	last := i[1].([]byte)
+	first, ok := i[0].([]byte)
+	if !ok || len(first) == 0 {
+		return "", nil, fmt.Errorf("invalid compact key")
+	}
+	last, ok := i[1].([]byte)
+	if !ok {
+		// Nodes shorter than a hash are embedded into their parent,
+		// we only deal with the ones linked by their hash.
+		return "", nil, fmt.Errorf("unsupported embedded trie node")
+	}
 
 	switch first[0] / 16 {
 	case '\x00':
@@ -114,9 +126,14 @@ func decodeCompactKey(i []interface{}) (string, []interface{}, error) {
 
 // parseTrieNodeExtension helper improves readability
 func parseTrieNodeExtension(i []interface{}, codec uint64) ([]interface{}, error) {
+	child := i[1].([]byte)
+	if len(child) != 32 {
+		return nil, fmt.Errorf("unrecognized object: %v", child)
+	}
+
 	return []interface{}{
 		i[0].([]byte),
-		keccak256ToCid(codec, i[1].([]byte)),
+		keccak256ToCid(codec, child),
 	}, nil
 }
 
@@ -125,7 +142,10 @@ func parseTrieNodeBranch(i []interface{}, codec uint64) ([]interface{}, error) {
 	var out []interface{}
 
 	for _, vi := range i {
-		v := vi.([]byte)
+		v, ok := vi.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("unsupported embedded trie node")
+		}
 
 		switch len(v) {
 		case 0:
@@ -293,6 +313,19 @@ func (t *TrieNode) MarshalJSON() ([]byte, error) {
 	return json.Marshal(out)
 }
 
+// secureKeyPath returns the path leading to the given key in a secure
+// trie, such as the state and storage tries, where the keys are hashed.
+// This is, the nibbles of keccak256(key), one per path element.
+func secureKeyPath(key []byte) []string {
+	var out []string
+
+	for _, n := range nibbleToByte(crypto.Keccak256(key)) {
+		out = append(out, fmt.Sprintf("%x", n))
+	}
+
+	return out
+}
+
 // nibbleToByte expands the nibbles of a byte slice into their own bytes.
 func nibbleToByte(k []byte) []byte {
 	var out []byte
@@ -352,7 +385,7 @@ func (t *TrieNode) resolveTrieNodeLeaf(p []string) (interface{}, []string, error
 		p = rest
 	}
 
-	link, ok := t.elements[1].(node.Node)
+	link, ok := t.elements[1].(node.Resolver)
 	if !ok {
 		return nil, nil, fmt.Errorf("leaf children is not an IPLD node")
 	}